@@ -0,0 +1,285 @@
+//go:build windows || !windows
+// +build windows !windows
+
+package main
+
+import (
+	"database/sql"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/term"
+)
+
+// most rows listed by a search
+const browseSearchLimit = 1000
+
+// Represents one row of the browse listing
+type BrowseEntry struct {
+	Name      string // relative to the listed folder, or to the scan root in search results
+	Path      string
+	ObjType   string
+	Size      int64 // TotalCalFolderSize of a folder, FileSize otherwise
+	Entries   int64 // folders, files and archive members below it, -1 if not counted
+	Owner     string
+	LastWrite sql.NullTime // CalLastWriteTime of a folder, LastWriteTime otherwise
+	HasError  bool
+}
+
+// runBrowse implements the "browse" subcommand and returns the process exit code
+func runBrowse(args []string) int {
+	var browseDBfile, exportFile string
+
+	browseFlags := flag.NewFlagSet("browse", flag.ExitOnError)
+	browseFlags.StringVar(&browseDBfile, "DBfile", "", "Scan report DB file to browse (mandatory)")
+	browseFlags.StringVar(&exportFile, "ExportFile", "", "File the marked paths are written to with the e key (optional, default is <DBfile>_marked.txt)")
+	if exitCode, ok := parseCommandFlags(browseFlags, args); !ok {
+		return exitCode
+	}
+
+	if browseDBfile == "" {
+		fmt.Println("Mandatory fields are missing, check with -help")
+		return 2
+	}
+	reportDBfile, err := reportDBPath(browseDBfile)
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	if exportFile == "" {
+		exportFile = strings.TrimSuffix(reportDBfile, ".db") + "_marked.txt"
+	}
+	if !term.IsTerminal(int(os.Stdin.Fd())) || !term.IsTerminal(int(os.Stdout.Fd())) {
+		fmt.Println("browse needs an interactive terminal, use report or query otherwise")
+		return 2
+	}
+
+	db, err := openReportDB(reportDBfile)
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	defer db.Close()
+	rootPath, _, err := getScanRoot(db)
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+
+	browser := newBrowser(db, rootPath, exportFile)
+	if err := browser.openFolder(rootPath, ""); err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	if err := runBrowserTerminal(browser); err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	return 0
+}
+
+// returns the prefix of the paths directly below a folder, "" below the "." root of scan-archive reports
+func childPathPrefix(path string, separator string) string {
+	if path == "." {
+		return ""
+	}
+	if strings.HasSuffix(path, separator) {
+		return path // e.g. / or C:\
+	}
+	return path + separator
+}
+
+// returns the prefix of the paths below a folder and the first path after them. The subtree is one range
+// of the Path primary key, as the separator is the last byte of the prefix. Both are "" below the "." root.
+func subtreeBounds(path string, separator string) (string, string) {
+	prefix := childPathPrefix(path, separator)
+	if prefix == "" {
+		return "", ""
+	}
+	return prefix, prefix[:len(prefix)-1] + string(rune(separator[0]+1))
+}
+
+// the path of the entry directly below the listed folder which a subtree row is in, ?2 is the prefix of
+// the listed folder and ?4 the separator
+const childPathSQL = `CASE instr(substr(Path, length(?2) + 1), ?4) WHEN 0 THEN Path
+	ELSE substr(Path, 1, length(?2) + instr(substr(Path, length(?2) + 1), ?4) - 1) END`
+
+// reads the entries directly below a folder, an archive file with -ExpandArchives members is listed like a folder
+func readBrowseEntries(db *sql.DB, path string, separator string) ([]BrowseEntry, error) {
+	prefix, upper := subtreeBounds(path, separator)
+	rangeFilter := `Path <> ?1`
+	if prefix != "" {
+		rangeFilter += ` AND Path > ?2 AND Path < ?3`
+	}
+	args := []interface{}{path, prefix, upper}
+
+	query := `SELECT Path, ObjType, CASE ObjType WHEN 'd' THEN IFNULL(TotalCalFolderSize, 0) ELSE IFNULL(FileSize, 0) END,
+		IFNULL(Owner, ''), CalLastWriteTime, LastWriteTime, IFNULL(hasError, 0)
+		FROM fileinfo WHERE ` + rangeFilter + ` AND instr(substr(Path, length(?2) + 1), ?4) = 0;`
+	rows, err := db.Query(query, append(args, separator)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the entries of %s, error: %v", path, err)
+	}
+	defer rows.Close()
+
+	var entries []BrowseEntry
+	for rows.Next() {
+		var entry BrowseEntry
+		var calLastWrite sql.NullTime
+		if err := rows.Scan(&entry.Path, &entry.ObjType, &entry.Size, &entry.Owner, &calLastWrite, &entry.LastWrite, &entry.HasError); err != nil {
+			return nil, fmt.Errorf("failed to read the entries of %s, error: %v", path, err)
+		}
+		if entry.ObjType == "d" && calLastWrite.Valid {
+			entry.LastWrite = calLastWrite
+		}
+		entry.Name = strings.TrimPrefix(entry.Path, prefix)
+		entries = append(entries, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// the rows below each entry are counted in one pass over the subtree. The folders inside an archive
+	// have no rows of their own, they are listed from the members below them.
+	countQuery := `SELECT Child, COUNT(*) - SUM(Path = Child), SUM(CASE WHEN ObjType = 'a' THEN IFNULL(FileSize, 0) ELSE 0 END), MAX(LastWriteTime)
+		FROM (SELECT Path, ObjType, FileSize, LastWriteTime, ` + childPathSQL + ` AS Child
+			FROM fileinfo WHERE ` + rangeFilter + `) GROUP BY Child;`
+	countRows, err := db.Query(countQuery, append(args, separator)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count the entries of %s, error: %v", path, err)
+	}
+	defer countRows.Close()
+	entryIndex := make(map[string]int, len(entries))
+	for i, entry := range entries {
+		entryIndex[entry.Path] = i
+	}
+	for countRows.Next() {
+		var child string
+		var count, memberSize int64
+		var lastWrite sql.NullString
+		if err := countRows.Scan(&child, &count, &memberSize, &lastWrite); err != nil {
+			return nil, fmt.Errorf("failed to count the entries of %s, error: %v", path, err)
+		}
+		if i, exists := entryIndex[child]; exists {
+			entries[i].Entries = count
+			continue
+		}
+		folder := BrowseEntry{Name: strings.TrimPrefix(child, prefix), Path: child, ObjType: "a", Size: memberSize, Entries: count}
+		folder.LastWrite = parseReportTime(lastWrite.String)
+		entries = append(entries, folder)
+	}
+	return entries, countRows.Err()
+}
+
+// parses a time of the report DB returned as text by an SQL expression, e.g. MAX(LastWriteTime)
+func parseReportTime(text string) sql.NullTime {
+	t, err := time.Parse("2006-01-02 15:04:05.999999999 -0700 MST", text)
+	return sql.NullTime{Time: t, Valid: err == nil}
+}
+
+// returns the biggest entries whose name contains text, ignoring the case
+func searchBrowseEntries(db *sql.DB, rootPath string, separator string, text string) ([]BrowseEntry, error) {
+	query := `SELECT Path, ObjType, CASE ObjType WHEN 'd' THEN IFNULL(TotalCalFolderSize, 0) ELSE IFNULL(FileSize, 0) END AS Size,
+		IFNULL(Owner, ''), CalLastWriteTime, LastWriteTime, IFNULL(hasError, 0)
+		FROM fileinfo WHERE instr(lower(Path), lower(?)) > 0 AND Path <> ? ORDER BY Size DESC;`
+	rows, err := db.Query(query, text, rootPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search for %q, error: %v", text, err)
+	}
+	defer rows.Close()
+
+	lowerText := strings.ToLower(text)
+	rootPrefix := childPathPrefix(rootPath, separator)
+	var entries []BrowseEntry
+	for rows.Next() && len(entries) < browseSearchLimit {
+		var entry BrowseEntry
+		var calLastWrite sql.NullTime
+		if err := rows.Scan(&entry.Path, &entry.ObjType, &entry.Size, &entry.Owner, &calLastWrite, &entry.LastWrite, &entry.HasError); err != nil {
+			return nil, fmt.Errorf("failed to search for %q, error: %v", text, err)
+		}
+		// only the name has to match, not the folders above it
+		if !strings.Contains(strings.ToLower(pathBaseName(entry.Path, separator)), lowerText) {
+			continue
+		}
+		if entry.ObjType == "d" && calLastWrite.Valid {
+			entry.LastWrite = calLastWrite
+		}
+		entry.Name = strings.TrimPrefix(entry.Path, rootPrefix)
+		entry.Entries = -1
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+// returns the last element of a report DB path
+func pathBaseName(path string, separator string) string {
+	return path[strings.LastIndex(path, separator)+1:]
+}
+
+// returns the folder of a report DB path, the root for the entries directly below it
+func pathParent(path string, rootPath string, separator string) string {
+	parent := path[:max(strings.LastIndex(path, separator), 0)]
+	if len(parent) < len(rootPath) || !strings.HasPrefix(path, childPathPrefix(rootPath, separator)) {
+		return rootPath
+	}
+	return parent
+}
+
+// sorts the entries by one of the browse columns
+func sortBrowseEntries(entries []BrowseEntry, column string, descending bool) {
+	sort.SliceStable(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		if descending {
+			a, b = b, a
+		}
+		switch column {
+		case "name":
+			return strings.ToLower(a.Name) < strings.ToLower(b.Name)
+		case "count":
+			return a.Entries < b.Entries
+		case "owner":
+			return strings.ToLower(a.Owner) < strings.ToLower(b.Owner)
+		case "time":
+			return a.LastWrite.Time.Before(b.LastWrite.Time)
+		}
+		return a.Size < b.Size
+	})
+}
+
+// writes the marked paths, one per line
+func exportMarkedPaths(file string, marked map[string]bool) error {
+	paths := make([]string, 0, len(marked))
+	for path := range marked {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	content := strings.Join(paths, "\n")
+	if len(paths) > 0 {
+		content += "\n"
+	}
+	if err := os.WriteFile(file, []byte(content), 0666); err != nil {
+		return fmt.Errorf("failed to write %s, error: %v", file, err)
+	}
+	return nil
+}
+
+// cuts or pads a text to exactly width characters
+func fitText(text string, width int) string {
+	if width <= 0 {
+		return ""
+	}
+	length := utf8.RuneCountInString(text)
+	if length <= width {
+		return text + strings.Repeat(" ", width-length)
+	}
+	runes := []rune(text)
+	if width == 1 {
+		return string(runes[:1])
+	}
+	return string(runes[:width-1]) + "~"
+}
@@ -0,0 +1,108 @@
+//go:build windows || !windows
+// +build windows !windows
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"unicode/utf8"
+
+	"golang.org/x/term"
+)
+
+// runs the browse view on the alternate screen of the terminal until it is quit
+func runBrowserTerminal(browser *browser) error {
+	stdinFd := int(os.Stdin.Fd())
+	oldState, err := term.MakeRaw(stdinFd)
+	if err != nil {
+		return fmt.Errorf("failed to set up the terminal, error: %v", err)
+	}
+	defer term.Restore(stdinFd, oldState)
+	defer enableTerminalSequences()()
+	os.Stdout.WriteString("\x1b[?1049h\x1b[?25l") // alternate screen, hidden cursor
+	defer os.Stdout.WriteString("\x1b[?25h\x1b[?1049l")
+
+	keys := make(chan string, 16)
+	go readKeys(os.Stdin, keys)
+	resized := terminalResizes()
+	for {
+		width, height, err := term.GetSize(int(os.Stdout.Fd()))
+		if err != nil {
+			width, height = 80, 24
+		}
+		os.Stdout.WriteString(browser.render(width, height))
+		select {
+		case key, ok := <-keys:
+			if !ok || browser.handleKey(key) {
+				return nil
+			}
+		case <-resized:
+		}
+	}
+}
+
+// sends the keys typed in the raw mode terminal, the channel is closed when the input ends
+func readKeys(reader io.Reader, keys chan<- string) {
+	defer close(keys)
+	buffer := make([]byte, 256)
+	for {
+		n, err := reader.Read(buffer)
+		for _, key := range parseKeys(buffer[:n]) {
+			keys <- key
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// the escape sequences of the keys, after ESC [ or ESC O
+var escapeKeys = map[string]string{
+	"A": "up", "B": "down", "C": "right", "D": "left",
+	"H": "home", "1~": "home", "7~": "home", "F": "end", "4~": "end", "8~": "end",
+	"5~": "pgup", "6~": "pgdn",
+}
+
+// returns the names of the keys in one read from the terminal, printable keys are returned as they are
+func parseKeys(data []byte) []string {
+	var keys []string
+	for i := 0; i < len(data); {
+		switch b := data[i]; {
+		case b == 0x1b:
+			if i+1 < len(data) && (data[i+1] == '[' || data[i+1] == 'O') {
+				// parameters are digits and ; up to the final byte
+				end := i + 2
+				for end < len(data) && (data[end] >= '0' && data[end] <= '9' || data[end] == ';') {
+					end++
+				}
+				if end < len(data) {
+					if key, known := escapeKeys[string(data[i+2:end+1])]; known {
+						keys = append(keys, key)
+					}
+					i = end + 1
+					continue
+				}
+			}
+			keys = append(keys, "esc") // a lone escape key
+			i++
+		case b == '\r' || b == '\n':
+			keys = append(keys, "enter")
+			i++
+		case b == 0x7f || b == 0x08:
+			keys = append(keys, "backspace")
+			i++
+		case b == 0x03:
+			keys = append(keys, "ctrl-c")
+			i++
+		case b < 0x20:
+			i++ // other control keys are not used
+		default:
+			r, size := utf8.DecodeRune(data[i:])
+			keys = append(keys, string(r))
+			i += size
+		}
+	}
+	return keys
+}
@@ -0,0 +1,32 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+import (
+	"os"
+	"os/signal"
+
+	"golang.org/x/sys/unix"
+)
+
+// the terminals understand the ANSI escape sequences already
+func enableTerminalSequences() func() {
+	return func() {}
+}
+
+// signals a change of the terminal size
+func terminalResizes() <-chan struct{} {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, unix.SIGWINCH)
+	resized := make(chan struct{}, 1)
+	go func() {
+		for range signals {
+			select {
+			case resized <- struct{}{}:
+			default:
+			}
+		}
+	}()
+	return resized
+}
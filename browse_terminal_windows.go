@@ -0,0 +1,42 @@
+//go:build windows
+// +build windows
+
+package main
+
+import (
+	"os"
+	"time"
+
+	"golang.org/x/sys/windows"
+	"golang.org/x/term"
+)
+
+// turns on the ANSI escape sequences of the console, returns the function restoring its mode
+func enableTerminalSequences() func() {
+	handle := windows.Handle(os.Stdout.Fd())
+	var mode uint32
+	if err := windows.GetConsoleMode(handle, &mode); err != nil {
+		return func() {}
+	}
+	windows.SetConsoleMode(handle, mode|windows.ENABLE_VIRTUAL_TERMINAL_PROCESSING)
+	return func() { windows.SetConsoleMode(handle, mode) }
+}
+
+// the console has no resize signal, so its size is polled
+func terminalResizes() <-chan struct{} {
+	resized := make(chan struct{}, 1)
+	go func() {
+		width, height, _ := term.GetSize(int(os.Stdout.Fd()))
+		for range time.Tick(500 * time.Millisecond) {
+			newWidth, newHeight, _ := term.GetSize(int(os.Stdout.Fd()))
+			if newWidth != width || newHeight != height {
+				width, height = newWidth, newHeight
+				select {
+				case resized <- struct{}{}:
+				default:
+				}
+			}
+		}
+	}()
+	return resized
+}
@@ -0,0 +1,314 @@
+//go:build windows || !windows
+// +build windows !windows
+
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// width of the share bar in the browse rows
+const browseBarWidth = 10
+
+// the state of the browse view, changed by handleKey and drawn by render
+type browser struct {
+	db         *sql.DB
+	rootPath   string
+	separator  string
+	exportFile string
+
+	path    string // folder listed, also while the search results are shown
+	entries []BrowseEntry
+	total   int64 // size of all the entries, 100% of the share bar
+	cursor  int
+	offset  int // first entry on the screen
+	rows    int // entries on the screen at the last render
+
+	sortColumn string // size, name, count, owner or time
+	sortDesc   bool
+	marked     map[string]bool
+	selected   map[string]string // last selected path of the folders opened before
+
+	searchText string // the entries are search results while it is set
+	inputMode  bool   // the search text is typed in the footer
+	input      string
+	message    string // shown in the footer until the next key
+}
+
+func newBrowser(db *sql.DB, rootPath string, exportFile string) *browser {
+	return &browser{
+		db:         db,
+		rootPath:   rootPath,
+		separator:  reportPathSeparator(rootPath),
+		exportFile: exportFile,
+		sortColumn: "size",
+		sortDesc:   true,
+		marked:     make(map[string]bool),
+		selected:   make(map[string]string),
+	}
+}
+
+// lists a folder with selectPath selected, or the entry selected when it was open the last time
+func (browser *browser) openFolder(path string, selectPath string) error {
+	entries, err := readBrowseEntries(browser.db, path, browser.separator)
+	if err != nil {
+		return err
+	}
+	if current := browser.current(); current != nil && browser.searchText == "" {
+		browser.selected[browser.path] = current.Path
+	}
+	if selectPath == "" {
+		selectPath = browser.selected[path]
+	}
+	browser.path = path
+	browser.searchText = ""
+	browser.setEntries(entries, selectPath)
+	return nil
+}
+
+// runs a search from the scan root, the results are listed instead of the folder
+func (browser *browser) search(text string) error {
+	entries, err := searchBrowseEntries(browser.db, browser.rootPath, browser.separator, text)
+	if err != nil {
+		return err
+	}
+	if current := browser.current(); current != nil && browser.searchText == "" {
+		browser.selected[browser.path] = current.Path
+	}
+	browser.searchText = text
+	browser.setEntries(entries, "")
+	if len(entries) >= browseSearchLimit {
+		browser.message = fmt.Sprintf("Showing the %d biggest matches only", browseSearchLimit)
+	}
+	return nil
+}
+
+func (browser *browser) setEntries(entries []BrowseEntry, selectPath string) {
+	browser.entries = entries
+	browser.total = 0
+	for _, entry := range entries {
+		browser.total += entry.Size
+	}
+	sortBrowseEntries(browser.entries, browser.sortColumn, browser.sortDesc)
+	browser.cursor, browser.offset = 0, 0
+	browser.selectPath(selectPath)
+}
+
+func (browser *browser) selectPath(path string) {
+	for i, entry := range browser.entries {
+		if entry.Path == path {
+			browser.cursor = i
+			return
+		}
+	}
+}
+
+// returns the selected entry, nil in an empty folder
+func (browser *browser) current() *BrowseEntry {
+	if browser.cursor < 0 || browser.cursor >= len(browser.entries) {
+		return nil
+	}
+	return &browser.entries[browser.cursor]
+}
+
+func (browser *browser) moveCursor(delta int) {
+	browser.cursor = max(min(browser.cursor+delta, len(browser.entries)-1), 0)
+}
+
+// a folder, or an archive or archive folder with members below it
+func (entry *BrowseEntry) isFolder() bool {
+	return entry.ObjType == "d" || entry.Entries > 0
+}
+
+// applies one key read by the terminal loop, returns true to quit
+func (browser *browser) handleKey(key string) bool {
+	browser.message = ""
+	if key == "ctrl-c" {
+		return true
+	}
+	if browser.inputMode {
+		browser.handleInputKey(key)
+		return false
+	}
+
+	var err error
+	page := max(browser.rows-1, 1)
+	switch key {
+	case "q":
+		return true
+	case "up", "k":
+		browser.moveCursor(-1)
+	case "down", "j":
+		browser.moveCursor(1)
+	case "pgup":
+		browser.moveCursor(-page)
+	case "pgdn":
+		browser.moveCursor(page)
+	case "home", "g":
+		browser.cursor = 0
+	case "end", "G":
+		browser.moveCursor(len(browser.entries))
+	case "enter", "right", "l":
+		current := browser.current()
+		if current == nil {
+			break
+		}
+		if browser.searchText != "" {
+			// a search result is shown in its folder
+			err = browser.openFolder(pathParent(current.Path, browser.rootPath, browser.separator), current.Path)
+		} else if current.isFolder() {
+			err = browser.openFolder(current.Path, "")
+		}
+	case "left", "backspace", "h", "esc":
+		if browser.searchText != "" {
+			err = browser.openFolder(browser.path, "")
+		} else if browser.path != browser.rootPath {
+			err = browser.openFolder(pathParent(browser.path, browser.rootPath, browser.separator), browser.path)
+		}
+	case "s", "n", "c", "o", "t":
+		browser.sortBy(map[string]string{"s": "size", "n": "name", "c": "count", "o": "owner", "t": "time"}[key])
+	case "/":
+		browser.inputMode = true
+		browser.input = ""
+	case " ", "m":
+		if current := browser.current(); current != nil {
+			if browser.marked[current.Path] {
+				delete(browser.marked, current.Path)
+			} else {
+				browser.marked[current.Path] = true
+			}
+			browser.moveCursor(1)
+		}
+	case "e":
+		if err = exportMarkedPaths(browser.exportFile, browser.marked); err == nil {
+			browser.message = fmt.Sprintf("Exported %d marked paths to %s", len(browser.marked), browser.exportFile)
+		}
+	}
+	if err != nil {
+		browser.message = err.Error()
+	}
+	return false
+}
+
+// the keys typed after / edit the search text until enter or esc
+func (browser *browser) handleInputKey(key string) {
+	switch key {
+	case "esc":
+		browser.inputMode = false
+	case "enter":
+		browser.inputMode = false
+		if browser.input != "" {
+			if err := browser.search(browser.input); err != nil {
+				browser.message = err.Error()
+			}
+		}
+	case "backspace":
+		if _, size := utf8.DecodeLastRuneInString(browser.input); size > 0 {
+			browser.input = browser.input[:len(browser.input)-size]
+		}
+	default:
+		if r, size := utf8.DecodeRuneInString(key); size == len(key) && unicode.IsPrint(r) {
+			browser.input += key
+		}
+	}
+}
+
+// sorts by another column, the same column again reverses the order
+func (browser *browser) sortBy(column string) {
+	if column == browser.sortColumn {
+		browser.sortDesc = !browser.sortDesc
+	} else {
+		browser.sortColumn = column
+		browser.sortDesc = column == "size" || column == "count" || column == "time" // biggest, most and newest first
+	}
+	var selectPath string
+	if current := browser.current(); current != nil {
+		selectPath = current.Path
+	}
+	sortBrowseEntries(browser.entries, browser.sortColumn, browser.sortDesc)
+	browser.selectPath(selectPath)
+}
+
+// returns the whole screen, drawn from the top left corner of the terminal
+func (browser *browser) render(width int, height int) string {
+	var screen strings.Builder
+	screen.WriteString("\x1b[H")
+	writeLine := func(line string, highlight bool) {
+		if highlight {
+			screen.WriteString("\x1b[7m" + fitText(line, width) + "\x1b[0m\r\n")
+		} else {
+			screen.WriteString(fitText(line, width) + "\x1b[K\r\n")
+		}
+	}
+
+	title := browser.path
+	if browser.searchText != "" {
+		title = fmt.Sprintf("Search %q", browser.searchText)
+	}
+	order := "ascending"
+	if browser.sortDesc {
+		order = "descending"
+	}
+	writeLine(" "+title, true)
+	writeLine(fmt.Sprintf(" Total %s in %d entries, sorted by %s %s, %d marked", formatBytes(browser.total),
+		len(browser.entries), browser.sortColumn, order, len(browser.marked)), false)
+	writeLine(fmt.Sprintf("   %10s %-*s %6s %8s  %-12s  %-16s  %s", "Size", browseBarWidth+2, "Share", "%", "Entries", "Owner", "Last write", "Name"), false)
+
+	// the header lines above and the footer line below the entries
+	browser.rows = max(height-4, 1)
+	if browser.cursor < browser.offset {
+		browser.offset = browser.cursor
+	} else if browser.cursor >= browser.offset+browser.rows {
+		browser.offset = browser.cursor - browser.rows + 1
+	}
+	for i := browser.offset; i < browser.offset+browser.rows; i++ {
+		if i >= len(browser.entries) {
+			screen.WriteString("\x1b[K\r\n")
+			continue
+		}
+		writeLine(browser.formatEntry(&browser.entries[i]), i == browser.cursor)
+	}
+
+	footer := " up/down move  right open  left back  s/n/c/o/t sort  / search  space mark  e export  q quit"
+	if browser.inputMode {
+		footer = " Search: " + browser.input + "_"
+	} else if browser.message != "" {
+		footer = " " + browser.message
+	}
+	screen.WriteString(fitText(footer, width-1) + "\x1b[K")
+	return screen.String()
+}
+
+// returns one row of the listing
+func (browser *browser) formatEntry(entry *BrowseEntry) string {
+	mark, errorMark := " ", " "
+	if browser.marked[entry.Path] {
+		mark = "*"
+	}
+	if entry.HasError {
+		errorMark = "!"
+	}
+	share := 0.0
+	if browser.total > 0 {
+		share = float64(entry.Size) / float64(browser.total)
+	}
+	filled := int(share*browseBarWidth + 0.5)
+	bar := "[" + strings.Repeat("#", filled) + strings.Repeat(" ", browseBarWidth-filled) + "]"
+	var count, lastWrite string
+	if entry.isFolder() && entry.Entries >= 0 {
+		count = fmt.Sprint(entry.Entries)
+	}
+	if entry.LastWrite.Valid && !entry.LastWrite.Time.IsZero() {
+		lastWrite = entry.LastWrite.Time.Local().Format("2006-01-02 15:04")
+	}
+	name := entry.Name
+	if entry.isFolder() && browser.searchText == "" {
+		name += browser.separator
+	}
+	return fmt.Sprintf("%s%s %10s %s %5.1f%% %8s  %-12s  %-16s  %s", mark, errorMark, formatBytes(entry.Size), bar,
+		share*100, count, fitText(entry.Owner, 12), lastWrite, name)
+}
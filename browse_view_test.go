@@ -0,0 +1,182 @@
+//go:build windows || !windows
+// +build windows !windows
+
+package main
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// writes the report DB of a small /data scan with an expanded archive.zip holding the docs folder
+func writeBrowseTestDB(t *testing.T, file string) {
+	t.Helper()
+	db, err := sql.Open("sqlite", file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	_, err = db.Exec(`CREATE TABLE fileinfo (ObjType TEXT, Path TEXT PRIMARY KEY UNIQUE, ObjectDepth INTEGER, FileSize INTEGER,
+		CompressedSize INTEGER, ThisFolderSize INTEGER, TotalCalFolderSize INTEGER, hasError BOOLEAN, ErrorMessage TEXT,
+		ErrorCode TEXT, ErrorStage TEXT, Owner TEXT, CreationTime DATETIME, LastWriteTime DATETIME, CalLastWriteTime DATETIME,
+		LastAccessTime DATETIME);`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows := []struct {
+		objType   string
+		path      string
+		depth     int
+		size      int64
+		owner     string
+		lastWrite string
+	}{
+		{"d", "/data", 1, 105, "root", "2024-06-01 10:00:00 +0000 UTC"},
+		{"d", "/data/a", 2, 50, "alice", "2024-06-01 10:00:00 +0000 UTC"},
+		{"f", "/data/a/x.txt", 2, 40, "alice", "2020-01-01 10:00:00 +0000 UTC"},
+		{"f", "/data/a/y.log", 2, 10, "bob", "2024-06-01 10:00:00 +0000 UTC"},
+		{"f", "/data/b.txt", 1, 30, "carol", "2023-01-01 10:00:00 +0000 UTC"},
+		{"f", "/data/c.txt", 1, 5, "alice", "2022-01-01 10:00:00 +0000 UTC"},
+		{"f", "/data/archive.zip", 1, 20, "dave", "2021-01-01 10:00:00 +0000 UTC"},
+		{"a", "/data/archive.zip/docs/readme.txt", 3, 100, "erin", "2019-01-01 10:00:00 +0000 UTC"},
+	}
+	for _, row := range rows {
+		fileSize, folderSize := row.size, int64(0)
+		if row.objType == "d" {
+			fileSize, folderSize = 0, row.size
+		}
+		_, err := db.Exec(`INSERT INTO fileinfo (ObjType, Path, ObjectDepth, FileSize, ThisFolderSize, TotalCalFolderSize, hasError,
+			Owner, LastWriteTime, CalLastWriteTime) VALUES (?, ?, ?, ?, 0, ?, 0, ?, ?, ?);`,
+			row.objType, row.path, row.depth, fileSize, folderSize, row.owner, row.lastWrite, row.lastWrite)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func entryNames(entries []BrowseEntry) []string {
+	names := []string{}
+	for _, entry := range entries {
+		names = append(names, entry.Name)
+	}
+	return names
+}
+
+// the keys of the terminal loop change the listed folder, the order and the selected entry
+func TestBrowserKeys(t *testing.T) {
+	dbFile := filepath.Join(t.TempDir(), "browse.db")
+	writeBrowseTestDB(t, dbFile)
+	db, err := openReportDB(dbFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	tests := []struct {
+		keys         []string
+		wantPath     string
+		wantNames    []string
+		wantSelected string
+	}{
+		{nil, "/data", []string{"a", "b.txt", "archive.zip", "c.txt"}, "a"},
+		{[]string{"enter"}, "/data/a", []string{"x.txt", "y.log"}, "x.txt"},
+		{[]string{"j", "l", "h"}, "/data", []string{"a", "b.txt", "archive.zip", "c.txt"}, "b.txt"},
+		{[]string{"enter", "left"}, "/data", []string{"a", "b.txt", "archive.zip", "c.txt"}, "a"},  // back on the folder left
+		{[]string{"left"}, "/data", []string{"a", "b.txt", "archive.zip", "c.txt"}, "a"},           // not above the root
+		{[]string{"j", "enter"}, "/data", []string{"a", "b.txt", "archive.zip", "c.txt"}, "b.txt"}, // a file is not opened
+		{[]string{"G", "j", "k"}, "/data", []string{"a", "b.txt", "archive.zip", "c.txt"}, "archive.zip"},
+		{[]string{"j", "j", "enter"}, "/data/archive.zip", []string{"docs"}, "docs"},
+		{[]string{"j", "j", "enter", "enter"}, "/data/archive.zip/docs", []string{"readme.txt"}, "readme.txt"},
+		{[]string{"n"}, "/data", []string{"a", "archive.zip", "b.txt", "c.txt"}, "a"},
+		{[]string{"n", "n"}, "/data", []string{"c.txt", "b.txt", "archive.zip", "a"}, "a"},
+		{[]string{"j", "t"}, "/data", []string{"a", "b.txt", "c.txt", "archive.zip"}, "b.txt"}, // newest first, the selection is kept
+		{[]string{"c"}, "/data", []string{"a", "archive.zip", "b.txt", "c.txt"}, "a"},
+		{[]string{"o"}, "/data", []string{"a", "c.txt", "b.txt", "archive.zip"}, "a"},
+		{[]string{"/", "t", "x", "t", "enter"}, "/data", []string{"archive.zip/docs/readme.txt", "a/x.txt", "b.txt", "c.txt"}, "archive.zip/docs/readme.txt"},
+		{[]string{"/", "l", "o", "g", "backspace", "esc"}, "/data", []string{"a", "b.txt", "archive.zip", "c.txt"}, "a"},
+		{[]string{"/", "t", "x", "t", "enter", "j", "enter"}, "/data/a", []string{"x.txt", "y.log"}, "x.txt"}, // a result opens its folder
+		{[]string{"/", "t", "x", "t", "enter", "esc"}, "/data", []string{"a", "b.txt", "archive.zip", "c.txt"}, "a"},
+	}
+	for _, test := range tests {
+		browser := newBrowser(db, "/data", "")
+		if err := browser.openFolder("/data", ""); err != nil {
+			t.Fatal(err)
+		}
+		for _, key := range test.keys {
+			if browser.handleKey(key) {
+				t.Fatalf("keys %q: %s quit", test.keys, key)
+			}
+		}
+		if browser.message != "" {
+			t.Fatalf("keys %q: message %q", test.keys, browser.message)
+		}
+		if browser.path != test.wantPath || !reflect.DeepEqual(entryNames(browser.entries), test.wantNames) {
+			t.Fatalf("keys %q: %s lists %q, want %s listing %q", test.keys, browser.path, entryNames(browser.entries), test.wantPath, test.wantNames)
+		}
+		if current := browser.current(); current == nil || current.Name != test.wantSelected {
+			t.Fatalf("keys %q: selected %+v, want %s", test.keys, current, test.wantSelected)
+		}
+	}
+}
+
+// the archive folder and the subfolder are listed with their entry counts and sizes
+func TestReadBrowseEntries(t *testing.T) {
+	dbFile := filepath.Join(t.TempDir(), "browse.db")
+	writeBrowseTestDB(t, dbFile)
+	db, err := openReportDB(dbFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	tests := []struct {
+		path        string
+		wantEntries map[string]int64
+		wantSizes   map[string]int64
+	}{
+		{"/data", map[string]int64{"a": 2, "b.txt": 0, "archive.zip": 1, "c.txt": 0}, map[string]int64{"a": 50, "b.txt": 30, "archive.zip": 20, "c.txt": 5}},
+		{"/data/archive.zip", map[string]int64{"docs": 1}, map[string]int64{"docs": 100}},
+	}
+	for _, test := range tests {
+		entries, err := readBrowseEntries(db, test.path, "/")
+		if err != nil {
+			t.Fatal(err)
+		}
+		counts, sizes := map[string]int64{}, map[string]int64{}
+		for _, entry := range entries {
+			counts[entry.Name], sizes[entry.Name] = entry.Entries, entry.Size
+		}
+		if !reflect.DeepEqual(counts, test.wantEntries) || !reflect.DeepEqual(sizes, test.wantSizes) {
+			t.Fatalf("readBrowseEntries(%s) counts %v sizes %v, want %v and %v", test.path, counts, sizes, test.wantEntries, test.wantSizes)
+		}
+	}
+}
+
+// the marked paths are written sorted, marking again unmarks
+func TestBrowserExportMarked(t *testing.T) {
+	dir := t.TempDir()
+	dbFile := filepath.Join(dir, "browse.db")
+	writeBrowseTestDB(t, dbFile)
+	db, err := openReportDB(dbFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	exportFile := filepath.Join(dir, "marked.txt")
+	browser := newBrowser(db, "/data", exportFile)
+	if err := browser.openFolder("/data", ""); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{" ", " ", "k", "m", "G", "m", "e"} {
+		browser.handleKey(key)
+	}
+	content, err := os.ReadFile(exportFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "/data/a\n/data/c.txt\n"; string(content) != want {
+		t.Fatalf("exported %q, want %q", content, want)
+	}
+}
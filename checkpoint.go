@@ -0,0 +1,113 @@
+//go:build windows || !windows
+// +build windows !windows
+
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// cancels the scan on the first SIGINT/SIGTERM so that the buffered data gets written and
+// the unvisited folders get saved to the checkpoint table. A second signal exits immediately.
+func handleShutdownSignals(cancel context.CancelFunc) {
+	sigChan := make(chan os.Signal, 2)
+	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		sig := <-sigChan
+		logger.Warn("Received a signal, stopping the scan and saving a checkpoint. Send it again to exit immediately.", "signal", sig.String())
+		cancel()
+		sig = <-sigChan
+		logger.Error("Received a signal again, exiting without saving the checkpoint", "signal", sig.String())
+		os.Exit(1)
+	}()
+}
+
+// returns the folders saved by an interrupted scan
+func readCheckpoint(db *sql.DB) ([]ErrorObjectInfo, error) {
+	query := `SELECT Path, ObjectDepth FROM checkpoint ORDER BY ObjectDepth, Path;`
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query: %s error is %v", query, err)
+	}
+	defer rows.Close()
+
+	var folders []ErrorObjectInfo
+	for rows.Next() {
+		var folder ErrorObjectInfo
+		if err := rows.Scan(&folder.Path, &folder.ObjectDepth); err != nil {
+			return nil, fmt.Errorf("failed to scan a row: %v", err)
+		}
+		folders = append(folders, folder)
+	}
+	return folders, rows.Err()
+}
+
+// returns the number of folders in the checkpoint table, 0 for report DBs without one
+func checkpointFolderCount(db *sql.DB) (int, error) {
+	var tables int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'checkpoint';`).Scan(&tables); err != nil {
+		return 0, fmt.Errorf("failed to look for the checkpoint table: %v", err)
+	}
+	if tables == 0 {
+		return 0, nil
+	}
+	var folders int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM checkpoint;`).Scan(&folders); err != nil {
+		return 0, fmt.Errorf("failed to count the checkpoint folders: %v", err)
+	}
+	return folders, nil
+}
+
+// returns the number of folders in the checkpoint of the report DB file
+func pendingCheckpointFolders(reportDBfile string) (int, error) {
+	db, err := openReportDB(reportDBfile)
+	if err != nil {
+		return 0, err
+	}
+	defer db.Close()
+	return checkpointFolderCount(db)
+}
+
+// replaces the checkpoint table content with the folders not read by the scan.
+// An empty checkpoint table means the scan is complete.
+func saveCheckpoint(pendingFolders []ErrorObjectInfo) error {
+	db, err := sql.Open("sqlite", DBfile)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	createTableSQL := `
+    CREATE TABLE IF NOT EXISTS checkpoint (
+        Path TEXT PRIMARY KEY UNIQUE,
+        ObjectDepth INTEGER,
+        SavedAt DATETIME
+    );`
+	if _, err := db.Exec(createTableSQL); err != nil {
+		return fmt.Errorf("failed to create checkpoint table: %v", err)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %v", err)
+	}
+	if _, err := tx.Exec(`DELETE FROM checkpoint;`); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to clear the checkpoint table: %v", err)
+	}
+	savedAt := time.Now().Round(0)
+	for _, folder := range pendingFolders {
+		if _, err := tx.Exec(`INSERT OR REPLACE INTO checkpoint (Path, ObjectDepth, SavedAt) VALUES (?, ?, ?);`,
+			folder.Path, folder.ObjectDepth, savedAt); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to save %s to the checkpoint table: %v", folder.Path, err)
+		}
+	}
+	return tx.Commit()
+}
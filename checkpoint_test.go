@@ -0,0 +1,84 @@
+//go:build windows || !windows
+// +build windows !windows
+
+package main
+
+import (
+	"database/sql"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestCheckpointFolderCount(t *testing.T) {
+	quietGlobals(t)
+	dir := t.TempDir()
+	savedDBfile := DBfile
+	t.Cleanup(func() { DBfile = savedDBfile })
+
+	// a report DB of an older version has no checkpoint table
+	DBfile = filepath.Join(dir, "report.db")
+	writeExportTestDB(t, DBfile, "/data/", "/data/a/")
+	if folders, err := pendingCheckpointFolders(DBfile); err != nil || folders != 0 {
+		t.Fatalf("pendingCheckpointFolders() without a checkpoint table = %d, %v, want 0", folders, err)
+	}
+
+	pending := []ErrorObjectInfo{{Path: "/data/b", ObjectDepth: 2}, {Path: "/data/a/c", ObjectDepth: 3}, {Path: "/data/a", ObjectDepth: 2}}
+	if err := saveCheckpoint(pending); err != nil {
+		t.Fatal(err)
+	}
+	if folders, err := pendingCheckpointFolders(DBfile); err != nil || folders != len(pending) {
+		t.Fatalf("pendingCheckpointFolders() = %d, %v, want %d", folders, err, len(pending))
+	}
+	db, err := sql.Open("sqlite", DBfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved, err := readCheckpoint(db)
+	db.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []ErrorObjectInfo{{Path: "/data/a", ObjectDepth: 2}, {Path: "/data/b", ObjectDepth: 2}, {Path: "/data/a/c", ObjectDepth: 3}}
+	if !reflect.DeepEqual(saved, want) {
+		t.Fatalf("readCheckpoint() = %+v, want %+v", saved, want)
+	}
+
+	// a complete scan leaves an empty checkpoint table
+	if err := saveCheckpoint(nil); err != nil {
+		t.Fatal(err)
+	}
+	if folders, err := pendingCheckpointFolders(DBfile); err != nil || folders != 0 {
+		t.Fatalf("pendingCheckpointFolders() after a complete scan = %d, %v, want 0", folders, err)
+	}
+
+	if _, err := pendingCheckpointFolders(filepath.Join(dir, "missing.db")); err == nil {
+		t.Fatal("pendingCheckpointFolders() of a missing file did not fail")
+	}
+}
+
+// a retry on a report DB with checkpoint folders left is refused before the scan starts
+func TestRetryRefusesInterruptedScan(t *testing.T) {
+	quietGlobals(t)
+	dir := t.TempDir()
+	savedDBfile, savedDirPath, savedUpdateErrorOnly := DBfile, dirPath, updateErrorOnly
+	t.Cleanup(func() { DBfile, dirPath, updateErrorOnly = savedDBfile, savedDirPath, savedUpdateErrorOnly })
+
+	DBfile = filepath.Join(dir, "report.db")
+	writeExportTestDB(t, DBfile, "/data/")
+	if err := saveCheckpoint([]ErrorObjectInfo{{Path: "/data/a", ObjectDepth: 2}}); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		command string
+		args    []string
+	}{
+		{"retry", []string{"-Path", dir, "-DBfile", DBfile}},
+		{"scan", []string{"-Path", dir, "-DBfile", DBfile, "-UpdateErrorOnly=true"}},
+	}
+	for _, test := range tests {
+		if code := runScan(test.command, test.args); code != 2 {
+			t.Fatalf("runScan(%s, %q) = %d, want 2", test.command, test.args, code)
+		}
+	}
+}
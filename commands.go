@@ -0,0 +1,108 @@
+//go:build windows || !windows
+// +build windows !windows
+
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Represents one subcommand of the tool
+type Command struct {
+	Name        string
+	Description string
+	Run         func(args []string) int // returns the process exit code
+}
+
+// all the subcommands, in the order of the help output
+var commands []Command
+
+// the commands are set in init as the config checks run them to list their flags
+func init() {
+	commands = []Command{
+		{"scan", "Scan a folder into a new report DB (or -UpdateErrorOnly / -Resume an existing one)", func(args []string) int { return runScan("scan", args) }},
+		{"retry", "Scan the failed folders and files of a report DB again", func(args []string) int { return runScan("retry", args) }},
+		{"scan-archive", "Scan a tar file or stream (gzip or zstd compressed too) into a new report DB", runScanArchive},
+		{"report", "Print a summary of a report DB, or its top entries with report top", runReport},
+		{"browse", "Browse a report DB interactively, biggest entries first", runBrowse},
+		{"diff", "Compare two report DBs of the same folder", runDiff},
+		{"export", "Export a report DB to Apache Parquet", runExport},
+		{"query", "Run a SQL query against a report DB", runQuery},
+		{"serve", "Serve report DBs read only over a JSON HTTP API", runServe},
+	}
+}
+
+// runs the subcommand given as first argument. Without a subcommand the arguments are the
+// flags of the older versions, which are the flags of the scan subcommand.
+func runCommand(args []string) int {
+	if len(args) == 0 {
+		return runLegacyScan(args)
+	}
+	switch args[0] {
+	case "help", "-help", "--help", "-h":
+		printUsage()
+		return 0
+	}
+	for _, command := range commands {
+		if command.Name == args[0] {
+			return command.Run(args[1:])
+		}
+	}
+	if strings.HasPrefix(args[0], "-") {
+		return runLegacyScan(args)
+	}
+	fmt.Printf("Unknown command %q\n", args[0])
+	printUsage()
+	return 2
+}
+
+// runs the flag only invocation of the older versions, which exited with 0 on the failed pre-checks
+// (missing or invalid flags, existing DBfile...). The scan and retry commands exit with 2 instead.
+func runLegacyScan(args []string) int {
+	exitCode := runScan("scan", args)
+	if exitCode == 2 {
+		return 0
+	}
+	return exitCode
+}
+
+func printUsage() {
+	executable := filepath.Base(os.Args[0])
+	fmt.Printf("Usage: %s <command> [flags]\n\nCommands:\n", executable)
+	for _, command := range commands {
+		fmt.Printf("  %-13s %s\n", command.Name, command.Description)
+	}
+	fmt.Printf("\nRun '%s <command> -help' for the flags of a command.\n", executable)
+	fmt.Printf("The flags of the scan command also work without the command name, e.g. %s -Path=C:\\Temp -DBfile=temp\n", executable)
+}
+
+// adds the .db extension if missing and checks that the report DB exists
+func reportDBPath(reportDBfile string) (string, error) {
+	if reportDBfile == "" {
+		return "", fmt.Errorf("the report DB file is missing")
+	}
+	if !strings.HasSuffix(reportDBfile, ".db") {
+		reportDBfile += ".db"
+	}
+	info, err := os.Stat(reportDBfile)
+	if err != nil {
+		return "", fmt.Errorf("cannot read the DBfile %s error message: %v", reportDBfile, err)
+	}
+	if info.IsDir() {
+		return "", fmt.Errorf("the DBfile %s cannot be a directory", reportDBfile)
+	}
+	return reportDBfile, nil
+}
+
+// opens an existing report DB read only
+func openReportDB(reportDBfile string) (*sql.DB, error) {
+	reportDBfile, err := reportDBPath(reportDBfile)
+	if err != nil {
+		return nil, err
+	}
+	return sql.Open("sqlite", "file:"+filepath.ToSlash(reportDBfile)+"?mode=ro")
+}
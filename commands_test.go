@@ -0,0 +1,39 @@
+//go:build windows || !windows
+// +build windows !windows
+
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+// the flag only invocation keeps the exit code 0 of the older versions on the failed pre-checks,
+// the subcommands exit with 2
+func TestRunCommandExitCodes(t *testing.T) {
+	quietGlobals(t)
+	dir := t.TempDir()
+	savedDBfile, savedDirPath, savedUpdateErrorOnly := DBfile, dirPath, updateErrorOnly
+	t.Cleanup(func() { DBfile, dirPath, updateErrorOnly = savedDBfile, savedDirPath, savedUpdateErrorOnly })
+
+	existing := filepath.Join(dir, "existing.db")
+	writeExportTestDB(t, existing, "/data/")
+	tests := []struct {
+		args []string
+		want int
+	}{
+		{nil, 0},
+		{[]string{"-DBfile", existing}, 0}, // missing -Path
+		{[]string{"-Path", dir, "-DBfile", existing}, 0}, // existing DBfile
+		{[]string{"scan", "-DBfile", existing}, 2},
+		{[]string{"scan", "-Path", dir, "-DBfile", existing}, 2},
+		{[]string{"retry", "-Path", dir, "-DBfile", filepath.Join(dir, "missing.db")}, 2},
+		{[]string{"unknown"}, 2},
+		{[]string{"help"}, 0},
+	}
+	for _, test := range tests {
+		if code := runCommand(test.args); code != test.want {
+			t.Fatalf("runCommand(%q) = %d, want %d", test.args, code, test.want)
+		}
+	}
+}
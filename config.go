@@ -0,0 +1,303 @@
+//go:build windows || !windows
+// +build windows !windows
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// prefix of the environment variables overriding the flags, e.g. FOLDERINSIGHT_BUFFERSIZE
+const envPrefix = "FOLDERINSIGHT_"
+
+var (
+	configFile    string
+	configProfile string
+	printConfig   bool
+)
+
+// lower case flag names of the commands while commandFlagNames collects them, nil otherwise
+var collectedFlagNames map[string]bool
+
+// Represents a config file: the settings apply to every run, the selected profile overrides them.
+// The keys are the flag names (case insensitive), lists are joined with commas.
+type ConfigFile struct {
+	Settings map[string]interface{}            `yaml:"settings" json:"settings" toml:"settings"`
+	Profiles map[string]map[string]interface{} `yaml:"profiles" json:"profiles" toml:"profiles"`
+}
+
+// registers -Config, -Profile and -PrintConfig on the flag set of a command
+func addConfigFlags(flags *flag.FlagSet) {
+	flags.StringVar(&configFile, "Config", "", "YAML, TOML or JSON config file with the default settings and profiles (optional)")
+	flags.StringVar(&configProfile, "Profile", "", "Profile of the config file to use, e.g. nas-nightly (optional)")
+	flags.BoolVar(&printConfig, "PrintConfig", false, "Print the effective settings and their source, then exit (optional, default is false)")
+}
+
+// registers the config flags on the flag set of a command, parses args and fills the other flags from
+// the environment and the config file. Returns false with the exit code when the command must stop,
+// after -PrintConfig or on an invalid setting.
+func parseCommandFlags(flags *flag.FlagSet, args []string) (int, bool) {
+	if collectedFlagNames != nil {
+		addConfigFlags(flags)
+		flags.VisitAll(func(f *flag.Flag) {
+			collectedFlagNames[strings.ToLower(f.Name)] = true
+		})
+		return 0, false
+	}
+	// before the parsing, the other commands reset the shared flag variables to their defaults
+	knownNames := commandFlagNames()
+	addConfigFlags(flags)
+	flags.Parse(args)
+	settingSources, ignoredKeys, err := applyConfig(flags, knownNames)
+	if err != nil {
+		fmt.Println(err)
+		return 2, false
+	}
+	if printConfig {
+		printEffectiveConfig(flags, settingSources, ignoredKeys)
+		return 0, false
+	}
+	return 0, true
+}
+
+// returns the lower case flag names of all the commands. Every command is run without arguments
+// while parseCommandFlags only collects the names of its flags and stops it.
+func commandFlagNames() map[string]bool {
+	collectedFlagNames = make(map[string]bool)
+	defer func() { collectedFlagNames = nil }()
+	for _, command := range commands {
+		command.Run(nil)
+	}
+	runReport([]string{"top"})
+	return collectedFlagNames
+}
+
+// fills the flags not given on the command line from the FOLDERINSIGHT_* environment variables and
+// then from the config file, so the precedence is flags > env vars > config > defaults.
+// One config file serves all the commands, so the keys which are flags of another command are skipped,
+// the keys and the variables which are not flags of any command in knownNames are an error.
+// Returns the source of every flag which isn't at its default and the skipped keys.
+func applyConfig(flags *flag.FlagSet, knownNames map[string]bool) (map[string]string, []string, error) {
+	sources := make(map[string]string)
+	flags.Visit(func(f *flag.Flag) {
+		sources[f.Name] = "flag"
+	})
+
+	for _, variable := range os.Environ() {
+		envName, _, _ := strings.Cut(variable, "=")
+		if strings.HasPrefix(envName, envPrefix) && !knownNames[strings.ToLower(strings.TrimPrefix(envName, envPrefix))] {
+			return nil, nil, fmt.Errorf("unknown environment variable %s, it is not a flag of any command", envName)
+		}
+	}
+
+	var envErr error
+	flags.VisitAll(func(f *flag.Flag) {
+		if sources[f.Name] != "" || envErr != nil {
+			return
+		}
+		envName := envPrefix + strings.ToUpper(f.Name)
+		if value, ok := os.LookupEnv(envName); ok {
+			if err := flags.Set(f.Name, value); err != nil {
+				envErr = fmt.Errorf("invalid value %q of %s: %v", value, envName, err)
+				return
+			}
+			sources[f.Name] = "env " + envName
+		}
+	})
+	if envErr != nil {
+		return nil, nil, envErr
+	}
+
+	if configFile == "" {
+		if configProfile != "" {
+			return nil, nil, fmt.Errorf("-Profile needs a -Config file")
+		}
+		return sources, nil, nil
+	}
+	settings, err := readConfigSettings(configFile, configProfile, knownNames)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// config keys match the flag names case insensitively
+	flagNames := make(map[string]string)
+	flags.VisitAll(func(f *flag.Flag) {
+		flagNames[strings.ToLower(f.Name)] = f.Name
+	})
+	keys := make([]string, 0, len(settings))
+	for key := range settings {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var ignoredKeys []string
+	for _, key := range keys {
+		setting := settings[key]
+		name, ok := flagNames[key]
+		if !ok {
+			ignoredKeys = append(ignoredKeys, setting.key)
+			continue
+		}
+		switch name {
+		case "Config", "Profile", "PrintConfig":
+			return nil, nil, fmt.Errorf("%s cannot be set in the config file %s", name, configFile)
+		}
+		if sources[name] != "" {
+			continue
+		}
+		value, err := configValueString(setting.value)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid value of %s in %s: %v", setting.key, configFile, err)
+		}
+		if err := flags.Set(name, value); err != nil {
+			return nil, nil, fmt.Errorf("invalid value %q of %s in %s: %v", value, setting.key, configFile, err)
+		}
+		sources[name] = setting.source
+	}
+	return sources, ignoredKeys, nil
+}
+
+// Represents one value of the config file
+type configSetting struct {
+	key    string // as written in the file
+	value  interface{}
+	source string // config or profile <name>
+}
+
+// reads the config file and returns its settings merged with the given profile, keyed by the lower case name.
+// The keys of the settings and of all the profiles must be in knownNames.
+func readConfigSettings(path string, profile string, knownNames map[string]bool) (map[string]configSetting, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read the config file %s error message: %v", path, err)
+	}
+
+	var config ConfigFile
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		decoder := yaml.NewDecoder(bytes.NewReader(content))
+		decoder.KnownFields(true)
+		err = decoder.Decode(&config)
+		if err == io.EOF {
+			err = nil // empty file
+		}
+	case ".json":
+		decoder := json.NewDecoder(bytes.NewReader(content))
+		decoder.DisallowUnknownFields()
+		decoder.UseNumber()
+		err = decoder.Decode(&config)
+	case ".toml":
+		var metaData toml.MetaData
+		metaData, err = toml.Decode(string(content), &config)
+		if err == nil && len(metaData.Undecoded()) > 0 {
+			err = fmt.Errorf("unknown section %s", metaData.Undecoded()[0])
+		}
+	default:
+		return nil, fmt.Errorf("the config file %s must have a .yaml, .yml, .toml or .json extension", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse the config file %s error message: %v", path, err)
+	}
+	if err := checkConfigKeys(path, "settings", config.Settings, knownNames); err != nil {
+		return nil, err
+	}
+	for name, profileSettings := range config.Profiles {
+		if err := checkConfigKeys(path, "profile "+name, profileSettings, knownNames); err != nil {
+			return nil, err
+		}
+	}
+
+	settings := make(map[string]configSetting, len(config.Settings))
+	for key, value := range config.Settings {
+		settings[strings.ToLower(key)] = configSetting{key, value, "config"}
+	}
+	if profile == "" {
+		return settings, nil
+	}
+	profileSettings, ok := config.Profiles[profile]
+	if !ok {
+		names := make([]string, 0, len(config.Profiles))
+		for name := range config.Profiles {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("profile %q not found in %s, the profiles are: %s", profile, path, strings.Join(names, ", "))
+	}
+	for key, value := range profileSettings {
+		settings[strings.ToLower(key)] = configSetting{key, value, "profile " + profile}
+	}
+	return settings, nil
+}
+
+// fails on the first key of the section which is not a flag of any command, in key order
+func checkConfigKeys(path string, section string, settings map[string]interface{}, knownNames map[string]bool) error {
+	keys := make([]string, 0, len(settings))
+	for key := range settings {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if !knownNames[strings.ToLower(key)] {
+			return fmt.Errorf("unknown key %s in the %s of the config file %s, it is not a flag of any command", key, section, path)
+		}
+	}
+	return nil
+}
+
+// converts a config value to the string form of its flag
+func configValueString(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case []interface{}:
+		items := make([]string, 0, len(v))
+		for _, item := range v {
+			itemString, err := configValueString(item)
+			if err != nil {
+				return "", err
+			}
+			items = append(items, itemString)
+		}
+		return strings.Join(items, ","), nil
+	case map[string]interface{}:
+		return "", fmt.Errorf("nested settings are not supported")
+	default:
+		return fmt.Sprint(v), nil
+	}
+}
+
+// prints the effective value and source of every flag, in a form which can be pasted into a config file.
+// The config keys skipped as they are flags of other commands are listed after them.
+func printEffectiveConfig(flags *flag.FlagSet, sources map[string]string, ignoredKeys []string) {
+	fmt.Printf("# effective settings of the %s command\nsettings:\n", flags.Name())
+	flags.VisitAll(func(f *flag.Flag) {
+		switch f.Name {
+		case "Config", "Profile", "PrintConfig":
+			return
+		}
+		source := sources[f.Name]
+		if source == "" {
+			source = "default"
+		}
+		value := f.Value.String()
+		if f.Name == "Token" && value != "" {
+			value = "********" // the secret of serve
+		}
+		fmt.Printf("  %s: %q  # %s\n", f.Name, value, source)
+	})
+	if len(ignoredKeys) > 0 {
+		fmt.Printf("# not flags of the %s command, skipped: %s\n", flags.Name(), strings.Join(ignoredKeys, ", "))
+	}
+}
@@ -0,0 +1,122 @@
+//go:build windows || !windows
+// +build windows !windows
+
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// parses args into a few flags of the scan command, bound to local variables
+func parseTestFlags(t *testing.T, args ...string) (map[string]string, error) {
+	t.Helper()
+	t.Cleanup(func() { configFile, configProfile, printConfig = "", "", false })
+	configFile, configProfile, printConfig = "", "", false
+	flags := flag.NewFlagSet("scan", flag.ContinueOnError)
+	var workers, bufferSize, batchSize, maxWorkers int
+	flags.IntVar(&workers, "Workers", 64, "")
+	flags.IntVar(&bufferSize, "BufferSize", 100000, "")
+	flags.IntVar(&batchSize, "SQLBatchSize", 200, "")
+	flags.IntVar(&maxWorkers, "MaxWorkers", 512, "")
+	knownNames := commandFlagNames()
+	addConfigFlags(flags)
+	if err := flags.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := applyConfig(flags, knownNames); err != nil {
+		return nil, err
+	}
+	values := make(map[string]string)
+	flags.VisitAll(func(f *flag.Flag) {
+		values[f.Name] = f.Value.String()
+	})
+	return values, nil
+}
+
+func writeConfigFile(t *testing.T, name string, content string) string {
+	t.Helper()
+	file := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+// flags > env vars > config > defaults, the selected profile overrides the settings
+func TestConfigPrecedence(t *testing.T) {
+	yamlFile := writeConfigFile(t, "config.yaml", `
+settings:
+  workers: 8
+  BufferSize: 1000
+  SQLBatchSize: 300
+  Token: secret # a flag of serve, skipped by scan
+  Metric: age   # a flag of report top
+profiles:
+  nas:
+    SQLBatchSize: 500
+`)
+	jsonFile := writeConfigFile(t, "config.json", `{"settings": {"SQLBatchSize": 300}, "profiles": {"nas": {"SQLBatchSize": 500}}}`)
+	tomlFile := writeConfigFile(t, "config.toml", "[settings]\nSQLBatchSize = 300\n[profiles.nas]\nSQLBatchSize = 500\n")
+	t.Setenv("FOLDERINSIGHT_BUFFERSIZE", "2000")
+
+	tests := []struct {
+		args []string
+		want map[string]string
+	}{
+		{nil, map[string]string{"Workers": "64", "BufferSize": "2000", "SQLBatchSize": "200", "MaxWorkers": "512"}},
+		{[]string{"-Config", yamlFile}, map[string]string{"Workers": "8", "BufferSize": "2000", "SQLBatchSize": "300", "MaxWorkers": "512"}},
+		{[]string{"-Config", yamlFile, "-Profile", "nas", "-Workers", "16"},
+			map[string]string{"Workers": "16", "BufferSize": "2000", "SQLBatchSize": "500", "MaxWorkers": "512"}},
+		{[]string{"-Config", yamlFile, "-BufferSize", "3000"}, map[string]string{"Workers": "8", "BufferSize": "3000", "SQLBatchSize": "300"}},
+		{[]string{"-Config", jsonFile, "-Profile", "nas"}, map[string]string{"SQLBatchSize": "500"}},
+		{[]string{"-Config", tomlFile}, map[string]string{"SQLBatchSize": "300"}},
+		{[]string{"-Config", tomlFile, "-Profile", "nas"}, map[string]string{"SQLBatchSize": "500"}},
+	}
+	for _, test := range tests {
+		values, err := parseTestFlags(t, test.args...)
+		if err != nil {
+			t.Fatalf("flags %q error = %v", test.args, err)
+		}
+		for name, want := range test.want {
+			if values[name] != want {
+				t.Fatalf("flags %q set %s to %s, want %s", test.args, name, values[name], want)
+			}
+		}
+	}
+}
+
+// the keys and the environment variables which are not flags of any command are an error naming them
+func TestConfigUnknownKeys(t *testing.T) {
+	tests := []struct {
+		name     string
+		file     string
+		content  string
+		args     []string
+		wantName string
+	}{
+		{"yaml setting", "config.yaml", "settings:\n  Wrokers: 8\n", nil, "Wrokers"},
+		{"yaml section", "config.yaml", "setings:\n  Workers: 8\n", nil, "setings"},
+		{"other profile", "config.yaml", "profiles:\n  a:\n    Workers: 8\n  b:\n    BuferSize: 8\n", []string{"-Profile", "a"}, "BuferSize"},
+		{"json setting", "config.json", `{"settings": {"SQLBatchSise": 1}}`, nil, "SQLBatchSise"},
+		{"json section", "config.json", `{"profile": {}}`, nil, "profile"},
+		{"toml setting", "config.toml", "[settings]\nMaxWorker = 1\n", nil, "MaxWorker"},
+		{"toml section", "config.toml", "[setting]\nWorkers = 1\n", nil, "setting"},
+		{"missing profile", "config.yaml", "profiles:\n  a:\n    Workers: 8\n", []string{"-Profile", "b"}, `"b"`},
+	}
+	for _, test := range tests {
+		file := writeConfigFile(t, test.file, test.content)
+		_, err := parseTestFlags(t, append([]string{"-Config", file}, test.args...)...)
+		if err == nil || !strings.Contains(err.Error(), test.wantName) {
+			t.Fatalf("%s: error = %v, want one naming %s", test.name, err, test.wantName)
+		}
+	}
+
+	t.Setenv("FOLDERINSIGHT_WROKERS", "8")
+	if _, err := parseTestFlags(t); err == nil || !strings.Contains(err.Error(), "FOLDERINSIGHT_WROKERS") {
+		t.Fatalf("unknown environment variable error = %v", err)
+	}
+}
@@ -0,0 +1,187 @@
+//go:build windows || !windows
+// +build windows !windows
+
+package main
+
+import (
+	"database/sql"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+)
+
+// path relative to the scan root ?1 with slash separators, ?2 is the root as a path prefix ("" for the "." root)
+const diffRelPathSQL = `CASE WHEN Path = ?1 THEN '' ELSE ltrim(replace(substr(Path, length(?2) + 1), '\', '/'), '/') END`
+
+// entries of a report DB keyed by their path relative to the scan root, so that two scans of the
+// same folder mounted on different paths, or a scan and a scan-archive of its backup, can be compared too.
+// The write times are compared in UTC to the second, tar and zip files keep no fractions and the
+// two scans may have run in other time zones.
+var createDiffEntriesSQL = `
+    CREATE TEMP TABLE %[1]s_entries AS
+    SELECT ` + diffRelPathSQL + ` AS RelPath, ObjType,
+        CASE ObjType WHEN 'd' THEN IFNULL(TotalCalFolderSize, 0) ELSE IFNULL(FileSize, 0) END AS Size,
+        ` + utcTimeSQL("LastWriteTime") + ` AS LastWriteTime
+    FROM %[1]s.fileinfo WHERE substr(Path, 1, length(?2)) = ?2;`
+
+// runDiff implements the "diff" subcommand and returns the process exit code
+func runDiff(args []string) int {
+	var oldDBfile, newDBfile, objType string
+	var limit int
+
+	diffFlags := flag.NewFlagSet("diff", flag.ExitOnError)
+	diffFlags.StringVar(&oldDBfile, "Old", "", "Report DB of the older scan (mandatory)")
+	diffFlags.StringVar(&newDBfile, "New", "", "Report DB of the newer scan (mandatory)")
+	diffFlags.IntVar(&limit, "Limit", 20, "Max entries listed per change type, 0 prints the summary only (optional)")
+	diffFlags.StringVar(&objType, "ObjType", "", "Compare only d (folders) or f (files) (optional, default is all)")
+	if exitCode, ok := parseCommandFlags(diffFlags, args); !ok {
+		return exitCode
+	}
+
+	if oldDBfile == "" || newDBfile == "" {
+		fmt.Println("Mandatory fields are missing, check with diff -help")
+		return 2
+	}
+	var err error
+	if oldDBfile, err = reportDBPath(oldDBfile); err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	if newDBfile, err = reportDBPath(newDBfile); err != nil {
+		fmt.Println(err)
+		return 1
+	}
+
+	// both report DBs are attached read only to a private in-memory DB holding the temp tables
+	db, err := sql.Open("sqlite", "file::memory:")
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1) // attached DBs and temp tables belong to a single connection
+
+	for _, report := range []struct{ alias, DBfile string }{{"old", oldDBfile}, {"new", newDBfile}} {
+		rootPath, err := attachDiffDB(db, report.alias, report.DBfile)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		fmt.Printf("%s: %s, scan root %s\n", report.alias, report.DBfile, rootPath)
+	}
+	if err := printDiff(db, limit, objType); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+// attaches a report DB, loads its entries into the <alias>_entries temp table and returns its scan root
+func attachDiffDB(db *sql.DB, alias string, reportDBfile string) (string, error) {
+	if _, err := db.Exec(`ATTACH DATABASE ? AS `+alias+`;`, "file:"+filepath.ToSlash(reportDBfile)+"?mode=ro"); err != nil {
+		return "", fmt.Errorf("failed to open %s error message: %v", reportDBfile, err)
+	}
+	var rootPath string
+	err := db.QueryRow(`SELECT Path FROM ` + alias + `.fileinfo WHERE ObjType = 'd' ORDER BY ObjectDepth, length(Path) LIMIT 1;`).Scan(&rootPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to find the scan root of %s, error: %v", reportDBfile, err)
+	}
+	if _, err := db.Exec(fmt.Sprintf(createDiffEntriesSQL, alias), rootPath, diffPathPrefix(rootPath)); err != nil {
+		return "", fmt.Errorf("failed to read the entries of %s, error: %v", reportDBfile, err)
+	}
+	if _, err := db.Exec(`CREATE INDEX temp.idx_` + alias + `_entries ON ` + alias + `_entries (RelPath);`); err != nil {
+		return "", fmt.Errorf("failed to index the entries of %s, error: %v", reportDBfile, err)
+	}
+	return rootPath, nil
+}
+
+// returns the scan root as the prefix of the paths below it for diffRelPathSQL,
+// the paths of a scan-archive report are relative to its "." root already
+func diffPathPrefix(rootPath string) string {
+	if rootPath == "." {
+		return ""
+	}
+	return rootPath
+}
+
+// prints the summary of the added, removed and changed entries followed by the biggest of them
+func printDiff(db *sql.DB, limit int, objType string) error {
+	typeFilter := ""
+	if objType == "d" {
+		typeFilter = ` AND e.ObjType = 'd'`
+	} else if objType != "" {
+		typeFilter = ` AND e.ObjType <> 'd'`
+	}
+	changes := []struct {
+		title, query string
+	}{
+		{"Added", `SELECT e.RelPath, e.ObjType, 0 AS OldSize, e.Size AS NewSize FROM new_entries e
+			LEFT JOIN old_entries o ON o.RelPath = e.RelPath WHERE o.RelPath IS NULL` + typeFilter},
+		{"Removed", `SELECT e.RelPath, e.ObjType, e.Size AS OldSize, 0 AS NewSize FROM old_entries e
+			LEFT JOIN new_entries n ON n.RelPath = e.RelPath WHERE n.RelPath IS NULL` + typeFilter},
+		{"Changed", `SELECT e.RelPath, e.ObjType, o.Size AS OldSize, e.Size AS NewSize FROM new_entries e
+			JOIN old_entries o ON o.RelPath = e.RelPath
+			WHERE (o.Size <> e.Size OR o.ObjType <> e.ObjType OR (e.ObjType <> 'd' AND o.LastWriteTime IS NOT e.LastWriteTime))` + typeFilter},
+	}
+
+	out := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	defer out.Flush()
+
+	fmt.Fprintln(out, "Change\tEntries\tFolders\tFiles\tOld size\tNew size\tSize change")
+	for _, change := range changes {
+		var entries, folders int64
+		var oldSize, newSize sql.NullInt64
+		// the folder sizes already contain their files, only the file sizes are summed up
+		err := db.QueryRow(`SELECT COUNT(*), COUNT(*) FILTER (WHERE ObjType = 'd'),
+			SUM(OldSize) FILTER (WHERE ObjType <> 'd'), SUM(NewSize) FILTER (WHERE ObjType <> 'd')
+			FROM (`+change.query+`);`).
+			Scan(&entries, &folders, &oldSize, &newSize)
+		if err != nil {
+			return fmt.Errorf("failed to count the %s entries, error: %v", change.title, err)
+		}
+		fmt.Fprintf(out, "%s\t%d\t%d\t%d\t%s\t%s\t%s\n", change.title, entries, folders, entries-folders,
+			formatBytes(oldSize.Int64), formatBytes(newSize.Int64), formatSizeChange(newSize.Int64-oldSize.Int64))
+	}
+
+	if limit <= 0 {
+		return nil
+	}
+	for _, change := range changes {
+		rows, err := db.Query(`SELECT * FROM (`+change.query+`)
+			ORDER BY abs(NewSize - OldSize) DESC, RelPath LIMIT ?;`, limit)
+		if err != nil {
+			return fmt.Errorf("failed to list the %s entries, error: %v", change.title, err)
+		}
+		fmt.Fprintf(out, "\n%s (biggest %d):\n", change.title, limit)
+		fmt.Fprintln(out, "Type\tOld size\tNew size\tSize change\tPath")
+		for rows.Next() {
+			var relPath, entryType string
+			var oldSize, newSize int64
+			if err := rows.Scan(&relPath, &entryType, &oldSize, &newSize); err != nil {
+				rows.Close()
+				return fmt.Errorf("failed to scan a %s row: %v", change.title, err)
+			}
+			if relPath == "" {
+				relPath = "."
+			}
+			fmt.Fprintf(out, "%s\t%s\t%s\t%s\t%s\n", entryType, formatBytes(oldSize), formatBytes(newSize),
+				formatSizeChange(newSize-oldSize), relPath)
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// formats a size difference with its sign
+func formatSizeChange(delta int64) string {
+	if delta < 0 {
+		return "-" + formatBytes(-delta)
+	}
+	return "+" + formatBytes(delta)
+}
@@ -0,0 +1,60 @@
+//go:build windows || !windows
+// +build windows !windows
+
+package main
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+)
+
+// the write times of the two scans are compared in UTC to the second
+func TestDiffEntriesWriteTime(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name     string
+		old, new string
+		changed  bool
+	}{
+		{"same", "2024-01-02 03:04:05 +0000 UTC", "2024-01-02 03:04:05 +0000 UTC", false},
+		{"other zone", "2024-01-02 03:04:05.5 +0000 UTC", "2024-01-02 04:04:05 +0100 CET", false},
+		{"west of UTC", "2024-01-02 03:04:05 +0000 UTC", "2024-01-01 22:04:05.123 -0500 EST", false},
+		{"same text other zone", "2024-01-02 03:04:05 +0000 UTC", "2024-01-02 03:04:05 +0100 CET", true},
+		{"later", "2024-01-02 03:04:05 +0000 UTC", "2024-01-02 03:04:06 +0000 UTC", true},
+	}
+	for _, test := range tests {
+		files := map[string]string{"old": test.old, "new": test.new}
+		db, err := sql.Open("sqlite", ":memory:")
+		if err != nil {
+			t.Fatal(err)
+		}
+		db.SetMaxOpenConns(1)
+		for alias, writeTime := range files {
+			file := filepath.Join(dir, test.name+"_"+alias+".db")
+			writeExportTestDB(t, file, "/data/", "/data/a.txt")
+			report, err := sql.Open("sqlite", file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = report.Exec(`UPDATE fileinfo SET LastWriteTime = ?;`, writeTime)
+			report.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := attachDiffDB(db, alias, file); err != nil {
+				t.Fatal(err)
+			}
+		}
+		var changed bool
+		err = db.QueryRow(`SELECT o.LastWriteTime IS NOT n.LastWriteTime FROM old_entries o JOIN new_entries n USING (RelPath)
+			WHERE RelPath = 'a.txt';`).Scan(&changed)
+		db.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if changed != test.changed {
+			t.Fatalf("%s: %s and %s changed = %v, want %v", test.name, test.old, test.new, changed, test.changed)
+		}
+	}
+}
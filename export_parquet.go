@@ -0,0 +1,346 @@
+//go:build windows || !windows
+// +build windows !windows
+
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/parquet-go/parquet-go"
+	_ "modernc.org/sqlite" // Pure Go SQLite driver
+)
+
+// Represents one fileinfo row in the parquet export
+type ParquetRow struct {
+	ObjType            string `parquet:"ObjType,dict"`
+	Path               string `parquet:"Path"`
+	TopFolder          string `parquet:"TopFolder,dict"`
+	Extension          string `parquet:"Extension,dict"`
+	ObjectDepth        int64  `parquet:"ObjectDepth"`
+	FileSize           int64  `parquet:"FileSize"`
+	CompressedSize     int64  `parquet:"CompressedSize"`
+	ThisFolderSize     int64  `parquet:"ThisFolderSize"`
+	TotalCalFolderSize int64  `parquet:"TotalCalFolderSize"`
+	HasError           bool   `parquet:"hasError"`
+	ErrorMessage       string `parquet:"ErrorMessage,optional"`
+	ErrorCode          string `parquet:"ErrorCode,dict"`
+	ErrorStage         string `parquet:"ErrorStage,dict"`
+	Owner              string `parquet:"Owner,dict"`
+	CreationTime       int64  `parquet:"CreationTime,optional,timestamp(nanosecond)"`
+	LastWriteTime      int64  `parquet:"LastWriteTime,optional,timestamp(nanosecond)"`
+	CalLastWriteTime   int64  `parquet:"CalLastWriteTime,optional,timestamp(nanosecond)"`
+	LastAccessTime     int64  `parquet:"LastAccessTime,optional,timestamp(nanosecond)"`
+}
+
+// the partition of the scan root, the files directly inside it and the members of the archives among
+// them. No folder name contains a slash, so it cannot be taken for a top-level folder.
+const rootPartition = "/"
+
+// the partition folder of rootPartition, hive readers take it as a NULL TopFolder
+const rootPartitionDir = "__HIVE_DEFAULT_PARTITION__"
+
+// runExport implements the "export" subcommand and returns the process exit code
+func runExport(args []string) int {
+	var exportDBfile, outPath string
+	var rowGroupSize int
+	var partitionByTopFolder bool
+
+	exportFlags := flag.NewFlagSet("export", flag.ExitOnError)
+	exportFlags.StringVar(&exportDBfile, "DBfile", "", "Scan report DB file to export (mandatory)")
+	exportFlags.StringVar(&outPath, "Out", "", "Parquet output file, or output folder with -PartitionByTopFolder (mandatory)")
+	exportFlags.IntVar(&rowGroupSize, "RowGroupSize", 131072, "Max rows held in memory per parquet row group (optional)")
+	exportFlags.BoolVar(&partitionByTopFolder, "PartitionByTopFolder", false, "Write one parquet file per top-level folder (optional, default is false)")
+	addLogFlags(exportFlags)
+	if exitCode, ok := parseCommandFlags(exportFlags, args); !ok {
+		return exitCode
+	}
+
+	if exportDBfile == "" || outPath == "" {
+		fmt.Println("Mandatory fields are missing, check with export -help")
+		return 2
+	}
+	exportDBfile, err := reportDBPath(exportDBfile)
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	if rowGroupSize <= 0 {
+		fmt.Println("-RowGroupSize must be greater than 0")
+		return 2
+	}
+	level, err := parseLogFlags()
+	if err != nil {
+		fmt.Println(err)
+		return 2
+	}
+	setupLogging(os.Stderr, nil, level)
+
+	// read-only, the columns missing in the DBs of older versions are exported empty
+	db, err := openReportDB(exportDBfile)
+	if err != nil {
+		logger.Error("Failed to open the report DB", "db", exportDBfile, "error", err)
+		return 1
+	}
+	defer db.Close()
+
+	rootPath, rootDepth, err := getScanRoot(db)
+	if err != nil {
+		logger.Error("Failed to find the scan root", "db", exportDBfile, "error", err)
+		return 1
+	}
+	logger.Info("Exporting the report DB", "db", exportDBfile, "path", rootPath)
+
+	if !partitionByTopFolder {
+		if !strings.HasSuffix(outPath, ".parquet") {
+			outPath += ".parquet"
+		}
+		count, err := exportParquetFile(db, outPath, rootPath, rowGroupSize, "", rootDepth)
+		if err != nil {
+			logger.Error("Export failed", "file", outPath, "error", err)
+			return 1
+		}
+		logger.Info("Exported rows", "rows", count, "file", outPath)
+		return 0
+	}
+
+	// one file per top-level folder, in a hive style layout (TopFolder=<name>/part-0.parquet)
+	topFolders, err := getTopFolders(db, rootPath, rootDepth)
+	if err != nil {
+		logger.Error("Failed to read the top level folders", "error", err)
+		return 1
+	}
+	for _, topFolder := range topFolders {
+		if topFolder == rootPartitionDir {
+			logger.Error("A top level folder is named like the partition of the root files, export it without -PartitionByTopFolder",
+				"folder", topFolder)
+			return 1
+		}
+	}
+	topFolders = append(topFolders, rootPartition)
+	total := 0
+	for _, topFolder := range topFolders {
+		partitionDir := filepath.Join(outPath, "TopFolder="+topFolder)
+		if topFolder == rootPartition {
+			partitionDir = filepath.Join(outPath, "TopFolder="+rootPartitionDir)
+		}
+		if err := os.MkdirAll(partitionDir, 0755); err != nil {
+			logger.Error("Failed to create the partition folder", "folder", partitionDir, "error", err)
+			return 1
+		}
+		partitionFile := filepath.Join(partitionDir, "part-0.parquet")
+		count, err := exportParquetFile(db, partitionFile, rootPath, rowGroupSize, topFolder, rootDepth)
+		if err != nil {
+			logger.Error("Export failed", "file", partitionFile, "error", err)
+			return 1
+		}
+		total += count
+		logger.Info("Exported rows", "rows", count, "file", partitionFile)
+	}
+	logger.Info("Exported all partitions", "rows", total, "partitions", len(topFolders), "folder", outPath)
+	return 0
+}
+
+// returns the path and depth of the scanned root folder stored in the DB
+func getScanRoot(db *sql.DB) (string, int, error) {
+	return getTableScanRoot(db, "fileinfo")
+}
+
+// returns the scan root of a fileinfo table, named with its schema in a DB with others attached, e.g. report.fileinfo
+func getTableScanRoot(db *sql.DB, table string) (string, int, error) {
+	var rootPath string
+	var rootDepth int
+	query := `SELECT Path, ObjectDepth FROM ` + table + ` WHERE ObjType = 'd' ORDER BY ObjectDepth, length(Path) LIMIT 1;`
+	err := db.QueryRow(query).Scan(&rootPath, &rootDepth)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", 0, fmt.Errorf("no folders found in the fileinfo table")
+	} else if err != nil {
+		return "", 0, fmt.Errorf("failed to find the scan root, error: %v", err)
+	}
+	return rootPath, rootDepth, nil
+}
+
+// returns the names of the folders directly under the scan root
+func getTopFolders(db *sql.DB, rootPath string, rootDepth int) ([]string, error) {
+	query := `SELECT Path FROM fileinfo WHERE ObjType = 'd' AND ObjectDepth = ? ORDER BY Path;`
+	rows, err := db.Query(query, rootDepth+1)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query: %s error is %v", query, err)
+	}
+	defer rows.Close()
+
+	var topFolders []string
+	for rows.Next() {
+		var path string
+		if err := rows.Scan(&path); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %v", err)
+		}
+		if topFolder := topFolderOf(path, rootPath); topFolder != "" {
+			topFolders = append(topFolders, topFolder)
+		}
+	}
+	return topFolders, rows.Err()
+}
+
+// returns the first path element below the scan root, "" for the root itself
+func topFolderOf(path string, rootPath string) string {
+	if rootPath == "." {
+		path = "./" + path // scan-archive paths are relative to the root already
+	}
+	rel := strings.TrimPrefix(path, rootPath)
+	rel = strings.TrimLeft(rel, `\/`)
+	if rel == "" || rel == path || rel == "." {
+		return ""
+	}
+	if sep := strings.IndexAny(rel, `\/`); sep != -1 {
+		return rel[:sep]
+	}
+	return rel
+}
+
+// returns the path separator of the report DB paths: slashes for scan-archive reports with the "." root,
+// otherwise the separator of the scanned root, so that a DB of a windows scan can be read anywhere
+func reportPathSeparator(rootPath string) string {
+	if rootPath == "." {
+		return "/"
+	}
+	if strings.Contains(rootPath, `\`) {
+		return `\`
+	}
+	if strings.Contains(rootPath, "/") {
+		return "/"
+	}
+	return string(filepath.Separator)
+}
+
+// returns the column name when the fileinfo table has it, otherwise NULL
+func columnOrNull(columns map[string]bool, name string) string {
+	if columns[name] {
+		return name
+	}
+	return "NULL"
+}
+
+// writes the fileinfo rows to a single parquet file, optionally limited to one top-level folder or rootPartition.
+// Returns the number of rows written.
+func exportParquetFile(db *sql.DB, outFile string, rootPath string, rowGroupSize int, topFolder string, rootDepth int) (int, error) {
+	columns, err := fileinfoColumns(db)
+	if err != nil {
+		return 0, err
+	}
+	query := `SELECT ObjType, Path, ObjectDepth, FileSize, IFNULL(` + columnOrNull(columns, "CompressedSize") + `, 0), ThisFolderSize,
+	TotalCalFolderSize, hasError, ErrorMessage, ` + columnOrNull(columns, "ErrorCode") + `, ` + columnOrNull(columns, "ErrorStage") + `,
+	Owner, CreationTime, LastWriteTime, CalLastWriteTime, LastAccessTime FROM fileinfo`
+	var args []interface{}
+	if topFolder == rootPartition {
+		// the root folder itself, the files directly inside it and the members of the archives among them
+		query += ` WHERE ObjectDepth = ? OR (ObjType = 'a' AND EXISTS (SELECT 1 FROM fileinfo archive
+			WHERE archive.ObjType = 'f' AND archive.ObjectDepth = ? AND substr(fileinfo.Path, 1, length(archive.Path) + 1) = archive.Path || ?))`
+		args = append(args, rootDepth, rootDepth, reportPathSeparator(rootPath))
+	} else if topFolder != "" {
+		// the top-level folder and everything below it
+		prefix := strings.TrimRight(rootPath, `\/`) + reportPathSeparator(rootPath) + topFolder
+		if rootPath == "." {
+			prefix = topFolder
+		}
+		query += ` WHERE Path = ? OR substr(Path, 1, length(?)) = ?`
+		args = append(args, prefix, prefix+reportPathSeparator(rootPath), prefix+reportPathSeparator(rootPath))
+	}
+	query += ` ORDER BY Path;`
+
+	// the files directly in the root and the archive members below them have an empty TopFolder, like
+	// in the root partition, so the top-level folders are needed when the whole DB is exported
+	var topFolders map[string]bool
+	if topFolder == "" {
+		names, err := getTopFolders(db, rootPath, rootDepth)
+		if err != nil {
+			return 0, err
+		}
+		topFolders = make(map[string]bool, len(names))
+		for _, name := range names {
+			topFolders[name] = true
+		}
+	}
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to execute query: %s error is %v", query, err)
+	}
+	defer rows.Close()
+
+	file, err := os.Create(outFile)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create %s, error: %v", outFile, err)
+	}
+	defer file.Close()
+
+	writer := parquet.NewGenericWriter[ParquetRow](file, parquet.MaxRowsPerRowGroup(int64(rowGroupSize)))
+	batch := make([]ParquetRow, 0, 1024)
+	count := 0
+	for rows.Next() {
+		var row ParquetRow
+		var totalCalFolderSize sql.NullInt64
+		var errorMessage, errorCode, errorStage, owner sql.NullString
+		var ctime, wtime, calWtime, atime sql.NullTime
+		if err := rows.Scan(&row.ObjType, &row.Path, &row.ObjectDepth, &row.FileSize, &row.CompressedSize, &row.ThisFolderSize,
+			&totalCalFolderSize, &row.HasError, &errorMessage, &errorCode, &errorStage, &owner, &ctime, &wtime, &calWtime, &atime); err != nil {
+			return count, fmt.Errorf("failed to scan row: %v", err)
+		}
+		row.TotalCalFolderSize = totalCalFolderSize.Int64
+		row.ErrorMessage = errorMessage.String
+		row.ErrorCode = errorCode.String
+		row.ErrorStage = errorStage.String
+		row.Owner = owner.String
+		switch topFolder {
+		case rootPartition:
+		case "":
+			if name := topFolderOf(row.Path, rootPath); topFolders[name] {
+				row.TopFolder = name
+			}
+		default:
+			row.TopFolder = topFolder
+		}
+		if row.ObjType != "d" {
+			row.Extension = strings.ToLower(strings.TrimPrefix(filepath.Ext(row.Path), "."))
+		}
+		row.CreationTime = parquetTimestamp(ctime)
+		row.LastWriteTime = parquetTimestamp(wtime)
+		row.CalLastWriteTime = parquetTimestamp(calWtime)
+		row.LastAccessTime = parquetTimestamp(atime)
+
+		batch = append(batch, row)
+		if len(batch) == cap(batch) {
+			if _, err := writer.Write(batch); err != nil {
+				return count, fmt.Errorf("failed to write parquet rows to %s, error: %v", outFile, err)
+			}
+			count += len(batch)
+			batch = batch[:0]
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return count, fmt.Errorf("failed to read rows: %v", err)
+	}
+	if len(batch) > 0 {
+		if _, err := writer.Write(batch); err != nil {
+			return count, fmt.Errorf("failed to write parquet rows to %s, error: %v", outFile, err)
+		}
+		count += len(batch)
+	}
+	if err := writer.Close(); err != nil {
+		return count, fmt.Errorf("failed to close parquet writer for %s, error: %v", outFile, err)
+	}
+	return count, nil
+}
+
+// converts a DB time into unix nanoseconds, 0 (stored as null) for missing or zero times
+func parquetTimestamp(t sql.NullTime) int64 {
+	if !t.Valid || t.Time.IsZero() {
+		return 0
+	}
+	return t.Time.UnixNano()
+}
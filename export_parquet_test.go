@@ -0,0 +1,151 @@
+//go:build windows || !windows
+// +build windows !windows
+
+package main
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+// a report DB of an older version has no ErrorCode, ErrorStage and CompressedSize columns
+func TestExportOldReportDB(t *testing.T) {
+	dir := t.TempDir()
+	dbFile := filepath.Join(dir, "old.db")
+	db, err := sql.Open("sqlite", dbFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Exec(`CREATE TABLE fileinfo (ObjType TEXT, Path TEXT PRIMARY KEY UNIQUE, ObjectDepth INTEGER, FileSize INTEGER,
+		ThisFolderSize INTEGER, TotalCalFolderSize INTEGER, hasError BOOLEAN, ErrorMessage TEXT, Owner TEXT,
+		CreationTime DATETIME, LastWriteTime DATETIME, CalLastWriteTime DATETIME, LastAccessTime DATETIME);
+		INSERT INTO fileinfo (ObjType, Path, ObjectDepth, FileSize, ThisFolderSize, TotalCalFolderSize, hasError)
+		VALUES ('d', '/data', 2, 0, 5, 5, 0), ('f', '/data/a.txt', 3, 5, 0, 0, 1);`)
+	db.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(dbFile, 0444); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err = openReportDB(dbFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	outFile := filepath.Join(dir, "old.parquet")
+	count, err := exportParquetFile(db, outFile, "/data", 1024, "", 2)
+	if err != nil || count != 2 {
+		t.Fatalf("exportParquetFile() = %d, %v, want 2 rows", count, err)
+	}
+	rows, err := parquet.ReadFile[ParquetRow](outFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 2 || rows[1].Path != "/data/a.txt" || rows[1].Extension != "txt" || rows[1].ErrorCode != "" || rows[1].CompressedSize != 0 {
+		t.Fatalf("exported rows = %+v", rows)
+	}
+	columns, err := fileinfoColumns(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if columns["ErrorCode"] || columns["CompressedSize"] {
+		t.Fatal("the export added columns to the report DB")
+	}
+}
+
+// writes a report DB of the paths below /data, the folders end with a slash
+func writeExportTestDB(t *testing.T, file string, paths ...string) {
+	t.Helper()
+	db, err := sql.Open("sqlite", file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	_, err = db.Exec(`CREATE TABLE fileinfo (ObjType TEXT, Path TEXT PRIMARY KEY UNIQUE, ObjectDepth INTEGER, FileSize INTEGER,
+		CompressedSize INTEGER, ThisFolderSize INTEGER, TotalCalFolderSize INTEGER, hasError BOOLEAN, ErrorMessage TEXT,
+		ErrorCode TEXT, ErrorStage TEXT, Owner TEXT, CreationTime DATETIME, LastWriteTime DATETIME, CalLastWriteTime DATETIME,
+		LastAccessTime DATETIME);`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range paths {
+		objType, depth := "f", strings.Count(path, "/")-1 // a file has the depth of its folder
+		if strings.HasSuffix(path, "/") {
+			objType, path = "d", strings.TrimSuffix(path, "/")
+		}
+		if _, err := db.Exec(`INSERT INTO fileinfo (ObjType, Path, ObjectDepth, FileSize, ThisFolderSize, TotalCalFolderSize, hasError)
+			VALUES (?, ?, ?, 1, 0, 0, 0);`,
+			objType, path, depth); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// a top-level folder named _root is a partition of its own, the files of the root go to the default partition
+func TestExportPartitions(t *testing.T) {
+	dir := t.TempDir()
+	dbFile := filepath.Join(dir, "parts.db")
+	writeExportTestDB(t, dbFile, "/data/", "/data/top.txt", "/data/_root/", "/data/_root/x.txt", "/data/b/", "/data/b/y.txt")
+	outDir := filepath.Join(dir, "out")
+	if code := runExport([]string{"-DBfile", dbFile, "-Out", outDir, "-PartitionByTopFolder=true"}); code != 0 {
+		t.Fatalf("runExport() = %d, want 0", code)
+	}
+	tests := []struct {
+		partition     string
+		wantPaths     []string
+		wantTopFolder string
+	}{
+		{rootPartitionDir, []string{"/data", "/data/top.txt"}, ""},
+		{"_root", []string{"/data/_root", "/data/_root/x.txt"}, "_root"},
+		{"b", []string{"/data/b", "/data/b/y.txt"}, "b"},
+	}
+	for _, test := range tests {
+		rows, err := parquet.ReadFile[ParquetRow](filepath.Join(outDir, "TopFolder="+test.partition, "part-0.parquet"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var paths []string
+		for _, row := range rows {
+			paths = append(paths, row.Path)
+			if row.TopFolder != test.wantTopFolder {
+				t.Fatalf("%s has TopFolder %q in partition %s, want %q", row.Path, row.TopFolder, test.partition, test.wantTopFolder)
+			}
+		}
+		if !reflect.DeepEqual(paths, test.wantPaths) {
+			t.Fatalf("partition %s = %q, want %q", test.partition, paths, test.wantPaths)
+		}
+	}
+
+	// a single file has the same TopFolder values
+	single := filepath.Join(dir, "single.parquet")
+	if code := runExport([]string{"-DBfile", dbFile, "-Out", single}); code != 0 {
+		t.Fatalf("runExport() = %d, want 0", code)
+	}
+	rows, err := parquet.ReadFile[ParquetRow](single)
+	if err != nil {
+		t.Fatal(err)
+	}
+	topFolders := map[string]string{}
+	for _, row := range rows {
+		topFolders[row.Path] = row.TopFolder
+	}
+	if want := map[string]string{"/data": "", "/data/top.txt": "", "/data/_root": "_root", "/data/_root/x.txt": "_root",
+		"/data/b": "b", "/data/b/y.txt": "b"}; !reflect.DeepEqual(topFolders, want) {
+		t.Fatalf("TopFolder of the single file = %v, want %v", topFolders, want)
+	}
+
+	// a folder named like the default partition cannot be told apart from the root files
+	clashFile := filepath.Join(dir, "clash.db")
+	writeExportTestDB(t, clashFile, "/data/", "/data/"+rootPartitionDir+"/")
+	if code := runExport([]string{"-DBfile", clashFile, "-Out", filepath.Join(dir, "clash"), "-PartitionByTopFolder=true"}); code != 1 {
+		t.Fatalf("runExport() with a %s folder = %d, want 1", rootPartitionDir, code)
+	}
+}
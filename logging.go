@@ -0,0 +1,119 @@
+//go:build windows || !windows
+// +build windows !windows
+
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+var (
+	logLevel  string // -LogLevel
+	logFormat string // -LogFormat
+	// logger writes to the console and to the log file of the run, fileLogger only to the log file.
+	// The failed entries are logged with the path, depth, stage, error_code and error attributes.
+	logger     = slog.New(newLogHandler(os.Stderr, slog.LevelInfo, "text"))
+	fileLogger = logger
+)
+
+// registers -LogLevel and -LogFormat on the flag set of a command
+func addLogFlags(flags *flag.FlagSet) {
+	flags.StringVar(&logLevel, "LogLevel", "info", "Lowest level logged: debug, info, warn or error, debug goes to the log file only (optional)")
+	flags.StringVar(&logFormat, "LogFormat", "text", "Format of the log lines: text (key=value) or json (optional)")
+}
+
+// checks -LogLevel and -LogFormat and returns the level, the -debug flag of the older versions is -LogLevel=debug
+func parseLogFlags() (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(logLevel)); err != nil {
+		return level, fmt.Errorf("invalid -LogLevel %q, use debug, info, warn or error", logLevel)
+	}
+	if debug {
+		level = slog.LevelDebug
+	}
+	logFormat = strings.ToLower(logFormat)
+	if logFormat != "text" && logFormat != "json" {
+		return level, fmt.Errorf("invalid -LogFormat %q, use text or json", logFormat)
+	}
+	return level, nil
+}
+
+// sets up logger for the console and logFile, and fileLogger for logFile only. The console gets
+// info and above even at the debug level, so that the details don't flood it. logFile may be nil.
+func setupLogging(console io.Writer, logFile io.Writer, level slog.Level) {
+	if logFile == nil {
+		logger = slog.New(newLogHandler(console, level, logFormat))
+		fileLogger = logger
+		return
+	}
+	fileHandler := newLogHandler(logFile, level, logFormat)
+	logger = slog.New(teeHandler{newLogHandler(console, max(level, slog.LevelInfo), logFormat), fileHandler})
+	fileLogger = slog.New(fileHandler)
+}
+
+// returns the text or json handler of -LogFormat, with the source as file:line like log.Lshortfile
+func newLogHandler(out io.Writer, level slog.Level, format string) slog.Handler {
+	options := &slog.HandlerOptions{
+		AddSource: true,
+		Level:     level,
+		ReplaceAttr: func(groups []string, attr slog.Attr) slog.Attr {
+			if source, ok := attr.Value.Any().(*slog.Source); ok && attr.Key == slog.SourceKey && len(groups) == 0 {
+				return slog.String(slog.SourceKey, filepath.Base(source.File)+":"+strconv.Itoa(source.Line))
+			}
+			return attr
+		},
+	}
+	if format == "json" {
+		return slog.NewJSONHandler(out, options)
+	}
+	return slog.NewTextHandler(out, options)
+}
+
+// slog.Handler passing every record to several handlers, each one with its own level
+type teeHandler []slog.Handler
+
+func (handlers teeHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	for _, handler := range handlers {
+		if handler.Enabled(ctx, level) {
+			return true
+		}
+	}
+	return false
+}
+
+func (handlers teeHandler) Handle(ctx context.Context, record slog.Record) error {
+	var errs []error
+	for _, handler := range handlers {
+		if handler.Enabled(ctx, record.Level) {
+			if err := handler.Handle(ctx, record.Clone()); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (handlers teeHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	withAttrs := make(teeHandler, len(handlers))
+	for i, handler := range handlers {
+		withAttrs[i] = handler.WithAttrs(attrs)
+	}
+	return withAttrs
+}
+
+func (handlers teeHandler) WithGroup(name string) slog.Handler {
+	withGroup := make(teeHandler, len(handlers))
+	for i, handler := range handlers {
+		withGroup[i] = handler.WithGroup(name)
+	}
+	return withGroup
+}
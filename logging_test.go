@@ -0,0 +1,71 @@
+//go:build windows || !windows
+// +build windows !windows
+
+package main
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+// the console gets info and above, the log file gets -LogLevel and above, the attributes and the groups reach both
+func TestSetupLogging(t *testing.T) {
+	quietGlobals(t)
+	savedFormat := logFormat
+	t.Cleanup(func() { logFormat = savedFormat })
+	logFormat = "text"
+
+	tests := []struct {
+		level       slog.Level
+		wantConsole []string
+		wantFile    []string
+	}{
+		{slog.LevelInfo, []string{"info line", "warn line"}, []string{"info line", "warn line", "file only line"}},
+		{slog.LevelDebug, []string{"info line", "warn line"}, []string{"debug line", "info line", "warn line", "file only line"}},
+		{slog.LevelWarn, []string{"warn line"}, []string{"warn line"}},
+	}
+	for _, test := range tests {
+		var console, file bytes.Buffer
+		setupLogging(&console, &file, test.level)
+		logger.Debug("debug line")
+		logger.Info("info line")
+		logger.Warn("warn line")
+		fileLogger.Info("file only line")
+		for _, sink := range []struct {
+			name string
+			out  string
+			want []string
+		}{{"console", console.String(), test.wantConsole}, {"log file", file.String(), test.wantFile}} {
+			var messages []string
+			for _, line := range strings.Split(strings.TrimSuffix(sink.out, "\n"), "\n") {
+				if start := strings.Index(line, `msg="`); start >= 0 {
+					messages = append(messages, strings.SplitN(line[start+len(`msg="`):], `"`, 2)[0])
+				}
+			}
+			if strings.Join(messages, ",") != strings.Join(sink.want, ",") {
+				t.Fatalf("level %v: the %s got %q, want %q", test.level, sink.name, messages, sink.want)
+			}
+		}
+	}
+
+	var console, file bytes.Buffer
+	setupLogging(&console, &file, slog.LevelInfo)
+	logger.With("run", "nightly").WithGroup("entry").Info("failed", "path", "/data/a", "depth", 2)
+	for name, out := range map[string]string{"console": console.String(), "log file": file.String()} {
+		for _, want := range []string{"run=nightly", "entry.path=/data/a", "entry.depth=2", "source=logging_test.go:"} {
+			if !strings.Contains(out, want) {
+				t.Fatalf("the %s has no %s in %q", name, want, out)
+			}
+		}
+	}
+
+	// without a log file the console gets -LogLevel
+	console.Reset()
+	setupLogging(&console, nil, slog.LevelDebug)
+	logger.Debug("debug line")
+	if fileLogger != logger || !strings.Contains(console.String(), `msg="debug line"`) {
+		t.Fatalf("the console without a log file got %q", console.String())
+	}
+}
@@ -9,15 +9,14 @@ import (
 	"errors"
 	"flag"
 	"fmt"
-	"io"
-	"log"
+	"log/slog"
 	"os"
 	"path/filepath"
-	"runtime"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/abhilash945/FolderInsight/pkg/scanner"
 	_ "modernc.org/sqlite" // Pure Go SQLite driver
 )
 
@@ -26,69 +25,124 @@ var (
 	dirPath                string
 	DBfile                 string
 	updateErrorOnly        bool
+	resume                 bool
 	debug                  bool
 	updateWindowsFileOwner bool
+	createIndexes          bool
+	progressInterval       time.Duration
+	estimateFrom           string
 	channelSize            int
 	insertionBatchSizeSQL  = 200 // Number of rows to insert in one query
-	infoMultiLogger        *log.Logger
-	errorMultiLogger       *log.Logger
-	infoFileLogger         *log.Logger
-	// readFolderCounter     int32                       // Atomic counter for active goroutines
-	sem = make(chan struct{}, 8000) // semaphore used to set max goroutines
+	droppedRowCount        int   // rows which couldn't be written to the DB, set by writeMetaDataToSQliteDB
+	workers                int   // number of readFolder workers
+	largeDirThreshold      int   // folders with more entries are reported, 0 disables it
+	autoTune               bool
+	minWorkers             int
+	maxWorkers             int
+	retryErrorCodes        []string // error codes retried by -UpdateErrorOnly, all when empty
+	maxOpsPerSec           float64
+	maxReadBytesPerSec     string
+	rateSchedule           string
+	fsTimeout              time.Duration // 0 disables the filesystem call timeouts
+	expandArchives         bool
+	maxArchiveDepth        int
+	maxArchiveSize         string
 )
 
 // Represents the scan data gathered and stored to DB
-type ObjectInfo struct {
-	ObjType            string // d- directory, f- file, l- link, o- other
-	Path               string
-	ObjectDepth        int
-	FileSize           int //size of a file
-	ThisFolderSize     int //folder size with all the containing files only
-	TotalCalFolderSize int //Total folder size(with all the containing files & subfolders)
-	hasError           bool
-	ErrorMessage       string
-	Owner              string
-	CreationTime       time.Time
-	LastWriteTime      time.Time
-	CalLastWriteTime   time.Time
-	LastAccessTime     time.Time
-	// CreatedBy        string // Placeholder, platform-specific implementation needed
-	// LastModifiedBy   string // Placeholder, platform-specific implementation needed
-	// SubObjects       []ObjectInfo
-	// NumSubFiles      int
-	// NumSubFolders    int
-}
+type ObjectInfo = scanner.ObjectInfo
 
 // Represents the failed folder list if updateErrorOnly is enabled
-type ErrorObjectInfo struct {
-	Path        string
-	ObjectDepth int
-}
-
-// FolderInfoCal struct holds folder information
-type FolderInfoCal struct {
-	TotalCalFolderSize int
-	CalLastWriteTime   time.Time
-}
+type ErrorObjectInfo = scanner.Folder
 
 // starts here
 func main() {
+	os.Exit(runCommand(os.Args[1:]))
+}
+
+// implements the "scan" and "retry" subcommands, and the flag only invocation of the older versions.
+// Returns the process exit code.
+func runScan(command string, args []string) int {
 	preCheckErrors := false //assume as no precheck errors
 	// Define flags
-	flag.StringVar(&dirPath, "Path", "", "Folder to scan (mandatory)")
-	flag.StringVar(&DBfile, "DBfile", "", "Result report DB file (mandatory)")
-	flag.IntVar(&channelSize, "BufferSize", 100000, "meta data buffer size (optional)")
-	flag.IntVar(&insertionBatchSizeSQL, "SQLBatchSize", 200, "DB batch size for buffered insertions (optional)")
-	flag.BoolVar(&debug, "debug", false, "Enable debug logging (optional, default is false)")
-	flag.BoolVar(&updateErrorOnly, "UpdateErrorOnly", false, "Run scan only on failed directories (optional, default is false)")
-	flag.BoolVar(&updateWindowsFileOwner, "UpdateWindowsFileOwner", false, "Update the file owner or creater name (optional, default is false, applicable in windows only)")
-	// Parse provided flags
-	flag.Parse()
+	scanFlags := flag.NewFlagSet(command, flag.ExitOnError)
+	scanFlags.Usage = func() {
+		fmt.Fprintf(scanFlags.Output(), "Usage of %s %s:\n", filepath.Base(os.Args[0]), command)
+		scanFlags.PrintDefaults()
+	}
+	scanFlags.StringVar(&dirPath, "Path", "", "Folder to scan (mandatory)")
+	scanFlags.StringVar(&DBfile, "DBfile", "", "Result report DB file (mandatory)")
+	scanFlags.IntVar(&channelSize, "BufferSize", 100000, "meta data buffer size (optional)")
+	scanFlags.IntVar(&insertionBatchSizeSQL, "SQLBatchSize", 200, "DB batch size for buffered insertions (optional)")
+	scanFlags.BoolVar(&debug, "debug", false, "Enable debug logging, the same as -LogLevel=debug (optional, default is false)")
+	addLogFlags(scanFlags)
+	if command != "retry" {
+		scanFlags.BoolVar(&updateErrorOnly, "UpdateErrorOnly", false, "Run scan only on failed directories (optional, default is false)")
+		scanFlags.BoolVar(&resume, "Resume", false, "Continue an interrupted scan from its checkpoint (optional, default is false)")
+	}
+	scanFlags.Var((*errorCodeList)(&retryErrorCodes), "RetryErrorCodes", "Comma separated error codes retried by -UpdateErrorOnly, e.g. EACCES,ETIMEDOUT (optional, default is all)")
+	scanFlags.BoolVar(&updateWindowsFileOwner, "UpdateWindowsFileOwner", false, "Update the file owner or creater name (optional, default is false, applicable in windows only)")
+	scanFlags.BoolVar(&createIndexes, "CreateIndexes", true, "Create the secondary indexes on the report DB after the scan (optional, default is true)")
+	scanFlags.IntVar(&workers, "Workers", 64, "Number of folders read in parallel (optional)")
+	scanFlags.BoolVar(&autoTune, "AutoTune", false, "Adjust the number of active workers to the storage latency (optional, default is false)")
+	scanFlags.IntVar(&minWorkers, "MinWorkers", 4, "Lowest number of active workers with -AutoTune (optional)")
+	scanFlags.IntVar(&maxWorkers, "MaxWorkers", 512, "Highest number of active workers with -AutoTune (optional)")
+	scanFlags.Float64Var(&maxOpsPerSec, "MaxOpsPerSec", 0, "Max filesystem calls (stat, readdir) per second, 0 is unlimited (optional)")
+	scanFlags.StringVar(&maxReadBytesPerSec, "MaxReadBytesPerSec", "0", "Max file content read per second, e.g. 20MB, 0 is unlimited (optional)")
+	scanFlags.StringVar(&rateSchedule, "RateSchedule", "", "Time-of-day rate limits, e.g. 08:00-18:00=200/10MB,18:00-08:00=0/0 (optional)")
+	scanFlags.IntVar(&largeDirThreshold, "LargeDirThreshold", 100000, "Report folders with more entries than this, 0 disables it (optional)")
+	scanFlags.DurationVar(&fsTimeout, "FSTimeout", 0, "Give up on a stat/readdir call after this long, e.g. 30s, 0 waits forever (optional)")
+	scanFlags.BoolVar(&expandArchives, "ExpandArchives", false, "Record the files inside .zip, .tar and .tar.gz files as archive member rows (optional, default is false)")
+	scanFlags.IntVar(&maxArchiveDepth, "MaxArchiveDepth", scanner.DefaultMaxArchiveDepth, "Archive nesting levels expanded by -ExpandArchives, 1 skips the archives inside archives (optional)")
+	scanFlags.StringVar(&maxArchiveSize, "MaxArchiveSize", "10GB", "Max uncompressed size of the members listed from one archive, e.g. 2GB (optional)")
+	scanFlags.DurationVar(&progressInterval, "ProgressInterval", 10*time.Second, "Progress report interval, 0 disables it (optional)")
+	scanFlags.StringVar(&estimateFrom, "EstimateFrom", "", "Previous report DB of the same Path used to estimate the remaining time (optional)")
+	scanFlags.StringVar(&metricsAddr, "MetricsAddr", "", "Serve the live scan metrics for Prometheus on this address, e.g. :9150 for http://host:9150/metrics (optional)")
+	scanFlags.StringVar(&metricsTextfile, "MetricsTextfile", "", "Write the folder sizes and the scan duration to this node_exporter textfile after the scan, e.g. /var/lib/node_exporter/folderinsight.prom (optional)")
+	// Parse provided flags, then fill the others from the environment and the config file
+	if exitCode, ok := parseCommandFlags(scanFlags, args); !ok {
+		return exitCode
+	}
+	if command == "retry" {
+		updateErrorOnly = true
+	}
 
 	//check if the mandatory fields are missing
 	if dirPath == "" || DBfile == "" {
 		fmt.Println("Mandatory fields are missing, check with -help")
-		os.Exit(0)
+		return 2
+	}
+	if workers < 1 {
+		fmt.Println("-Workers must be at least 1")
+		return 2
+	}
+	if autoTune && (minWorkers < 1 || maxWorkers < minWorkers) {
+		fmt.Println("-MinWorkers must be at least 1 and not above -MaxWorkers")
+		return 2
+	}
+	readBytesPerSec, err := parseByteSize(maxReadBytesPerSec)
+	if err != nil || readBytesPerSec < 0 || maxOpsPerSec < 0 {
+		fmt.Println("-MaxOpsPerSec and -MaxReadBytesPerSec must be 0 or positive numbers")
+		return 2
+	}
+	archiveSizeLimit, err := parseByteSize(maxArchiveSize)
+	if err != nil || archiveSizeLimit < 1 || maxArchiveDepth < 1 {
+		fmt.Println("-MaxArchiveDepth and -MaxArchiveSize must be positive")
+		return 2
+	}
+	rateWindows, err := parseRateSchedule(rateSchedule)
+	if err != nil {
+		fmt.Println("Invalid -RateSchedule:", err)
+		return 2
+	}
+	if updateErrorOnly && resume {
+		fmt.Println("-UpdateErrorOnly and -Resume cannot be used together")
+		return 2
+	}
+	level, err := parseLogFlags()
+	if err != nil {
+		fmt.Println(err)
+		return 2
 	}
 
 	//check if the directory is a valid one
@@ -111,11 +165,22 @@ func main() {
 			fmt.Println("The DBfile", DBfile, "cannot be a directory!")
 			preCheckErrors = true
 		} else {
-			if !updateErrorOnly {
+			if !updateErrorOnly && !resume {
 				fmt.Println("Looks like the DBfile", DBfile, "already exists.")
-				fmt.Println("Either run the report to a new file or run with -updateErrorOnly=true options")
+				fmt.Println("Either run the report to a new file or run with -updateErrorOnly=true or -Resume=true options")
 				preCheckErrors = true
 			}
+			// a retry on an interrupted scan would roll up the partial folder sizes, the scan must be resumed first
+			if updateErrorOnly {
+				if folders, err := pendingCheckpointFolders(DBfile); err != nil {
+					fmt.Println("Cannot read the checkpoint of", DBfile, "error message:", err)
+					preCheckErrors = true
+				} else if folders > 0 {
+					fmt.Println("The DBfile", DBfile, "has", folders, "folders left in the checkpoint of an interrupted scan.")
+					fmt.Println("Run with -Resume=true to complete the scan before retrying the errors.")
+					preCheckErrors = true
+				}
+			}
 		}
 	} else if errors.Is(err, os.ErrNotExist) {
 		if updateErrorOnly {
@@ -123,6 +188,11 @@ func main() {
 			fmt.Println("Hence, -updateErrorOnly=false must be defined or this parameter must be omitted.")
 			preCheckErrors = true
 		}
+		if resume {
+			fmt.Println("Looks like the DBfile", DBfile, "doesn't exists.")
+			fmt.Println("Hence, -Resume=false must be defined or this parameter must be omitted.")
+			preCheckErrors = true
+		}
 	} else {
 		fmt.Println("Error while checking", DBfile, "error message:", err)
 		preCheckErrors = true
@@ -130,67 +200,109 @@ func main() {
 
 	// exit if any error
 	if preCheckErrors {
-		os.Exit(0)
+		return 2
 	}
 
-	logFileName := strings.TrimSuffix(DBfile, ".db")               //log file name to store all the current logs
-	timestamp := time.Now().Format("20060102_150405")              //Example format: 20240811_103045
-	logFileName = fmt.Sprintf("%s_%s.log", logFileName, timestamp) //Append the current timestamp and .log suffix
-
-	// Initialize loggers
-	logFile, err := os.OpenFile(logFileName, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
+	logFile, logFileName, err := openScanLog(DBfile, level)
 	if err != nil {
-		fmt.Printf("Failed to open log file %s: %v", logFileName, err)
-		os.Exit(0)
+		fmt.Println(err)
+		return 1
 	}
 	defer logFile.Close()
-	// Create a multi-writer to write to both file and console
-	multiWriter := io.MultiWriter(os.Stdout, logFile)
-	// Create the logger that writes to both file and console
-	infoMultiLogger = log.New(multiWriter, "INFO: ", log.Ldate|log.Ltime|log.Lshortfile)
-	errorMultiLogger = log.New(multiWriter, "ERR: ", log.Ldate|log.Ltime|log.Lshortfile)
-	// Create the logger that writes to only file
-	infoFileLogger = log.New(logFile, "INFO: ", log.Ldate|log.Ltime|log.Lshortfile)
-
-	infoMultiLogger.Println("Basic checks completed")
-	infoMultiLogger.Println("Scanning", dirPath, "folder.")
-	infoMultiLogger.Println("SQL report DB filename", DBfile)
-	infoMultiLogger.Println("Scan only on the error folders?", updateErrorOnly)
-	infoMultiLogger.Println("Is debugging enabled?", debug)
-	infoMultiLogger.Println("Is UpdateWindowsFileOwner enabled?", updateWindowsFileOwner)
+
+	logger.Info("Basic checks completed")
+	if configFile != "" {
+		logger.Info("Settings read from the config file", "config", configFile, "profile", configProfile)
+	}
+	logger.Info("Scanning folder", "path", dirPath, "db", DBfile, "update_error_only", updateErrorOnly, "resume", resume)
+	if len(retryErrorCodes) > 0 {
+		logger.Info("Error codes to retry", "error_codes", strings.Join(retryErrorCodes, ","))
+	}
+	logger.Info("Scan settings", "log_level", level.String(), "update_windows_file_owner", updateWindowsFileOwner,
+		"create_indexes", createIndexes, "workers", workers, "fs_timeout", fsTimeout.String())
+	logger.Info("Rate limits, 0 is unlimited", "max_ops_per_sec", maxOpsPerSec, "max_read_bytes_per_sec", readBytesPerSec,
+		"scheduled_windows", len(rateWindows))
+	if autoTune {
+		logger.Info("Auto tuning the active workers", "min_workers", minWorkers, "max_workers", maxWorkers)
+	}
+	if expandArchives {
+		logger.Info("Expanding archives", "max_archive_depth", maxArchiveDepth, "max_archive_size", archiveSizeLimit)
+	}
 	fmt.Println("Logs will be saved to", logFileName, "file.")
-	timestamp = time.Now().Format("20060102_150405") //reused the previous timestamp var as its not needed anymore
-	infoMultiLogger.Println("Scan start time:", timestamp)
+	startTime := time.Now()
+	timestamp := startTime.Format("20060102_150405")
+	logger.Info("Scan started", "start_time", timestamp)
 
+	runMode := "scan"
+	if updateErrorOnly {
+		runMode = "retry"
+	} else if resume {
+		runMode = "resume"
+	}
+	// the remaining time is estimated from the entry count of a previous complete scan
+	var expectedEntries int64
+	if runMode == "scan" && estimateFrom != "" {
+		if !strings.HasSuffix(estimateFrom, ".db") {
+			estimateFrom += ".db"
+		}
+		expectedEntries, err = previousScanEntries(estimateFrom, dirPath)
+		if err != nil {
+			logger.Error("Failed to read the previous scan", "db", estimateFrom, "error", err)
+		} else if expectedEntries == 0 {
+			logger.Info("No previous complete scan found", "path", dirPath, "db", estimateFrom)
+		} else {
+			logger.Info("Previous scan entries used for the ETA", "path", dirPath, "entries", expectedEntries)
+		}
+	}
 	FSdata := make(chan ObjectInfo, channelSize) //channel for new data
-	infoMultiLogger.Printf("buffered channel of %d size created", channelSize)
+	logger.Info("Buffered channel created", "size", channelSize)
+	if metricsAddr != "" {
+		metricsServer, err := startMetricsServer(metricsAddr, func() int { return len(FSdata) }, channelSize, startTime)
+		if err != nil {
+			logger.Error("Failed to start the metrics endpoint", "error", err)
+			return 1
+		}
+		defer metricsServer.Close()
+		logger.Info("Serving the scan metrics on /metrics", "addr", metricsAddr)
+	}
+	runID, err := startScanRun(dirPath, runMode, startTime)
+	if err != nil {
+		logger.Error("Failed to record the scan run", "error", err)
+	}
 
-	// Create a context with cancellation
+	// Create a context with cancellation, cancelled by the DB writer on failures or by SIGINT/SIGTERM
 	ctx, cancel := context.WithCancel(context.Background())
+	handleShutdownSignals(cancel)
 
-	var wg sync.WaitGroup
+	var startFolders []ErrorObjectInfo
+	var errorFiles []ErrorFileInfo
 	if updateErrorOnly {
-		infoMultiLogger.Println("Running scan on error folders only")
+		logger.Info("Running scan on error folders only")
 		var errorFolders []ErrorObjectInfo
 		//block created to close the DB connection
 		{
 			// Open the database connection
 			db, err := sql.Open("sqlite", DBfile)
 			if err != nil {
-				errorMultiLogger.Println(err)
-				return
+				logger.Error("Failed to open the report DB", "db", DBfile, "error", err)
+				return 1
 			}
 			db.Exec("PRAGMA journal_mode=WAL;")
 			defer db.Close()
+			if err := addMissingFileinfoColumns(db); err != nil {
+				logger.Error("Failed to update the report DB", "db", DBfile, "error", err)
+				return 1
+			}
 
-			// Prepare the SQL query
-			query := `SELECT Path, ObjectDepth FROM fileinfo WHERE ObjType = 'd' and hasError = '1';`
+			// Prepare the SQL query, optionally limited to some error codes
+			codeFilter, args := errorCodeFilter()
+			query := `SELECT Path, ObjectDepth FROM fileinfo WHERE ObjType = 'd' and hasError = '1'` + codeFilter + `;`
 
 			// Execute the query
-			rows, err := db.Query(query)
+			rows, err := db.Query(query, args...)
 			if err != nil {
-				errorMultiLogger.Println("failed to execute query:", query, "error is ", err)
-				return
+				logger.Error("Failed to execute query", "query", query, "error", err)
+				return 1
 			}
 			defer rows.Close()
 
@@ -199,140 +311,172 @@ func main() {
 				// Scan each row into the FileInfo struct
 				err := rows.Scan(&errorFolder.Path, &errorFolder.ObjectDepth)
 				if err != nil {
-					errorMultiLogger.Println("Failed to scan a row:", err)
-					return
+					logger.Error("Failed to scan a row", "error", err)
+					return 1
 				}
 				// Add to the result slice
 				errorFolders = append(errorFolders, errorFolder)
 			}
+
+			// the failed files outside of those folders are stat'ed again on their own
+			errorFiles, err = readErrorFiles(db, errorFolders)
+			if err != nil {
+				logger.Error("Failed to read the error files", "error", err)
+				return 1
+			}
 		}
-		infoMultiLogger.Println("Identified list of error folders are:")
+		logger.Info("Identified the error folders", "folders", len(errorFolders))
 		for _, error_folder := range errorFolders {
-			infoMultiLogger.Printf("%v", error_folder)
-			wg.Add(1)
-			// atomic.AddInt32(&readFolderCounter, 1) // Increment the counter when a goroutine starts
-			go readFolder(ctx, error_folder.Path, FSdata, error_folder.ObjectDepth, &wg)
+			logger.Info("Error folder", "path", error_folder.Path, "depth", error_folder.ObjectDepth)
+		}
+		startFolders = errorFolders
+		logger.Info("Identified the error files outside of those folders", "files", len(errorFiles))
+	} else if resume {
+		logger.Info("Resuming the scan from the checkpoint")
+		db, err := sql.Open("sqlite", DBfile)
+		if err != nil {
+			logger.Error("Failed to open the report DB", "db", DBfile, "error", err)
+			return 1
+		}
+		checkpointFolders, err := readCheckpoint(db)
+		db.Close()
+		if err != nil {
+			logger.Error("Failed to read the checkpoint", "error", err)
+			return 1
+		}
+		if len(checkpointFolders) == 0 {
+			logger.Info("The checkpoint is empty, the scan is already complete")
+			return 0
 		}
+		logger.Info("Continuing from the checkpoint folders", "folders", len(checkpointFolders))
+		for _, folder := range checkpointFolders {
+			logger.Debug("Checkpoint folder", "path", folder.Path, "depth", folder.ObjectDepth)
+		}
+		startFolders = checkpointFolders
+	} else {
+		startFolders = []ErrorObjectInfo{{Path: dirPath, ObjectDepth: 1}}
+	}
+	opsLimiter.SetRate(maxOpsPerSec)
+	readBytesLimiter.SetRate(float64(readBytesPerSec))
+	workersDone := make(chan struct{})
+	if len(rateWindows) > 0 {
+		go followRateSchedule(rateWindows, maxOpsPerSec, float64(readBytesPerSec), workersDone)
+	}
+	scanOptions := scanner.Options{
+		Workers:           workers,
+		AutoTune:          autoTune,
+		MinWorkers:        minWorkers,
+		MaxWorkers:        maxWorkers,
+		FSTimeout:         fsTimeout,
+		LargeDirThreshold: largeDirThreshold,
+		FileOwner:         updateWindowsFileOwner,
+		ExpandArchives:    expandArchives,
+		MaxArchiveDepth:   maxArchiveDepth,
+		MaxArchiveSize:    archiveSizeLimit,
+		OpsLimiter:        opsLimiter,
+		ReadLimiter:       readBytesLimiter,
+		Counters:          &counters.Counters,
+		Logger:            logger,
+	}
+	folderScanner := scanner.New(scanOptions)
+	if autoTune {
+		logger.Info("Starting the readFolder workers", "workers", maxWorkers, "active_workers", min(max(workers, minWorkers), maxWorkers))
 	} else {
-		infoMultiLogger.Println("starting the 1st readFolder goroutine")
-		wg.Add(1)
-		// atomic.AddInt32(&readFolderCounter, 1) // Increment the counter when a goroutine starts
-		go readFolder(ctx, dirPath, FSdata, 1, &wg)
+		logger.Info("Starting the readFolder workers", "workers", workers)
+	}
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		folderScanner.Scan(ctx, FSdata, startFolders...)
+	}()
+	if len(errorFiles) > 0 {
+		retryErrorFiles(ctx, folderScanner, errorFiles, FSdata, &wg)
 	}
 
-	infoMultiLogger.Println("Starting the writeMetaDataToSQliteDB goroutine")
+	logger.Info("Starting the writeMetaDataToSQliteDB goroutine")
 	var wg2 sync.WaitGroup
 	wg2.Add(1)
 	go writeMetaDataToSQliteDB(FSdata, &wg2, cancel, DBfile)
+	progressDone := make(chan struct{})
+	if progressInterval > 0 {
+		go reportProgress(progressInterval, func() int { return len(FSdata) }, expectedEntries, progressDone)
+	}
 	wg.Wait()
+	close(workersDone)
 	close(FSdata)
 	wg2.Wait()
+	close(progressDone)
+	logger.Info(progressLine(time.Since(startTime), 0, 0))
+	logger.Info("Peak folder queue depth", "folders", folderScanner.PeakQueueDepth())
+	if largeFolders := counters.LargeFolders.Load(); largeFolders > 0 {
+		logger.Info("Folders above -LargeDirThreshold, see the \"Large directory\" log lines", "folders", largeFolders, "threshold", largeDirThreshold)
+	}
+	if archiveMembers := counters.ArchiveMembers.Load(); archiveMembers > 0 {
+		logger.Info("Archive members recorded", "members", archiveMembers)
+	}
+	if stuck := folderScanner.AbandonedCalls(); stuck > 0 {
+		logger.Error("Timed out filesystem calls are still hanging, the timed out folders can be retried with -UpdateErrorOnly=true", "calls", stuck)
+	}
+
+	// the unvisited folders are saved for -Resume, a complete scan leaves an empty checkpoint.
+	// A retry starts with an empty checkpoint and only fills it when interrupted.
+	pendingFolders := folderScanner.Pending()
+	if runMode != "retry" || ctx.Err() != nil {
+		if err := saveCheckpoint(pendingFolders); err != nil {
+			logger.Error("Failed to save the checkpoint", "error", err)
+		}
+	}
+	if ctx.Err() != nil {
+		logger.Error("Scan interrupted, the pending folders are saved to the checkpoint. Run again with -Resume=true to continue.", "folders", len(pendingFolders))
+		if err := finishScanRun(runID, "interrupted"); err != nil {
+			logger.Error("Failed to record the scan run", "error", err)
+		}
+		return 1
+	}
+	cancel()
 
 	// postScanMetaDataUpdate()
+	if err := applyFolderSizeDeltas(); err != nil {
+		logger.Error("Failed to update the folder sizes of the retried files", "error", err)
+	}
 	updateSizeLastWriteDate()
+	createIndexesAndViews(createIndexes)
 	timestamp = time.Now().Format("20060102_150405") //reused the previous timestamp var as its not needed anymore
-	infoMultiLogger.Println("Scan end time:", timestamp)
-	infoMultiLogger.Println("The End!")
-}
-
-// To read the folder contents
-func readFolder(ctx context.Context, path string, FSdata chan<- ObjectInfo, depth int, wg *sync.WaitGroup) {
-	defer wg.Done()
-	// defer atomic.AddInt32(&readFolderCounter, -1) // Decrement the counter when done
-	sem <- struct{}{}        // Acquire a token
-	defer func() { <-sem }() // Release token after execution
-
-	// infoFileLogger.Println("Number of readFolder routines running:", atomic.LoadInt32(&readFolderCounter))
-	if debug {
-		infoFileLogger.Printf("no of active/waiting goroutines: %d and pending data to be written to DB: %d", runtime.NumGoroutine(), len(FSdata))
+	logger.Info("Scan ended", "end_time", timestamp)
+	if err := finishScanRun(runID, "completed"); err != nil {
+		logger.Error("Failed to record the scan run", "error", err)
 	}
-
-	if ctx.Err() != nil {
-		errorMultiLogger.Printf("readFolder goroutine stopping at/for %s.\n", path)
-		return
+	if metricsTextfile != "" {
+		endTime := time.Now()
+		if err := writeMetricsTextfile(metricsTextfile, DBfile, endTime.Sub(startTime), endTime); err != nil {
+			logger.Error("Failed to write the metrics textfile", "error", err)
+		} else {
+			logger.Info("Metrics written", "file", metricsTextfile)
+		}
 	}
-	// build new ObjectInfo for the current folder
-	currentFolderData := new(ObjectInfo)
-	currentFolderData.ObjType = "d"
-	currentFolderData.hasError = false
-	currentFolderData.Path = path
-	currentFolderData.ObjectDepth = depth
-	currentFolderData.FileSize = 0
-	currentFolderData.ThisFolderSize = 0
-
-	// Get folder information
-	info, err := os.Stat(path)
-	if err != nil {
-		currentFolderData.hasError = true
-		currentFolderData.ErrorMessage = err.Error()
-		errorMultiLogger.Printf("Failed to get directory info %s: %v", path, err)
-	} else {
-		//set the folder size which will just be the meta data size
-		currentFolderData.ThisFolderSize = int(info.Size())
+	if droppedRowCount > 0 {
+		logger.Error("The End, with entries missing from the report!", "missing_entries", droppedRowCount)
+		return 1
+	}
+	logger.Info("The End!")
+	return 0
+}
 
-		ctime, atime, wtime, owner, err := getFileTimes(path)
-		if err != nil {
-			errorMultiLogger.Println(err)
-		}
-		currentFolderData.CreationTime = ctime
-		currentFolderData.LastAccessTime = atime
-		currentFolderData.LastWriteTime = wtime
-		currentFolderData.Owner = owner
+// opens a new log file named after the report DB and sets up the loggers writing to it
+func openScanLog(reportDBfile string, level slog.Level) (*os.File, string, error) {
+	logFileName := strings.TrimSuffix(reportDBfile, ".db")         //log file name to store all the current logs
+	timestamp := time.Now().Format("20060102_150405")              //Example format: 20240811_103045
+	logFileName = fmt.Sprintf("%s_%s.log", logFileName, timestamp) //Append the current timestamp and .log suffix
 
-		// Read the directory contents
-		entries, err := os.ReadDir(path)
-		if err != nil {
-			currentFolderData.hasError = true
-			currentFolderData.ErrorMessage = err.Error()
-			errorMultiLogger.Printf("Failed to read contents of directory %s: %v", path, err)
-		} else {
-			// Iterate over the directory entries
-			var name string
-			totalCurrentFolderSize := 0
-			for _, entry := range entries {
-				name = entry.Name()
-				// Join the directory and file name
-				fullPath := filepath.Join(path, name)
-				if entry.IsDir() {
-					wg.Add(1)
-					// atomic.AddInt32(&readFolderCounter, 1) // Increment the counter when a goroutine starts
-					go readFolder(ctx, fullPath, FSdata, depth+1, wg)
-				} else {
-					// build new ObjectInfo for the file
-					newFileData := new(ObjectInfo)
-					newFileData.ObjType = "f"
-					newFileData.hasError = false
-					newFileData.Path = fullPath
-					newFileData.ObjectDepth = depth
-					newFileData.FileSize = 0
-					newFileData.ThisFolderSize = 0
-					// Get file information
-					// info, err := os.Stat(fullPath)
-					info, err := entry.Info()
-					if err != nil {
-						newFileData.hasError = true
-						newFileData.ErrorMessage = err.Error()
-						errorMultiLogger.Printf("Failed to read file %s: %v", fullPath, err)
-					} else {
-						newFileData.FileSize = int(info.Size())
-						totalCurrentFolderSize += newFileData.FileSize
-
-						ctime, atime, wtime, owner, err := getFileTimes(fullPath)
-						if err != nil {
-							errorMultiLogger.Println(err)
-						}
-						newFileData.CreationTime = ctime
-						newFileData.LastAccessTime = atime
-						newFileData.LastWriteTime = wtime
-						newFileData.Owner = owner
-					}
-					FSdata <- *newFileData
-				}
-			}
-			currentFolderData.ThisFolderSize = totalCurrentFolderSize
-		}
+	// Initialize loggers
+	logFile, err := os.OpenFile(logFileName, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0666)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to open log file %s: %v", logFileName, err)
 	}
-	FSdata <- *currentFolderData
+	// logger writes to both file and console, fileLogger to only file
+	setupLogging(os.Stdout, logFile, level)
+	return logFile, logFileName, nil
 }
 
 // To keep writing all the data in the channel to SQlite DB
@@ -341,9 +485,8 @@ func writeMetaDataToSQliteDB(FSdata <-chan ObjectInfo, wg2 *sync.WaitGroup, canc
 	// Open a connection to the SQLite database
 	db, err := sql.Open("sqlite", DBfile)
 	if err != nil {
-		errorMultiLogger.Printf("Unable to open SQlite connection in writeMetaDataToSQliteDB. Error msg: %v", err)
-		errorMultiLogger.Printf("Sending cancellation signal from writeMetaDataToSQliteDB")
-		cancel()
+		logger.Error("Unable to open SQlite connection in writeMetaDataToSQliteDB, sending cancellation signal", "db", DBfile, "error", err)
+		stopWriter(FSdata, cancel)
 		return
 	}
 	defer db.Close()
@@ -355,10 +498,13 @@ func writeMetaDataToSQliteDB(FSdata <-chan ObjectInfo, wg2 *sync.WaitGroup, canc
         Path TEXT PRIMARY KEY UNIQUE,
         ObjectDepth INTEGER,
 		FileSize INTEGER,
+        CompressedSize INTEGER,
         ThisFolderSize INTEGER,
         TotalCalFolderSize INTEGER,
         hasError BOOLEAN,
         ErrorMessage TEXT,
+        ErrorCode TEXT,
+        ErrorStage TEXT,
 		Owner TEXT,
         CreationTime DATETIME,
         LastWriteTime DATETIME,
@@ -368,85 +514,247 @@ func writeMetaDataToSQliteDB(FSdata <-chan ObjectInfo, wg2 *sync.WaitGroup, canc
 
 	_, err = db.Exec(createTableSQL)
 	if err != nil {
-		errorMultiLogger.Printf("Failed to create table: %v", err)
-		errorMultiLogger.Println("Sending cancellation signal")
-		cancel()
+		logger.Error("Failed to create table, sending cancellation signal", "error", err)
+		stopWriter(FSdata, cancel)
+		return
+	}
+	// report DBs of older versions are updated with -UpdateErrorOnly or -Resume
+	if err := addMissingFileinfoColumns(db); err != nil {
+		logger.Error("Failed to update the report DB, sending cancellation signal", "db", DBfile, "error", err)
+		stopWriter(FSdata, cancel)
 		return
 	}
 
-	placeholders := make([]string, 0, insertionBatchSizeSQL)
-	values := make([]interface{}, 0, insertionBatchSizeSQL*11) // Each row has 11 values
-	insertStmt := `INSERT INTO fileinfo (ObjType, Path, ObjectDepth, FileSize, ThisFolderSize, 
-	hasError, ErrorMessage, Owner, CreationTime, LastWriteTime, LastAccessTime) VALUES `
+	// Rows which cannot be inserted even one by one are kept here for later analysis
+	createWriteErrorsTableSQL := `
+    CREATE TABLE IF NOT EXISTS write_errors (
+        Path TEXT,
+        ObjType TEXT,
+        ObjectDepth INTEGER,
+        ErrorMessage TEXT,
+        FailedAt DATETIME
+    );`
 
-	currentIteration := 0 //used to count the number of batch insertions done
+	_, err = db.Exec(createWriteErrorsTableSQL)
+	if err != nil {
+		logger.Error("Failed to create write_errors table, sending cancellation signal", "error", err)
+		stopWriter(FSdata, cancel)
+		return
+	}
+
+	// statements are prepared once and reused inside every batch transaction
+	batchStmt, err := db.Prepare(insertStatementSQL(insertionBatchSizeSQL))
+	if err != nil {
+		logger.Error("Failed to prepare batch insert statement, sending cancellation signal", "error", err)
+		stopWriter(FSdata, cancel)
+		return
+	}
+	defer batchStmt.Close()
+	rowStmt, err := db.Prepare(insertStatementSQL(1))
+	if err != nil {
+		logger.Error("Failed to prepare row insert statement, sending cancellation signal", "error", err)
+		stopWriter(FSdata, cancel)
+		return
+	}
+	defer rowStmt.Close()
+
+	batch := make([]ObjectInfo, 0, insertionBatchSizeSQL)
+	insertedRows := 0
 	for data := range FSdata {
-		// Add placeholders for each row
-		placeholders = append(placeholders, "(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)")
-		// Collect values for the placeholders
-		values = append(values, data.ObjType, data.Path, data.ObjectDepth, data.FileSize,
-			data.ThisFolderSize, data.hasError, data.ErrorMessage, data.Owner,
-			data.CreationTime, data.LastWriteTime, data.LastAccessTime)
+		batch = append(batch, data)
 
 		// When we hit the batch size, execute the insert
-		if len(placeholders) == insertionBatchSizeSQL {
-			currentIteration++
-			query := insertStmt + strings.Join(placeholders, ",")
-			_, err := db.Exec(query, values...)
-			if err != nil {
-				errorMultiLogger.Printf("Failed to insert batch: %v", err)
-			} else {
-				if debug {
-					infoFileLogger.Printf("Successfully inserted %d entries. Remaining entries are %d", currentIteration*insertionBatchSizeSQL, len(FSdata))
-				}
-			}
-			// Reset placeholders and values for the next batch
-			placeholders = placeholders[:0]
-			values = values[:0]
+		if len(batch) == insertionBatchSizeSQL {
+			insertedRows += insertBatch(db, batchStmt, rowStmt, batch)
+			counters.RowsWritten.Store(int64(insertedRows))
+			logger.Debug("Inserted entries", "rows_written", insertedRows, "remaining_entries", len(FSdata))
+			// Reset the batch for the next round
+			batch = batch[:0]
 		}
 	}
 
 	// Insert any remaining rows if there are fewer than batchSize
-	if len(placeholders) > 0 {
-		query := insertStmt + strings.Join(placeholders, ",")
-		_, err := db.Exec(query, values...)
+	if len(batch) > 0 {
+		remainderStmt, err := db.Prepare(insertStatementSQL(len(batch)))
 		if err != nil {
-			errorMultiLogger.Printf("Failed to insert remaining batch: %v", err)
+			logger.Error("Failed to prepare remaining batch insert statement", "error", err)
+			insertedRows += insertRowByRow(db, rowStmt, batch)
 		} else {
-			if debug {
-				infoFileLogger.Printf("Successfully inserted %d entries. Remaining entries are %d", currentIteration*insertionBatchSizeSQL+len(placeholders), len(FSdata))
-			}
+			insertedRows += insertBatch(db, remainderStmt, rowStmt, batch)
+			remainderStmt.Close()
+		}
+		counters.RowsWritten.Store(int64(insertedRows))
+		logger.Debug("Inserted entries", "rows_written", insertedRows, "remaining_entries", len(FSdata))
+	}
+	if droppedRowCount > 0 {
+		logger.Error("Entries could not be inserted, check the write_errors table", "entries", droppedRowCount)
+	}
+	logger.Info("End of the DB insertion.")
+}
+
+// cancels the scan when the DB writer cannot start. FSdata is still drained until it is closed,
+// so that nothing sending to it blocks before seeing the cancellation.
+func stopWriter(FSdata <-chan ObjectInfo, cancel context.CancelFunc) {
+	cancel()
+	for range FSdata {
+	}
+}
+
+// adds the fileinfo columns which report DBs of older versions don't have yet
+func addMissingFileinfoColumns(db *sql.DB) error {
+	existing, err := fileinfoColumns(db)
+	if err != nil {
+		return err
+	}
+
+	newColumns := []struct{ name, columnType string }{
+		{"ErrorCode", "TEXT"},
+		{"ErrorStage", "TEXT"},
+		{"CompressedSize", "INTEGER"},
+	}
+	for _, column := range newColumns {
+		if existing[column.name] {
+			continue
+		}
+		if _, err := db.Exec(`ALTER TABLE fileinfo ADD COLUMN ` + column.name + ` ` + column.columnType + `;`); err != nil {
+			return fmt.Errorf("failed to add the %s column to fileinfo: %v", column.name, err)
+		}
+	}
+	return nil
+}
+
+// returns the names of the columns of the fileinfo table
+func fileinfoColumns(db *sql.DB) (map[string]bool, error) {
+	rows, err := db.Query(`PRAGMA table_info(fileinfo);`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the fileinfo columns: %v", err)
+	}
+	defer rows.Close()
+	columns := make(map[string]bool)
+	for rows.Next() {
+		var cid, notNull, primaryKey int
+		var name, columnType string
+		var defaultValue sql.NullString
+		if err := rows.Scan(&cid, &name, &columnType, &notNull, &defaultValue, &primaryKey); err != nil {
+			return nil, fmt.Errorf("failed to read the fileinfo columns: %v", err)
+		}
+		columns[name] = true
+	}
+	return columns, rows.Err()
+}
+
+// returns the insert statement for the given number of rows.
+// Existing rows are replaced, so a re-scan with -UpdateErrorOnly overwrites the old entries.
+func insertStatementSQL(rowCount int) string {
+	placeholders := make([]string, rowCount)
+	for i := range placeholders {
+		placeholders[i] = "(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)" // Each row has 14 values
+	}
+	return `INSERT OR REPLACE INTO fileinfo (ObjType, Path, ObjectDepth, FileSize, CompressedSize, ThisFolderSize,
+	hasError, ErrorMessage, ErrorCode, ErrorStage, Owner, CreationTime, LastWriteTime, LastAccessTime) VALUES ` + strings.Join(placeholders, ",")
+}
+
+// returns the insert values of a row in the insertStatementSQL column order
+func objectInfoValues(data ObjectInfo) []interface{} {
+	return []interface{}{data.ObjType, data.Path, data.ObjectDepth, data.FileSize, data.CompressedSize,
+		data.ThisFolderSize, data.HasError, data.ErrorMessage, data.ErrorCode, data.ErrorStage, data.Owner,
+		data.CreationTime, data.LastWriteTime, data.LastAccessTime}
+}
+
+// inserts the whole batch in one transaction and falls back to row by row insertion if that fails.
+// Returns the number of inserted rows.
+func insertBatch(db *sql.DB, batchStmt *sql.Stmt, rowStmt *sql.Stmt, batch []ObjectInfo) int {
+	defer func(start time.Time) { insertLatency.Observe(time.Since(start)) }(time.Now())
+	values := make([]interface{}, 0, len(batch)*14)
+	for _, data := range batch {
+		values = append(values, objectInfoValues(data)...)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		logger.Error("Failed to start the batch transaction", "error", err)
+		return insertRowByRow(db, rowStmt, batch)
+	}
+	if _, err := tx.Stmt(batchStmt).Exec(values...); err != nil {
+		tx.Rollback()
+		logger.Error("Failed to insert batch, retrying row by row", "entries", len(batch), "error", err)
+		return insertRowByRow(db, rowStmt, batch)
+	}
+	if err := tx.Commit(); err != nil {
+		logger.Error("Failed to commit batch, retrying row by row", "entries", len(batch), "error", err)
+		return insertRowByRow(db, rowStmt, batch)
+	}
+	return len(batch)
+}
+
+// inserts the rows one by one in a single transaction, rejected rows are recorded in the write_errors table.
+// Returns the number of inserted rows.
+func insertRowByRow(db *sql.DB, rowStmt *sql.Stmt, batch []ObjectInfo) int {
+	tx, err := db.Begin()
+	if err != nil {
+		logger.Error("Failed to start the row by row transaction", "error", err)
+		recordWriteErrors(db, batch, err)
+		return 0
+	}
+	txRowStmt := tx.Stmt(rowStmt)
+	var rejected []ObjectInfo
+	var rejectedErrors []error
+	for _, data := range batch {
+		// a savepoint per row so that one failure doesn't abort the whole transaction
+		tx.Exec("SAVEPOINT row_insert;")
+		if _, err := txRowStmt.Exec(objectInfoValues(data)...); err != nil {
+			tx.Exec("ROLLBACK TO row_insert;")
+			rejected = append(rejected, data)
+			rejectedErrors = append(rejectedErrors, err)
+		}
+		tx.Exec("RELEASE row_insert;")
+	}
+	if err := tx.Commit(); err != nil {
+		logger.Error("Failed to commit the row by row transaction", "error", err)
+		recordWriteErrors(db, batch, err)
+		return 0
+	}
+	for i, data := range rejected {
+		recordWriteErrors(db, []ObjectInfo{data}, rejectedErrors[i])
+	}
+	return len(batch) - len(rejected)
+}
+
+// stores the rejected rows in the write_errors table and counts them as dropped
+func recordWriteErrors(db *sql.DB, rejected []ObjectInfo, insertErr error) {
+	droppedRowCount += len(rejected)
+	failedAt := time.Now().Round(0) // strip the monotonic clock reading, it would end up in the stored text
+	for _, data := range rejected {
+		logger.Error("Failed to insert", "path", data.Path, "depth", data.ObjectDepth, "error", insertErr)
+		_, err := db.Exec(`INSERT INTO write_errors (Path, ObjType, ObjectDepth, ErrorMessage, FailedAt) VALUES (?, ?, ?, ?, ?);`,
+			data.Path, data.ObjType, data.ObjectDepth, insertErr.Error(), failedAt)
+		if err != nil {
+			logger.Error("Failed to record the write error", "path", data.Path, "depth", data.ObjectDepth, "error", err)
 		}
 	}
-	infoMultiLogger.Println("End of the DB insertion.")
 }
 
 // updateTotalCalSize updates TotalCalSize for each folder by summing its size and all its subfolders' sizes
 func updateSizeLastWriteDate() {
-	infoMultiLogger.Println("Starting the updateSizeLastWriteDate now")
+	logger.Info("Starting the updateSizeLastWriteDate now")
 	// Open the database connection
 	db, err := sql.Open("sqlite", DBfile)
 	if err != nil {
-		errorMultiLogger.Println(err)
+		logger.Error("Failed to open the report DB", "db", DBfile, "error", err)
 		return
 	}
 	db.Exec("PRAGMA journal_mode=WAL;")
 	defer db.Close()
 
-	// // Map to hold cumulative TotalCalSize for each folder
-	// folderTotalCalSize := make(map[string]int)
-	// // Map to hold cumulative CalLastWriteTime for each folder
-	// folderLastWriteTimes := make(map[string]time.Time)
-
-	// Map to hold cumulative TotalCalSize for each folder
-	calculatedData := make(map[string]FolderInfoCal)
+	// cumulative TotalCalSize and CalLastWriteTime for each folder
+	rollup := scanner.NewRollup(dirPath)
 
 	// Prepare the SQL query
 	query := `SELECT Path, ThisFolderSize,LastWriteTime FROM fileinfo WHERE ObjType = 'd';`
 	// Execute the query
 	rows, err := db.Query(query)
 	if err != nil {
-		errorMultiLogger.Println("failed to execute query:", err)
+		logger.Error("Failed to execute query", "query", query, "error", err)
 		return
 	}
 	defer rows.Close()
@@ -459,62 +767,16 @@ func updateSizeLastWriteDate() {
 
 		// Scan the current row into variables
 		if err := rows.Scan(&path, &size, &lastWriteTime); err != nil {
-			errorMultiLogger.Println("failed to scan row:", err)
+			logger.Error("Failed to scan row", "error", err)
 			return
 		}
-		// Process the data
-		// folderTotalCalSize[path] += size
-		// folderLastWriteTimes[path] = lastWriteTime
-
-		// calculatedData[path] = FolderInfoCal{
-		// 	TotalCalFolderSize: 1000,
-		// 	CalLastWriteTime:   time.Now(),
-		// }
-
-		for {
-			if folderInfo, exists := calculatedData[path]; exists {
-				// If it exists, update the existing struct
-				folderInfo.TotalCalFolderSize += size // Modify size
-				if lastWriteTime.After(folderInfo.CalLastWriteTime) {
-					folderInfo.CalLastWriteTime = lastWriteTime // Update last write time
-				}
-				calculatedData[path] = folderInfo // Save back updated struct
-			} else {
-				// If it does not exist, initialize and insert a new struct
-				calculatedData[path] = FolderInfoCal{
-					TotalCalFolderSize: size,          // Initial size
-					CalLastWriteTime:   lastWriteTime, // Current time
-				}
-			}
-
-			// Find the last separator (either '/' or '\')
-			lastSeparator := strings.LastIndexAny(path, `\/`)
-			if lastSeparator == -1 {
-				break // No more separators, so we're at the root
-			}
-
-			if path[:lastSeparator] < dirPath {
-				break // we have crossed the user provided directory
-			} else if dirPath == path[:lastSeparator+1] {
-				path = path[:lastSeparator+1] // Move up to the parent directory
-
-			} else if dirPath == path[:lastSeparator] {
-				path = path[:lastSeparator] // Move up to the parent directory
-			} else {
-				path = path[:lastSeparator] // Move up to the parent directory
-			}
-
-			// folderTotalCalSize[path] += size
-			// if lastWriteTime.After(folderLastWriteTimes[path]) {
-			// 	folderLastWriteTimes[path] = lastWriteTime
-			// }
-		}
+		rollup.Add(path, size, lastWriteTime)
 	}
 
 	// Now perform a batch update to the database for all folders
 	tx, err := db.Begin() // Start a transaction for batch updating
 	if err != nil {
-		errorMultiLogger.Printf("failed to start transaction: %v", err)
+		logger.Error("Failed to start transaction", "error", err)
 		return
 	}
 
@@ -525,25 +787,25 @@ func updateSizeLastWriteDate() {
 		WHERE Path = ?;
 	`)
 	if err != nil {
-		errorMultiLogger.Printf("failed to prepare update statement: %v", err)
+		logger.Error("Failed to prepare update statement", "error", err)
 		return
 	}
 	defer updateStmt.Close()
 
 	// Batch update all folders
-	for path, calData := range calculatedData {
+	for path, calData := range rollup.Totals() {
 		if _, err := updateStmt.Exec(calData.TotalCalFolderSize, calData.CalLastWriteTime, path); err != nil {
 			tx.Rollback()
-			errorMultiLogger.Printf("failed to update TotalCalFolderSize for %s: %v", path, err)
+			logger.Error("Failed to update TotalCalFolderSize", "path", path, "error", err)
 			return
 		}
 	}
 
 	// Commit the transaction to apply the updates
 	if err := tx.Commit(); err != nil {
-		errorMultiLogger.Printf("failed to commit transaction: %v", err)
+		logger.Error("Failed to commit transaction", "error", err)
 		return
 	}
 
-	infoMultiLogger.Println("End of updateSizeLastWriteDate")
+	logger.Info("End of updateSizeLastWriteDate")
 }
@@ -0,0 +1,150 @@
+//go:build windows || !windows
+// +build windows !windows
+
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/abhilash945/FolderInsight/pkg/scanner"
+)
+
+// sends the logs of a test nowhere, restoring the loggers and droppedRowCount afterwards
+func quietGlobals(t *testing.T) {
+	t.Helper()
+	savedLogger, savedFileLogger, savedDropped := logger, fileLogger, droppedRowCount
+	logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	fileLogger = logger
+	droppedRowCount = 0
+	t.Cleanup(func() { logger, fileLogger, droppedRowCount = savedLogger, savedFileLogger, savedDropped })
+}
+
+// waits for wg, failing the test when it takes longer than a few seconds
+func waitGroupTimeout(t *testing.T, wg *sync.WaitGroup, what string) {
+	t.Helper()
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatalf("%s did not return", what)
+	}
+}
+
+// a batch with a rejected row is inserted row by row, only the rejected rows go to write_errors
+func TestWriteMetaDataBatchFallback(t *testing.T) {
+	quietGlobals(t)
+	dbFile := filepath.Join(t.TempDir(), "fallback.db")
+	db, err := sql.Open("sqlite", dbFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the writer keeps an existing fileinfo table, this one rejects the paths containing "bad"
+	_, err = db.Exec(`CREATE TABLE fileinfo (ObjType TEXT, Path TEXT PRIMARY KEY UNIQUE, ObjectDepth INTEGER, FileSize INTEGER,
+		CompressedSize INTEGER, ThisFolderSize INTEGER, TotalCalFolderSize INTEGER, hasError BOOLEAN, ErrorMessage TEXT,
+		ErrorCode TEXT, ErrorStage TEXT, Owner TEXT, CreationTime DATETIME, LastWriteTime DATETIME, CalLastWriteTime DATETIME,
+		LastAccessTime DATETIME);
+		CREATE TRIGGER reject_bad BEFORE INSERT ON fileinfo WHEN NEW.Path LIKE '%bad%' BEGIN SELECT RAISE(ABORT, 'bad row'); END;`)
+	db.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// two full batches and a remainder, with a rejected row in the first batch and in the remainder
+	entryCount := 2*insertionBatchSizeSQL + 50
+	badPaths := map[int]string{10: "/data/bad1", 2*insertionBatchSizeSQL + 5: "/data/bad2"}
+	FSdata := make(chan ObjectInfo, entryCount)
+	for i := 0; i < entryCount; i++ {
+		path := fmt.Sprintf("/data/f%04d", i)
+		if badPath, bad := badPaths[i]; bad {
+			path = badPath
+		}
+		FSdata <- ObjectInfo{ObjType: "f", Path: path, ObjectDepth: 1, FileSize: 1}
+	}
+	close(FSdata)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go writeMetaDataToSQliteDB(FSdata, &wg, cancel, dbFile)
+	waitGroupTimeout(t, &wg, "writeMetaDataToSQliteDB")
+	if ctx.Err() != nil {
+		t.Fatal("a rejected row cancelled the scan")
+	}
+
+	db, err = sql.Open("sqlite", dbFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	var rows int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM fileinfo;`).Scan(&rows); err != nil {
+		t.Fatal(err)
+	}
+	if want := entryCount - len(badPaths); rows != want {
+		t.Fatalf("fileinfo has %d rows, want %d", rows, want)
+	}
+	errorRows, err := db.Query(`SELECT Path, ErrorMessage FROM write_errors ORDER BY Path;`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer errorRows.Close()
+	var rejected []string
+	for errorRows.Next() {
+		var path, message string
+		if err := errorRows.Scan(&path, &message); err != nil {
+			t.Fatal(err)
+		}
+		rejected = append(rejected, path)
+	}
+	want := []string{badPaths[10], badPaths[2*insertionBatchSizeSQL+5]}
+	sort.Strings(want)
+	if fmt.Sprint(rejected) != fmt.Sprint(want) || droppedRowCount != len(want) {
+		t.Fatalf("write_errors = %q with %d dropped rows, want %q", rejected, droppedRowCount, want)
+	}
+}
+
+// a DB writer which cannot start cancels the scan, the workers stop instead of blocking on the full channel
+func TestWriterFailureStopsScan(t *testing.T) {
+	quietGlobals(t)
+	fsys := fstest.MapFS{}
+	for i := 0; i < 500; i++ {
+		fsys[fmt.Sprintf("d%d/f%d.txt", i%20, i)] = &fstest.MapFile{Data: []byte("x")}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	FSdata := make(chan ObjectInfo, 10)
+	folderScanner := scanner.New(scanner.Options{Workers: 4, FS: fsys})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		folderScanner.Scan(ctx, FSdata, scanner.Folder{Path: ".", ObjectDepth: 1})
+	}()
+	var wg2 sync.WaitGroup
+	wg2.Add(1)
+	go writeMetaDataToSQliteDB(FSdata, &wg2, cancel, filepath.Join(t.TempDir(), "missing", "folder", "report.db"))
+
+	waitGroupTimeout(t, &wg, "Scan")
+	close(FSdata)
+	waitGroupTimeout(t, &wg2, "writeMetaDataToSQliteDB")
+	if ctx.Err() == nil {
+		t.Fatal("the writer failure did not cancel the scan")
+	}
+	if len(folderScanner.Pending()) == 0 {
+		t.Fatal("the cancelled scan has no pending folders")
+	}
+}
@@ -0,0 +1,235 @@
+//go:build windows || !windows
+// +build windows !windows
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	metricsAddr     string // -MetricsAddr, "" disables the metrics endpoint
+	metricsTextfile string // -MetricsTextfile, "" disables the node_exporter textfile
+)
+
+// latency of the batch inserts into the report DB, including the row by row fallback
+var insertLatency = newDurationHistogram(0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5)
+
+// Histogram of durations in seconds with fixed upper bounds, in the Prometheus layout
+type durationHistogram struct {
+	mutex   sync.Mutex
+	bounds  []float64
+	buckets []int64 // observations per bound, not cumulative
+	count   int64
+	sum     float64
+}
+
+func newDurationHistogram(bounds ...float64) *durationHistogram {
+	return &durationHistogram{bounds: bounds, buckets: make([]int64, len(bounds))}
+}
+
+// records one duration
+func (histogram *durationHistogram) Observe(duration time.Duration) {
+	seconds := duration.Seconds()
+	histogram.mutex.Lock()
+	defer histogram.mutex.Unlock()
+	histogram.count++
+	histogram.sum += seconds
+	if bucket := sort.SearchFloat64s(histogram.bounds, seconds); bucket < len(histogram.bounds) {
+		histogram.buckets[bucket]++
+	}
+}
+
+// writes the histogram as the _bucket, _sum and _count series of name
+func (histogram *durationHistogram) write(out io.Writer, name string, help string) {
+	histogram.mutex.Lock()
+	defer histogram.mutex.Unlock()
+	writeMetricHeader(out, name, "histogram", help)
+	var cumulative int64
+	for i, bound := range histogram.bounds {
+		cumulative += histogram.buckets[i]
+		fmt.Fprintf(out, "%s_bucket{le=\"%g\"} %d\n", name, bound, cumulative)
+	}
+	fmt.Fprintf(out, "%s_bucket{le=\"+Inf\"} %d\n", name, histogram.count)
+	fmt.Fprintf(out, "%s_sum %g\n", name, histogram.sum)
+	fmt.Fprintf(out, "%s_count %d\n", name, histogram.count)
+}
+
+// starts the -MetricsAddr endpoint, which serves the live scan metrics on /metrics until the server is closed.
+// backlog returns the entries waiting in the channel to the DB writer.
+func startMetricsServer(addr string, backlog func() int, channelCapacity int, startTime time.Time) (*http.Server, error) {
+	// listening first, so that a busy port fails the scan before it starts
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s for the metrics, error: %v", addr, err)
+	}
+	server := &http.Server{Handler: newMetricsHandler(backlog, channelCapacity, startTime), ReadHeaderTimeout: 10 * time.Second}
+	go server.Serve(listener)
+	return server, nil
+}
+
+// returns the handler of the /metrics endpoint
+func newMetricsHandler(backlog func() int, channelCapacity int, startTime time.Time) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		writeScanMetrics(w, time.Since(startTime), backlog(), channelCapacity)
+	})
+	return mux
+}
+
+// writes the live counters of the scan in the Prometheus text format
+func writeScanMetrics(out io.Writer, elapsed time.Duration, backlog int, channelCapacity int) {
+	folders := counters.Folders.Load()
+	files := counters.Files.Load()
+
+	writeMetricHeader(out, "folderinsight_scan_entries_total", "counter", "Entries read by the scan by type.")
+	fmt.Fprintf(out, "folderinsight_scan_entries_total{type=\"folder\"} %d\n", folders)
+	fmt.Fprintf(out, "folderinsight_scan_entries_total{type=\"file\"} %d\n", files)
+	fmt.Fprintf(out, "folderinsight_scan_entries_total{type=\"archive_member\"} %d\n", counters.ArchiveMembers.Load())
+	writeMetric(out, "folderinsight_scan_entries_per_second", "gauge", "Average folders and files read per second since the scan start.",
+		float64(folders+files)/elapsed.Seconds())
+	writeMetric(out, "folderinsight_scan_bytes_total", "counter", "Size of the files read by the scan.", float64(counters.Bytes.Load()))
+	writeMetric(out, "folderinsight_scan_elapsed_seconds", "gauge", "Time since the scan start.", elapsed.Seconds())
+
+	writeMetricHeader(out, "folderinsight_scan_errors_total", "counter", "Failed entries by error stage and code.")
+	errorCounts := counters.ErrorCounts()
+	lines := make([]string, 0, len(errorCounts))
+	for class, count := range errorCounts {
+		lines = append(lines, fmt.Sprintf("folderinsight_scan_errors_total{stage=\"%s\",code=\"%s\"} %d\n",
+			escapeLabelValue(class.Stage), escapeLabelValue(class.Code), count))
+	}
+	sort.Strings(lines)
+	io.WriteString(out, strings.Join(lines, ""))
+
+	writeMetric(out, "folderinsight_scan_active_workers", "gauge", "Workers reading a folder right now.", float64(counters.ActiveWorkers.Load()))
+	writeMetric(out, "folderinsight_scan_channel_depth", "gauge", "Entries waiting for the DB writer.", float64(backlog))
+	writeMetric(out, "folderinsight_scan_channel_capacity", "gauge", "Size of the buffer to the DB writer, see -BufferSize.", float64(channelCapacity))
+	writeMetric(out, "folderinsight_db_rows_written_total", "counter", "Rows written to the report DB.", float64(counters.RowsWritten.Load()))
+	insertLatency.write(out, "folderinsight_db_insert_duration_seconds", "Duration of the batch inserts into the report DB.")
+}
+
+// writes the node_exporter textfile of a completed scan: the size and file count of every folder
+// directly below the scan root, the totals and the scan duration. Files directly in the root are
+// counted under the folder "/", which no real folder name can be. The file is replaced atomically so that node_exporter never reads half of it.
+func writeMetricsTextfile(textfile string, reportDBfile string, duration time.Duration, endTime time.Time) error {
+	db, err := openReportDB(reportDBfile)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	rootPath, rootDepth, err := getScanRoot(db)
+	if err != nil {
+		return err
+	}
+	totals, err := readReportTotals(db, rootPath)
+	if err != nil {
+		return err
+	}
+	separator := reportPathSeparator(rootPath)
+	root := escapeLabelValue(rootPath)
+
+	var out strings.Builder
+	writeMetricHeader(&out, "folderinsight_folder_bytes", "gauge", "Size of the folders directly below the scan root, including their sub folders.")
+	var folderFiles strings.Builder
+	writeMetricHeader(&folderFiles, "folderinsight_folder_files", "gauge", "Files in the folders directly below the scan root and their sub folders.")
+
+	rows, err := db.Query(`SELECT Path, IFNULL(TotalCalFolderSize, 0) FROM fileinfo WHERE ObjType = 'd' AND ObjectDepth = ? ORDER BY Path;`, rootDepth+1)
+	if err != nil {
+		return fmt.Errorf("failed to read the top level folders, error: %v", err)
+	}
+	defer rows.Close()
+	type topFolder struct {
+		path string
+		size int64
+	}
+	var topFolders []topFolder
+	for rows.Next() {
+		var folder topFolder
+		if err := rows.Scan(&folder.path, &folder.size); err != nil {
+			return fmt.Errorf("failed to scan a fileinfo row: %v", err)
+		}
+		topFolders = append(topFolders, folder)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+
+	var subtreeFiles, subtreeSize int64
+	for _, folder := range topFolders {
+		prefix, upper := subtreeBounds(folder.path, separator)
+		var files int64
+		err := db.QueryRow(`SELECT COUNT(*) FROM fileinfo WHERE Path > ? AND Path < ? AND ObjType NOT IN ('d', 'a');`, prefix, upper).Scan(&files)
+		if err != nil {
+			return fmt.Errorf("failed to count the files of %s, error: %v", folder.path, err)
+		}
+		name := escapeLabelValue(topFolderOf(folder.path, rootPath))
+		fmt.Fprintf(&out, "folderinsight_folder_bytes{root=\"%s\",folder=\"%s\"} %d\n", root, name, folder.size)
+		fmt.Fprintf(&folderFiles, "folderinsight_folder_files{root=\"%s\",folder=\"%s\"} %d\n", root, name, files)
+		subtreeFiles += files
+		subtreeSize += folder.size
+	}
+	fmt.Fprintf(&out, "folderinsight_folder_bytes{root=\"%s\",folder=\"%s\"} %d\n", root, rootPartition, max(totals.TotalSize-subtreeSize, 0))
+	fmt.Fprintf(&folderFiles, "folderinsight_folder_files{root=\"%s\",folder=\"%s\"} %d\n", root, rootPartition, max(totals.Files-subtreeFiles, 0))
+	out.WriteString(folderFiles.String())
+
+	writeRootMetric(&out, root, "folderinsight_scan_bytes", "Size of the scan root.", float64(totals.TotalSize))
+	writeRootMetric(&out, root, "folderinsight_scan_folders", "Folders in the report DB.", float64(totals.Folders))
+	writeRootMetric(&out, root, "folderinsight_scan_files", "Files in the report DB.", float64(totals.Files))
+	writeRootMetric(&out, root, "folderinsight_scan_errors", "Failed entries in the report DB.", float64(totals.Errors))
+	writeRootMetric(&out, root, "folderinsight_scan_duration_seconds", "Duration of the last scan run.", duration.Seconds())
+	writeRootMetric(&out, root, "folderinsight_scan_last_success_timestamp_seconds", "End time of the last completed scan run.", float64(endTime.Unix()))
+
+	// node_exporter only reads *.prom files, so the temporary file is invisible to it until the rename
+	tempFile, err := os.CreateTemp(filepath.Dir(textfile), filepath.Base(textfile)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create the metrics textfile, error: %v", err)
+	}
+	defer os.Remove(tempFile.Name())
+	if _, err := tempFile.WriteString(out.String()); err != nil {
+		tempFile.Close()
+		return fmt.Errorf("failed to write the metrics textfile, error: %v", err)
+	}
+	if err := tempFile.Close(); err != nil {
+		return fmt.Errorf("failed to write the metrics textfile, error: %v", err)
+	}
+	if err := os.Chmod(tempFile.Name(), 0644); err != nil {
+		return fmt.Errorf("failed to write the metrics textfile, error: %v", err)
+	}
+	if err := os.Rename(tempFile.Name(), textfile); err != nil {
+		return fmt.Errorf("failed to replace the metrics textfile %s, error: %v", textfile, err)
+	}
+	return nil
+}
+
+// writes the HELP and TYPE lines of a metric
+func writeMetricHeader(out io.Writer, name string, metricType string, help string) {
+	fmt.Fprintf(out, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, metricType)
+}
+
+// writes a metric without labels
+func writeMetric(out io.Writer, name string, metricType string, help string, value float64) {
+	writeMetricHeader(out, name, metricType, help)
+	fmt.Fprintf(out, "%s %g\n", name, value)
+}
+
+// writes a gauge labelled with the scan root
+func writeRootMetric(out io.Writer, root string, name string, help string, value float64) {
+	writeMetricHeader(out, name, "gauge", help)
+	fmt.Fprintf(out, "%s{root=\"%s\"} %g\n", name, root, value)
+}
+
+// escapes the backslashes, quotes and new lines of a label value
+func escapeLabelValue(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
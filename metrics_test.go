@@ -0,0 +1,154 @@
+//go:build windows || !windows
+// +build windows !windows
+
+package main
+
+import (
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMetricsEndpoint(t *testing.T) {
+	counters.Folders.Store(3)
+	counters.Files.Store(7)
+	counters.Bytes.Store(1024)
+	counters.RowsWritten.Store(10)
+	t.Cleanup(func() {
+		counters.Folders.Store(0)
+		counters.Files.Store(0)
+		counters.Bytes.Store(0)
+		counters.RowsWritten.Store(0)
+	})
+	handler := newMetricsHandler(func() int { return 5 }, 100, time.Now().Add(-2*time.Second))
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("GET /metrics = %d", recorder.Code)
+	}
+	if contentType := recorder.Header().Get("Content-Type"); contentType != "text/plain; version=0.0.4; charset=utf-8" {
+		t.Fatalf("Content-Type = %q", contentType)
+	}
+	body := recorder.Body.String()
+	for _, want := range []string{
+		"# TYPE folderinsight_scan_entries_total counter\n",
+		"folderinsight_scan_entries_total{type=\"folder\"} 3\n",
+		"folderinsight_scan_entries_total{type=\"file\"} 7\n",
+		"folderinsight_scan_bytes_total 1024\n",
+		"folderinsight_scan_channel_depth 5\n",
+		"folderinsight_scan_channel_capacity 100\n",
+		"folderinsight_db_rows_written_total 10\n",
+		"# TYPE folderinsight_db_insert_duration_seconds histogram\n",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("GET /metrics has no %q", want)
+		}
+	}
+
+	for _, request := range []*http.Request{
+		httptest.NewRequest(http.MethodPost, "/metrics", nil),
+		httptest.NewRequest(http.MethodGet, "/other", nil),
+	} {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, request)
+		if recorder.Code == http.StatusOK {
+			t.Fatalf("%s %s = 200", request.Method, request.URL.Path)
+		}
+	}
+}
+
+// the buckets are cumulative, le is inclusive and +Inf counts every observation
+func TestDurationHistogram(t *testing.T) {
+	histogram := newDurationHistogram(0.01, 0.1, 1)
+	for _, duration := range []time.Duration{5 * time.Millisecond, 10 * time.Millisecond, 50 * time.Millisecond, 2 * time.Second} {
+		histogram.Observe(duration)
+	}
+	var out strings.Builder
+	histogram.write(&out, "test_seconds", "Test durations.")
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	want := []string{
+		"# HELP test_seconds Test durations.",
+		"# TYPE test_seconds histogram",
+		`test_seconds_bucket{le="0.01"} 2`,
+		`test_seconds_bucket{le="0.1"} 3`,
+		`test_seconds_bucket{le="1"} 3`,
+		`test_seconds_bucket{le="+Inf"} 4`,
+		"test_seconds_sum",
+		"test_seconds_count 4",
+	}
+	if len(lines) != len(want) {
+		t.Fatalf("histogram lines = %q, want %q", lines, want)
+	}
+	for i, line := range lines {
+		if want[i] == "test_seconds_sum" {
+			sum, err := strconv.ParseFloat(strings.TrimPrefix(line, "test_seconds_sum "), 64)
+			if err != nil || math.Abs(sum-2.065) > 1e-9 {
+				t.Fatalf("histogram sum line = %q, want 2.065", line)
+			}
+		} else if line != want[i] {
+			t.Fatalf("histogram line %d = %q, want %q", i, line, want[i])
+		}
+	}
+}
+
+func TestWriteMetricsTextfile(t *testing.T) {
+	dbFile := filepath.Join(t.TempDir(), "report.db")
+	writeBrowseTestDB(t, dbFile)
+	dir := t.TempDir()
+	textfile := filepath.Join(dir, "folderinsight.prom")
+	if err := os.WriteFile(textfile, []byte("old content\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	endTime := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	if err := writeMetricsTextfile(textfile, dbFile, 90*time.Second, endTime); err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := os.ReadFile(textfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var samples []string
+	for _, line := range strings.Split(string(content), "\n") {
+		if line != "" && !strings.HasPrefix(line, "#") {
+			samples = append(samples, line)
+		}
+	}
+	// a holds x.txt and y.log, the files b.txt, c.txt and archive.zip are in the root
+	want := []string{
+		`folderinsight_folder_bytes{root="/data",folder="a"} 50`,
+		`folderinsight_folder_bytes{root="/data",folder="/"} 55`,
+		`folderinsight_folder_files{root="/data",folder="a"} 2`,
+		`folderinsight_folder_files{root="/data",folder="/"} 3`,
+		`folderinsight_scan_bytes{root="/data"} 105`,
+		`folderinsight_scan_folders{root="/data"} 2`,
+		`folderinsight_scan_files{root="/data"} 5`,
+		`folderinsight_scan_errors{root="/data"} 0`,
+		`folderinsight_scan_duration_seconds{root="/data"} 90`,
+		`folderinsight_scan_last_success_timestamp_seconds{root="/data"} 1.7172432e+09`,
+	}
+	if !reflect.DeepEqual(samples, want) {
+		t.Fatalf("textfile samples = %q, want %q", samples, want)
+	}
+
+	// the temporary file is renamed, nothing else is left in the folder
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "folderinsight.prom" {
+		var names []string
+		for _, entry := range entries {
+			names = append(names, entry.Name())
+		}
+		t.Fatalf("textfile folder has %q", names)
+	}
+}
@@ -0,0 +1,219 @@
+//go:build windows || !windows
+// +build windows !windows
+
+package scanner
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"strings"
+	"time"
+)
+
+// defaults of the archive limits of Options.ExpandArchives
+const (
+	DefaultMaxArchiveDepth = 2        // archives inside an archive are expanded, one level further they are not
+	DefaultMaxArchiveSize  = 10 << 30 // uncompressed bytes of all the members of one archive
+)
+
+// nested zip files have to be read into memory, bigger ones are listed without their members
+const maxNestedArchiveBuffer = 64 << 20
+
+// ErrArchiveLimit is wrapped by the errors of the archives which are bigger than Options.MaxArchiveSize
+var ErrArchiveLimit = errors.New("archive expansion limit reached")
+
+// the state of expanding one archive found on the disk, shared with the archives nested in it
+type archiveExpansion struct {
+	ctx      context.Context
+	results  chan<- ObjectInfo
+	total    int64 // uncompressed bytes of the members so far
+	exceeded bool  // total went above MaxArchiveSize, nothing more is read
+}
+
+// returns zip, tar or tgz for the file names expanded by Options.ExpandArchives, "" for the others
+func archiveKind(name string) string {
+	lowerName := strings.ToLower(name)
+	switch {
+	case strings.HasSuffix(lowerName, ".zip"):
+		return "zip"
+	case strings.HasSuffix(lowerName, ".tar"):
+		return "tar"
+	case strings.HasSuffix(lowerName, ".tar.gz"), strings.HasSuffix(lowerName, ".tgz"):
+		return "tgz"
+	}
+	return ""
+}
+
+// sends an "a" row for every file in the archive, a failure to read it is set on the archive row itself
+func (scanner *Scanner) expandArchive(ctx context.Context, archive *ObjectInfo, results chan<- ObjectInfo) {
+	scanner.options.OpsLimiter.Wait(ctx, 1)
+	file, err := withFSTimeout(scanner, "open", archive.Path, func() (fs.File, error) { return scanner.fs.open(archive.Path) })
+	if err != nil {
+		scanner.setObjectError(archive, StageArchive, err, "Failed to open archive")
+		return
+	}
+	defer file.Close()
+
+	expansion := &archiveExpansion{ctx: ctx, results: results}
+	var reader io.Reader = file
+	if archiveKind(archive.Path) == "tgz" {
+		// zip only reads its directory and a plain tar seeks over the member contents
+		reader = NewLimitedReader(ctx, file, scanner.options.ReadLimiter)
+	}
+	err = scanner.listArchive(expansion, reader, int64(archive.FileSize), archive.Path, archive.ObjectDepth, 1)
+	if err != nil && ctx.Err() == nil {
+		scanner.setObjectError(archive, StageArchive, err, "Failed to read archive")
+	}
+}
+
+// lists one archive, level is 1 for the archives on the disk and one more for every archive it is nested in
+func (scanner *Scanner) listArchive(expansion *archiveExpansion, reader io.Reader, size int64, archivePath string, depth int, level int) error {
+	switch archiveKind(archivePath) {
+	case "zip":
+		return scanner.listZip(expansion, reader, size, archivePath, depth, level)
+	case "tgz":
+		gzipReader, err := gzip.NewReader(reader)
+		if err != nil {
+			return err
+		}
+		defer gzipReader.Close()
+		return scanner.listTar(expansion, gzipReader, archivePath, depth, level, false)
+	default:
+		return scanner.listTar(expansion, reader, archivePath, depth, level, true)
+	}
+}
+
+func (scanner *Scanner) listZip(expansion *archiveExpansion, reader io.Reader, size int64, archivePath string, depth int, level int) error {
+	readerAt, ok := reader.(io.ReaderAt)
+	if !ok {
+		// a zip needs random access, so a nested one is read into memory
+		if size > maxNestedArchiveBuffer {
+			return fmt.Errorf("%w: nested zip of %d bytes is bigger than %d bytes", ErrArchiveLimit, size, maxNestedArchiveBuffer)
+		}
+		data, err := io.ReadAll(io.LimitReader(reader, maxNestedArchiveBuffer+1))
+		if err != nil {
+			return err
+		}
+		if len(data) > maxNestedArchiveBuffer {
+			return fmt.Errorf("%w: nested zip is bigger than %d bytes", ErrArchiveLimit, maxNestedArchiveBuffer)
+		}
+		readerAt, size = bytes.NewReader(data), int64(len(data))
+	}
+	zipReader, err := zip.NewReader(readerAt, size)
+	if err != nil && !errors.Is(err, zip.ErrInsecurePath) {
+		return err // insecure names are only recorded, never extracted
+	}
+	for _, zipFile := range zipReader.File {
+		if expansion.ctx.Err() != nil {
+			return expansion.ctx.Err()
+		}
+		if zipFile.FileInfo().IsDir() {
+			continue
+		}
+		member := scanner.newArchiveMember(expansion, archivePath, zipFile.Name, depth, int64(zipFile.UncompressedSize64), zipFile.Modified)
+		if member == nil {
+			continue
+		}
+		member.CompressedSize = int(zipFile.CompressedSize64)
+		scanner.expandNestedArchive(expansion, member, level, func() (io.ReadCloser, error) { return zipFile.Open() })
+		if !sendResult(expansion.ctx, expansion.results, *member) {
+			return expansion.ctx.Err()
+		}
+		if expansion.exceeded {
+			return fmt.Errorf("%w: members are bigger than %d bytes", ErrArchiveLimit, scanner.options.MaxArchiveSize)
+		}
+	}
+	return nil
+}
+
+// lists a tar stream, the members of an uncompressed tar are stored with their full size
+func (scanner *Scanner) listTar(expansion *archiveExpansion, reader io.Reader, archivePath string, depth int, level int, uncompressed bool) error {
+	tarReader := tar.NewReader(reader)
+	for {
+		if expansion.ctx.Err() != nil {
+			return expansion.ctx.Err()
+		}
+		header, err := tarReader.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if header.Typeflag == tar.TypeDir || header.Typeflag == tar.TypeXGlobalHeader {
+			continue
+		}
+		member := scanner.newArchiveMember(expansion, archivePath, header.Name, depth, header.Size, header.ModTime)
+		if member == nil {
+			continue
+		}
+		if uncompressed {
+			member.CompressedSize = member.FileSize
+		}
+		member.Owner = header.Uname
+		if member.Owner == "" {
+			member.Owner = fmt.Sprintf("%d:%d", header.Uid, header.Gid)
+		}
+		if !header.AccessTime.IsZero() {
+			member.LastAccessTime = header.AccessTime
+		}
+		scanner.expandNestedArchive(expansion, member, level, func() (io.ReadCloser, error) { return io.NopCloser(tarReader), nil })
+		if !sendResult(expansion.ctx, expansion.results, *member) {
+			return expansion.ctx.Err()
+		}
+		if expansion.exceeded {
+			// the rest of the stream is not decompressed at all
+			return fmt.Errorf("%w: members are bigger than %d bytes", ErrArchiveLimit, scanner.options.MaxArchiveSize)
+		}
+	}
+}
+
+// returns the row of an archive member and adds its size to the archive total, nil for the names
+// which would leave the archive, e.g. ../x. Absolute names like /etc/x are taken as relative.
+func (scanner *Scanner) newArchiveMember(expansion *archiveExpansion, archivePath string, name string, depth int, size int64, modTime time.Time) *ObjectInfo {
+	name = strings.ReplaceAll(name, `\`, "/")
+	name = strings.TrimPrefix(strings.Trim(name, "/"), "./")
+	if !fs.ValidPath(name) || name == "." {
+		scanner.options.Logger.Warn("Skipped archive member, it is not a relative path", "path", archivePath, "member", name)
+		return nil
+	}
+	expansion.total += size
+	if expansion.total > scanner.options.MaxArchiveSize {
+		expansion.exceeded = true
+	}
+	scanner.counters.ArchiveMembers.Add(1)
+
+	member := new(ObjectInfo)
+	member.ObjType = "a"
+	member.Path = scanner.fs.join(archivePath, name)
+	member.ObjectDepth = depth + 1 + strings.Count(name, "/") // the archive is like a folder below its own folder
+	member.FileSize = int(size)
+	member.LastWriteTime = modTime
+	return member
+}
+
+// lists the members of an archive inside an archive, up to Options.MaxArchiveDepth levels
+func (scanner *Scanner) expandNestedArchive(expansion *archiveExpansion, member *ObjectInfo, level int, open func() (io.ReadCloser, error)) {
+	if expansion.exceeded || archiveKind(member.Path) == "" {
+		return
+	}
+	if level >= scanner.options.MaxArchiveDepth {
+		scanner.options.Logger.Debug("Archive nested too deep, not expanded", "path", member.Path, "depth", member.ObjectDepth,
+			"max_archive_depth", scanner.options.MaxArchiveDepth)
+		return
+	}
+	reader, err := open()
+	if err == nil {
+		err = scanner.listArchive(expansion, reader, int64(member.FileSize), member.Path, member.ObjectDepth, level+1)
+		reader.Close()
+	}
+	if err != nil && expansion.ctx.Err() == nil {
+		scanner.setObjectError(member, StageArchive, err, "Failed to read archive")
+	}
+}
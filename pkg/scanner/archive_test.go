@@ -0,0 +1,173 @@
+//go:build windows || !windows
+// +build windows !windows
+
+package scanner
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// archive member of the test archives, content of another test archive for the nested ones
+type archiveMember struct {
+	name    string
+	content []byte
+}
+
+func zipArchive(t *testing.T, members ...archiveMember) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	writer := zip.NewWriter(&buf)
+	for _, member := range members {
+		file, err := writer.Create(member.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := file.Write(member.content); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func tarArchive(t *testing.T, members ...archiveMember) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	writer := tar.NewWriter(&buf)
+	for _, member := range members {
+		header := &tar.Header{Name: member.name, Mode: 0644, Size: int64(len(member.content)), Typeflag: tar.TypeReg}
+		if err := writer.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := writer.Write(member.content); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// lists the archive like expandArchive does and returns the member paths below archivePath
+func listTestArchive(t *testing.T, options Options, archivePath string, reader io.Reader, size int64) ([]string, map[string]ObjectInfo, error) {
+	t.Helper()
+	scanner := New(options)
+	results := make(chan ObjectInfo, 100)
+	expansion := &archiveExpansion{ctx: context.Background(), results: results}
+	err := scanner.listArchive(expansion, reader, size, archivePath, 1, 1)
+	close(results)
+	var names []string
+	members := make(map[string]ObjectInfo)
+	for member := range results {
+		name := filepath.ToSlash(strings.TrimPrefix(member.Path, archivePath+string(filepath.Separator)))
+		names = append(names, name)
+		members[name] = member
+	}
+	sort.Strings(names)
+	return names, members, err
+}
+
+func TestListArchiveLimits(t *testing.T) {
+	small := bytes.Repeat([]byte("x"), 10)
+	big := bytes.Repeat([]byte("x"), 100)
+	tests := []struct {
+		name      string
+		kind      string
+		members   []archiveMember
+		options   Options
+		wantNames []string
+		wantErr   error
+	}{
+		{"zip in the limit", "zip", []archiveMember{{"a.txt", small}, {"d/b.txt", small}},
+			Options{MaxArchiveSize: 20}, []string{"a.txt", "d/b.txt"}, nil},
+		{"zip over the limit", "zip", []archiveMember{{"a.txt", small}, {"b.txt", big}, {"c.txt", small}},
+			Options{MaxArchiveSize: 50}, []string{"a.txt", "b.txt"}, ErrArchiveLimit},
+		{"tar in the limit", "tar", []archiveMember{{"a.txt", small}, {"./d/b.txt", small}},
+			Options{MaxArchiveSize: 20}, []string{"a.txt", "d/b.txt"}, nil},
+		{"tar over the limit", "tar", []archiveMember{{"a.txt", big}, {"b.txt", small}},
+			Options{MaxArchiveSize: 50}, []string{"a.txt"}, ErrArchiveLimit},
+		{"names leaving the archive", "tar", []archiveMember{{"../x.txt", small}, {"/etc/y.txt", small}, {"a/../../z", small}},
+			Options{}, []string{"etc/y.txt"}, nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			data := tarArchive(t, test.members...)
+			if test.kind == "zip" {
+				data = zipArchive(t, test.members...)
+			}
+			names, _, err := listTestArchive(t, test.options, "test."+test.kind, bytes.NewReader(data), int64(len(data)))
+			if !errors.Is(err, test.wantErr) {
+				t.Fatalf("listArchive() error = %v, want %v", err, test.wantErr)
+			}
+			if !reflect.DeepEqual(names, test.wantNames) {
+				t.Fatalf("listArchive() members = %q, want %q", names, test.wantNames)
+			}
+		})
+	}
+}
+
+func TestListArchiveNested(t *testing.T) {
+	inner := zipArchive(t, archiveMember{"deep.txt", []byte("deep")})
+	middle := tarArchive(t, archiveMember{"inner.zip", inner}, archiveMember{"m.txt", []byte("m")})
+	outer := zipArchive(t, archiveMember{"middle.tar", middle})
+	tests := []struct {
+		maxDepth  int
+		wantNames []string
+	}{
+		{1, []string{"middle.tar"}},
+		{2, []string{"middle.tar", "middle.tar/inner.zip", "middle.tar/m.txt"}},
+		{3, []string{"middle.tar", "middle.tar/inner.zip", "middle.tar/inner.zip/deep.txt", "middle.tar/m.txt"}},
+	}
+	for _, test := range tests {
+		names, members, err := listTestArchive(t, Options{MaxArchiveDepth: test.maxDepth}, "outer.zip", bytes.NewReader(outer), int64(len(outer)))
+		if err != nil {
+			t.Fatalf("MaxArchiveDepth %d: listArchive() error = %v", test.maxDepth, err)
+		}
+		if !reflect.DeepEqual(names, test.wantNames) {
+			t.Fatalf("MaxArchiveDepth %d: members = %q, want %q", test.maxDepth, names, test.wantNames)
+		}
+		if depth := members["middle.tar"].ObjectDepth; depth != 2 {
+			t.Fatalf("MaxArchiveDepth %d: middle.tar depth = %d, want 2", test.maxDepth, depth)
+		}
+	}
+}
+
+// the limit of an outer archive counts the members of the archives nested in it too
+func TestListArchiveNestedLimit(t *testing.T) {
+	middle := tarArchive(t, archiveMember{"a.txt", bytes.Repeat([]byte("x"), 40)}, archiveMember{"b.txt", []byte("b")})
+	outer := zipArchive(t, archiveMember{"middle.tar", middle}, archiveMember{"c.txt", []byte("c")})
+	names, members, err := listTestArchive(t, Options{MaxArchiveSize: int64(len(middle)) + 20}, "outer.zip", bytes.NewReader(outer), int64(len(outer)))
+	if !errors.Is(err, ErrArchiveLimit) {
+		t.Fatalf("listArchive() error = %v, want ErrArchiveLimit", err)
+	}
+	if want := []string{"middle.tar", "middle.tar/a.txt"}; !reflect.DeepEqual(names, want) {
+		t.Fatalf("listArchive() members = %q, want %q", names, want)
+	}
+	if !members["middle.tar"].HasError || members["middle.tar"].ErrorCode != "EARCHIVELIMIT" {
+		t.Fatalf("nested archive row = %+v, want an EARCHIVELIMIT error", members["middle.tar"])
+	}
+}
+
+// a nested zip is read into memory, one bigger than the buffer is not read at all
+func TestListZipNestedBuffer(t *testing.T) {
+	scanner := New(Options{})
+	expansion := &archiveExpansion{ctx: context.Background(), results: make(chan ObjectInfo, 1)}
+	reader := io.MultiReader(strings.NewReader("not read")) // no io.ReaderAt, like a member stream
+	err := scanner.listZip(expansion, reader, maxNestedArchiveBuffer+1, "big.zip", 1, 2)
+	if !errors.Is(err, ErrArchiveLimit) {
+		t.Fatalf("listZip() error = %v, want ErrArchiveLimit", err)
+	}
+}
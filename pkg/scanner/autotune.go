@@ -0,0 +1,151 @@
+//go:build windows || !windows
+// +build windows !windows
+
+package scanner
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// interval between two concurrency decisions of the auto tuner
+const autoTuneInterval = 2 * time.Second
+
+// WorkerLimit caps how many readFolder workers may read a folder at the same time.
+// The limit can be changed while the workers are running.
+type WorkerLimit struct {
+	mutex  sync.Mutex
+	cond   *sync.Cond
+	limit  int
+	active int
+}
+
+func NewWorkerLimit(limit int) *WorkerLimit {
+	workerLimit := &WorkerLimit{limit: limit}
+	workerLimit.cond = sync.NewCond(&workerLimit.mutex)
+	return workerLimit
+}
+
+// waits until the worker is allowed to read a folder
+func (workerLimit *WorkerLimit) Acquire() {
+	workerLimit.mutex.Lock()
+	for workerLimit.active >= workerLimit.limit {
+		workerLimit.cond.Wait()
+	}
+	workerLimit.active++
+	workerLimit.mutex.Unlock()
+}
+
+// gives back the slot taken with Acquire
+func (workerLimit *WorkerLimit) Release() {
+	workerLimit.mutex.Lock()
+	workerLimit.active--
+	workerLimit.mutex.Unlock()
+	workerLimit.cond.Signal()
+}
+
+func (workerLimit *WorkerLimit) Limit() int {
+	workerLimit.mutex.Lock()
+	defer workerLimit.mutex.Unlock()
+	return workerLimit.limit
+}
+
+func (workerLimit *WorkerLimit) SetLimit(limit int) {
+	workerLimit.mutex.Lock()
+	workerLimit.limit = limit
+	workerLimit.mutex.Unlock()
+	workerLimit.cond.Broadcast()
+}
+
+// Latency and count of the filesystem calls (stat, readdir) since the last auto tuner decision
+type FSLatency struct {
+	totalNanos atomic.Int64
+	calls      atomic.Int64
+}
+
+// records the duration of one filesystem call
+func (latency *FSLatency) Record(duration time.Duration) {
+	latency.totalNanos.Add(int64(duration))
+	latency.calls.Add(1)
+}
+
+// returns the calls and average latency since the previous call and resets them
+func (latency *FSLatency) Reset() (int64, time.Duration) {
+	calls := latency.calls.Swap(0)
+	totalNanos := latency.totalNanos.Swap(0)
+	if calls == 0 {
+		return 0, 0
+	}
+	return calls, time.Duration(totalNanos / calls)
+}
+
+// state of the AIMD decisions of the auto tuner
+type autoTuner struct {
+	minWorkers, maxWorkers int
+	bestLatency            time.Duration
+	previousThroughput     float64
+}
+
+// returns the worker limit for the next interval AIMD style: the limit grows by a fixed step while
+// the throughput keeps up and the latency stays close to the best one seen, and it is cut down
+// by a factor once the latency climbs, which is the storage saying it is saturated.
+func (tuner *autoTuner) next(limit int, throughput float64, avgLatency time.Duration) (int, string) {
+	const (
+		increaseStep       = 4   // additive increase
+		decreaseFactor     = 0.7 // multiplicative decrease
+		congestedLatency   = 2.0 // latency compared to the best latency seen that counts as congestion
+		throughputTolerant = 0.9 // throughput compared to the previous interval that still counts as keeping up
+	)
+	if tuner.bestLatency == 0 || avgLatency < tuner.bestLatency {
+		tuner.bestLatency = avgLatency
+	} else {
+		// let the best latency drift up slowly, a warm cache at the start shouldn't count forever
+		tuner.bestLatency += tuner.bestLatency / 20
+	}
+
+	newLimit := limit
+	reason := "holding"
+	if float64(avgLatency) > congestedLatency*float64(tuner.bestLatency) {
+		newLimit = int(float64(limit) * decreaseFactor)
+		reason = "latency is up"
+	} else if throughput >= throughputTolerant*tuner.previousThroughput {
+		newLimit = limit + increaseStep
+		reason = "throughput keeps up"
+	}
+	if newLimit < tuner.minWorkers {
+		newLimit = tuner.minWorkers
+	} else if newLimit > tuner.maxWorkers {
+		newLimit = tuner.maxWorkers
+	}
+	tuner.previousThroughput = throughput
+	return newLimit, reason
+}
+
+// adjusts the worker limit with the autoTuner decisions until done is closed
+func (scanner *Scanner) autoTuneWorkers(workerLimit *WorkerLimit, done <-chan struct{}) {
+	tuner := autoTuner{minWorkers: scanner.options.MinWorkers, maxWorkers: scanner.options.MaxWorkers}
+	ticker := time.NewTicker(autoTuneInterval)
+	defer ticker.Stop()
+	scanner.latency.Reset()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+		calls, avgLatency := scanner.latency.Reset()
+		if calls == 0 {
+			// nothing was read, most likely waiting on the DB writer, so there is nothing to learn from
+			continue
+		}
+		throughput := float64(calls) / autoTuneInterval.Seconds()
+		limit := workerLimit.Limit()
+		newLimit, reason := tuner.next(limit, throughput, avgLatency)
+		if newLimit != limit {
+			workerLimit.SetLimit(newLimit)
+		}
+		scanner.options.Logger.Debug("Auto tune", "fs_calls_per_second", int64(throughput), "avg_latency", avgLatency.String(),
+			"best_latency", tuner.bestLatency.String(), "workers", limit, "new_workers", newLimit, "reason", reason)
+	}
+}
@@ -0,0 +1,101 @@
+//go:build windows || !windows
+// +build windows !windows
+
+package scanner
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAutoTunerNext(t *testing.T) {
+	type interval struct {
+		throughput float64
+		latency    time.Duration
+		wantLimit  int
+		wantReason string
+	}
+	tests := []struct {
+		name      string
+		limit     int
+		intervals []interval
+	}{
+		{"additive increase while the throughput keeps up", 8, []interval{
+			{1000, time.Millisecond, 12, "throughput keeps up"},
+			{1100, time.Millisecond, 16, "throughput keeps up"},
+			{1000, time.Millisecond, 20, "throughput keeps up"},
+		}},
+		{"multiplicative decrease once the latency doubles", 20, []interval{
+			{1000, time.Millisecond, 24, "throughput keeps up"},
+			{1000, 3 * time.Millisecond, 16, "latency is up"},
+			{1000, time.Millisecond, 20, "throughput keeps up"},
+		}},
+		{"holding when the throughput drops without latency", 8, []interval{
+			{1000, time.Millisecond, 12, "throughput keeps up"},
+			{500, time.Millisecond, 12, "holding"},
+		}},
+		{"capped at the max workers", 62, []interval{
+			{1000, time.Millisecond, 64, "throughput keeps up"},
+			{1000, time.Millisecond, 64, "throughput keeps up"},
+		}},
+		{"floored at the min workers", 4, []interval{
+			{1000, time.Millisecond, 8, "throughput keeps up"},
+			{1000, 10 * time.Millisecond, 5, "latency is up"},
+			{1000, 10 * time.Millisecond, 4, "latency is up"},
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tuner := autoTuner{minWorkers: 4, maxWorkers: 64}
+			limit := test.limit
+			for i, step := range test.intervals {
+				newLimit, reason := tuner.next(limit, step.throughput, step.latency)
+				if newLimit != step.wantLimit || reason != step.wantReason {
+					t.Fatalf("interval %d: next(%d) = %d, %q, want %d, %q", i, limit, newLimit, reason, step.wantLimit, step.wantReason)
+				}
+				limit = newLimit
+			}
+		})
+	}
+}
+
+func TestWorkerLimit(t *testing.T) {
+	workerLimit := NewWorkerLimit(1)
+	workerLimit.Acquire()
+	acquired := make(chan struct{})
+	go func() {
+		workerLimit.Acquire()
+		close(acquired)
+	}()
+	select {
+	case <-acquired:
+		t.Fatal("Acquire() went past the limit")
+	case <-time.After(20 * time.Millisecond):
+	}
+	workerLimit.SetLimit(2)
+	select {
+	case <-acquired:
+	case <-time.After(5 * time.Second):
+		t.Fatal("SetLimit() did not wake up the waiting worker")
+	}
+	if workerLimit.Limit() != 2 {
+		t.Fatalf("Limit() = %d, want 2", workerLimit.Limit())
+	}
+	workerLimit.Release()
+	workerLimit.Release()
+}
+
+func TestFSLatencyReset(t *testing.T) {
+	var latency FSLatency
+	if calls, avg := latency.Reset(); calls != 0 || avg != 0 {
+		t.Fatalf("Reset() without calls = %d, %v", calls, avg)
+	}
+	latency.Record(time.Millisecond)
+	latency.Record(3 * time.Millisecond)
+	if calls, avg := latency.Reset(); calls != 2 || avg != 2*time.Millisecond {
+		t.Fatalf("Reset() = %d, %v, want 2, 2ms", calls, avg)
+	}
+	if calls, _ := latency.Reset(); calls != 0 {
+		t.Fatalf("Reset() after a reset = %d calls", calls)
+	}
+}
@@ -0,0 +1,121 @@
+//go:build windows || !windows
+// +build windows !windows
+
+package scanner
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"runtime"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// ErrorStage values, the step of the scan which failed
+const (
+	StageStat    = "stat"    // stat of a folder or file
+	StageReadDir = "readdir" // opening or listing a folder
+	StageOwner   = "owner"   // owner lookup
+	StageArchive = "archive" // listing the members of an archive, see Options.ExpandArchives
+)
+
+// errOwnerLookup is wrapped by the owner lookup errors of getFileTimes
+var errOwnerLookup = errors.New("cannot get the file owner")
+
+// errno names stored in ErrorCode, in the order they are checked
+var errorCodes = []struct {
+	code string
+	err  error
+}{
+	{"FSTIMEOUT", ErrFSTimeout}, // abandoned by Options.FSTimeout, not a timeout of the system
+	{"EACCES", fs.ErrPermission},
+	{"ENOENT", fs.ErrNotExist},
+	{"EIO", syscall.EIO},
+	{"ELOOP", syscall.ELOOP},
+	{"ENAMETOOLONG", syscall.ENAMETOOLONG},
+	{"ETIMEDOUT", syscall.ETIMEDOUT},
+	{"ESTALE", syscall.ESTALE},
+	{"ENOTDIR", syscall.ENOTDIR},
+	{"EARCHIVELIMIT", ErrArchiveLimit},
+	{"EFORMAT", zip.ErrFormat},
+	{"EFORMAT", zip.ErrAlgorithm},
+	{"EFORMAT", tar.ErrHeader},
+	{"EFORMAT", gzip.ErrHeader},
+	{"EFORMAT", gzip.ErrChecksum},
+	{"EFORMAT", io.ErrUnexpectedEOF}, // truncated archive
+}
+
+// ClassifyError returns the ErrorCode of an error: an errno name like EACCES, ERRNO_<n> for other
+// system errors, FSTIMEOUT for the calls abandoned after Options.FSTimeout, EARCHIVELIMIT or EFORMAT
+// for the archives and EOTHER for everything else
+func ClassifyError(err error) string {
+	for _, errorCode := range errorCodes {
+		if errors.Is(err, errorCode.err) {
+			return errorCode.code
+		}
+	}
+	var errno syscall.Errno
+	if errors.As(err, &errno) {
+		return fmt.Sprintf("ERRNO_%d", uintptr(errno))
+	}
+	return "EOTHER"
+}
+
+// returns the ErrorStage of a getFileTimes error
+func fileTimesErrorStage(err error) string {
+	if errors.Is(err, errOwnerLookup) {
+		return StageOwner
+	}
+	return StageStat
+}
+
+// flags the object as failed with the error classified by code and stage and logs it as message.
+// Every error event has the path, depth, stage, error_code and error attributes, attrs are added to them.
+func (scanner *Scanner) setObjectError(data *ObjectInfo, stage string, err error, message string, attrs ...any) {
+	data.HasError = true
+	data.ErrorMessage = err.Error()
+	data.ErrorCode = ClassifyError(err)
+	data.ErrorStage = stage
+	scanner.counters.Errors.Add(1)
+	scanner.counters.addError(ErrorClass{Stage: stage, Code: data.ErrorCode})
+	if !scanner.options.Logger.Enabled(context.Background(), slog.LevelError) {
+		return
+	}
+	// the source of the record is the caller, not this function
+	var callers [1]uintptr
+	runtime.Callers(2, callers[:])
+	record := slog.NewRecord(time.Now(), slog.LevelError, message, callers[0])
+	record.Add("path", data.Path, "depth", data.ObjectDepth, "stage", stage, "error_code", data.ErrorCode, "error", err.Error())
+	record.Add(attrs...)
+	scanner.options.Logger.Handler().Handle(context.Background(), record)
+}
+
+// ErrorClass is the ErrorStage and ErrorCode pair of a failed entry
+type ErrorClass struct {
+	Stage string
+	Code  string
+}
+
+// counts a failed entry of the given class
+func (counters *Counters) addError(class ErrorClass) {
+	count, _ := counters.errorClasses.LoadOrStore(class, new(atomic.Int64))
+	count.(*atomic.Int64).Add(1)
+}
+
+// ErrorCounts returns the number of failed entries by class so far
+func (counters *Counters) ErrorCounts() map[ErrorClass]int64 {
+	errorCounts := make(map[ErrorClass]int64)
+	counters.errorClasses.Range(func(class, count interface{}) bool {
+		errorCounts[class.(ErrorClass)] = count.(*atomic.Int64).Load()
+		return true
+	})
+	return errorCounts
+}
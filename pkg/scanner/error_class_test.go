@@ -0,0 +1,90 @@
+//go:build windows || !windows
+// +build windows !windows
+
+package scanner
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"syscall"
+	"testing"
+)
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{&os.PathError{Op: "open", Path: "x", Err: syscall.EACCES}, "EACCES"},
+		{fs.ErrPermission, "EACCES"},
+		{&os.PathError{Op: "lstat", Path: "x", Err: syscall.ENOENT}, "ENOENT"},
+		{&os.PathError{Op: "readdirent", Path: "x", Err: syscall.EIO}, "EIO"},
+		{&os.PathError{Op: "stat", Path: "x", Err: syscall.ELOOP}, "ELOOP"},
+		{&os.PathError{Op: "stat", Path: "x", Err: syscall.ENAMETOOLONG}, "ENAMETOOLONG"},
+		{&os.PathError{Op: "stat", Path: "x", Err: syscall.ESTALE}, "ESTALE"},
+		{&os.PathError{Op: "stat", Path: "x", Err: syscall.ENOTDIR}, "ENOTDIR"},
+		{&os.PathError{Op: "stat", Path: "x", Err: syscall.ETIMEDOUT}, "ETIMEDOUT"},
+		{fmt.Errorf("stat x: %w after 1s", ErrFSTimeout), "FSTIMEOUT"},
+		{fmt.Errorf("member too big: %w", ErrArchiveLimit), "EARCHIVELIMIT"},
+		{fmt.Errorf("broken.zip: %w", zip.ErrFormat), "EFORMAT"},
+		{io.ErrUnexpectedEOF, "EFORMAT"},
+		{&os.PathError{Op: "stat", Path: "x", Err: syscall.Errno(250)}, "ERRNO_250"},
+		{errors.New("something else"), "EOTHER"},
+	}
+	for _, test := range tests {
+		if got := ClassifyError(test.err); got != test.want {
+			t.Errorf("ClassifyError(%v) = %s, want %s", test.err, got, test.want)
+		}
+	}
+}
+
+func TestFileTimesErrorStage(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{fmt.Errorf("%w of x: access denied", errOwnerLookup), StageOwner},
+		{fmt.Errorf("stat x: %w", fs.ErrPermission), StageStat},
+		{fmt.Errorf("stat x: %w after 1s", ErrFSTimeout), StageStat},
+	}
+	for _, test := range tests {
+		if got := fileTimesErrorStage(test.err); got != test.want {
+			t.Errorf("fileTimesErrorStage(%v) = %s, want %s", test.err, got, test.want)
+		}
+	}
+}
+
+func TestSetObjectErrorCounts(t *testing.T) {
+	scanner := New(Options{})
+	for _, err := range []error{fs.ErrPermission, fs.ErrPermission, fs.ErrNotExist} {
+		data := &ObjectInfo{Path: "x", ObjectDepth: 2}
+		scanner.setObjectError(data, StageStat, err, "Failed")
+		if !data.HasError || data.ErrorStage != StageStat || data.ErrorMessage != err.Error() {
+			t.Fatalf("setObjectError() left %+v", data)
+		}
+	}
+	data := &ObjectInfo{Path: "y"}
+	scanner.setObjectError(data, StageReadDir, fs.ErrPermission, "Failed")
+
+	want := map[ErrorClass]int64{
+		{StageStat, "EACCES"}:    2,
+		{StageStat, "ENOENT"}:    1,
+		{StageReadDir, "EACCES"}: 1,
+	}
+	got := scanner.Counters().ErrorCounts()
+	if len(got) != len(want) {
+		t.Fatalf("ErrorCounts() = %v, want %v", got, want)
+	}
+	for class, count := range want {
+		if got[class] != count {
+			t.Fatalf("ErrorCounts() = %v, want %v", got, want)
+		}
+	}
+	if errorCount := scanner.Counters().Errors.Load(); errorCount != 4 {
+		t.Fatalf("Errors = %d, want 4", errorCount)
+	}
+}
@@ -0,0 +1,91 @@
+//go:build linux
+// +build linux
+
+package scanner
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// returns ctime, atime, wtime, uid:gid, errorMSG of a file from its already gathered info.
+// The uid:gid owner needs no lookup, so fileOwner makes no difference here.
+func getFileTimes(path string, info os.FileInfo, fileOwner bool) (time.Time, time.Time, time.Time, string, error) {
+	var ctime, atime, wtime time.Time
+	var uid_gid string
+	var errorMSG error
+
+	switch stat := info.Sys().(type) {
+	case *unix.Stat_t: // from lstatAt
+		uid_gid = strconv.FormatUint(uint64(stat.Uid), 10) + ":" + strconv.FormatUint(uint64(stat.Gid), 10)
+		atime = time.Unix(int64(stat.Atim.Sec), int64(stat.Atim.Nsec))
+		ctime = time.Unix(int64(stat.Ctim.Sec), int64(stat.Ctim.Nsec))
+	case *syscall.Stat_t: // from os.Stat
+		uid_gid = strconv.FormatUint(uint64(stat.Uid), 10) + ":" + strconv.FormatUint(uint64(stat.Gid), 10)
+		atime = time.Unix(int64(stat.Atim.Sec), int64(stat.Atim.Nsec))
+		ctime = time.Unix(int64(stat.Ctim.Sec), int64(stat.Ctim.Nsec))
+	default:
+		errorMSG = fmt.Errorf("cannot get the linux stat for %s error message: unexpected %T", path, info.Sys())
+	}
+	wtime = info.ModTime()
+	return ctime, atime, wtime, uid_gid, errorMSG
+}
+
+// lstat of a directory entry with a single fstatat relative to the open directory,
+// so neither the full path is resolved again nor a second stat is needed for the times
+func lstatAt(dir *os.File, entry os.DirEntry) (os.FileInfo, error) {
+	info := &unixFileInfo{name: entry.Name()}
+	err := unix.Fstatat(int(dir.Fd()), entry.Name(), &info.stat, unix.AT_SYMLINK_NOFOLLOW)
+	if err != nil {
+		return nil, &os.PathError{Op: "fstatat", Path: filepath.Join(dir.Name(), entry.Name()), Err: err}
+	}
+	return info, nil
+}
+
+// os.FileInfo over a unix.Stat_t
+type unixFileInfo struct {
+	name string
+	stat unix.Stat_t
+}
+
+func (info *unixFileInfo) Name() string     { return info.name }
+func (info *unixFileInfo) Size() int64      { return info.stat.Size }
+func (info *unixFileInfo) IsDir() bool      { return info.Mode().IsDir() }
+func (info *unixFileInfo) Sys() interface{} { return &info.stat }
+func (info *unixFileInfo) ModTime() time.Time {
+	return time.Unix(int64(info.stat.Mtim.Sec), int64(info.stat.Mtim.Nsec))
+}
+
+func (info *unixFileInfo) Mode() os.FileMode {
+	mode := os.FileMode(info.stat.Mode & 0777)
+	switch info.stat.Mode & unix.S_IFMT {
+	case unix.S_IFDIR:
+		mode |= os.ModeDir
+	case unix.S_IFLNK:
+		mode |= os.ModeSymlink
+	case unix.S_IFIFO:
+		mode |= os.ModeNamedPipe
+	case unix.S_IFSOCK:
+		mode |= os.ModeSocket
+	case unix.S_IFBLK:
+		mode |= os.ModeDevice
+	case unix.S_IFCHR:
+		mode |= os.ModeDevice | os.ModeCharDevice
+	}
+	if info.stat.Mode&unix.S_ISUID != 0 {
+		mode |= os.ModeSetuid
+	}
+	if info.stat.Mode&unix.S_ISGID != 0 {
+		mode |= os.ModeSetgid
+	}
+	if info.stat.Mode&unix.S_ISVTX != 0 {
+		mode |= os.ModeSticky
+	}
+	return mode
+}
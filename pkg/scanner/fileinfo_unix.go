@@ -0,0 +1,44 @@
+//go:build !windows && !linux
+// +build !windows,!linux
+
+package scanner
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// returns ctime, atime, wtime, uid:gid, errorMSG of a file from its already gathered info.
+// The uid:gid owner needs no lookup, so fileOwner makes no difference here.
+func getFileTimes(path string, info os.FileInfo, fileOwner bool) (time.Time, time.Time, time.Time, string, error) {
+	var ctime, atime, wtime time.Time
+	var uid_gid string
+	var errorMSG error
+
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		uid_gid = strconv.FormatUint(uint64(stat.Uid), 10) + ":" + strconv.FormatUint(uint64(stat.Gid), 10)
+		atime, ctime = statTimes(stat)
+	} else {
+		// not from os, stat it again
+		var stat unix.Stat_t
+		if err := unix.Lstat(path, &stat); err != nil {
+			errorMSG = fmt.Errorf("cannot get the non-windows stat for %s error message: %v", path, err)
+		} else {
+			uid_gid = strconv.FormatUint(uint64(stat.Uid), 10) + ":" + strconv.FormatUint(uint64(stat.Gid), 10)
+			atime = time.Unix(int64(stat.Atim.Sec), int64(stat.Atim.Nsec))
+			ctime = time.Unix(int64(stat.Ctim.Sec), int64(stat.Ctim.Nsec))
+		}
+	}
+	wtime = info.ModTime()
+	return ctime, atime, wtime, uid_gid, errorMSG
+}
+
+// lstat of a directory entry, its info already holds the times
+func lstatAt(dir *os.File, entry os.DirEntry) (os.FileInfo, error) {
+	return entry.Info()
+}
@@ -1,7 +1,7 @@
 //go:build windows
 // +build windows
 
-package main
+package scanner
 
 import (
 	"fmt"
@@ -12,36 +12,41 @@ import (
 	"golang.org/x/sys/windows"
 )
 
-func getFileTimes(path string) (time.Time, time.Time, time.Time, string, error) {
+// returns ctime, atime, wtime, owner, errorMSG of a file from its already gathered info
+func getFileTimes(path string, info os.FileInfo, fileOwner bool) (time.Time, time.Time, time.Time, string, error) {
 	var ctime, atime, wtime time.Time
 	var owner string
 	var errorMSG error
 
-	info, err := os.Stat(path)
-	if err != nil {
-		errorMSG = fmt.Errorf("cannot get the windows stat for %s error message: %v", path, err)
+	winSys, ok := info.Sys().(*syscall.Win32FileAttributeData)
+	if !ok {
+		errorMSG = fmt.Errorf("cannot get the windows stat for %s error message: unexpected %T", path, info.Sys())
 	} else {
-		sys := info.Sys()
-		winSys := sys.(*syscall.Win32FileAttributeData)
 		ctime = time.Unix(0, winSys.CreationTime.Nanoseconds())
 		atime = time.Unix(0, winSys.LastAccessTime.Nanoseconds())
 		wtime = time.Unix(0, winSys.LastWriteTime.Nanoseconds())
 		errorMSG = nil
-		if updateWindowsFileOwner {
+		if fileOwner {
+			var err error
 			owner, err = getFileOwner(path)
 			if err != nil {
-				errorMSG = fmt.Errorf("cannot get the file owner for %s error message: %v", path, err)
+				errorMSG = fmt.Errorf("%w for %s error message: %w", errOwnerLookup, path, err)
 			}
 		}
 	}
 	return ctime, atime, wtime, owner, errorMSG
 }
 
+// lstat of a directory entry, on windows the info comes with the directory listing
+func lstatAt(dir *os.File, entry os.DirEntry) (os.FileInfo, error) {
+	return entry.Info()
+}
+
 func getFileOwner(path string) (string, error) {
 	// Open the file to get its handle
 	file, err := os.Open(path)
 	if err != nil {
-		return "", fmt.Errorf("failed to open %v file, error: %v", path, err)
+		return "", fmt.Errorf("failed to open %v file, error: %w", path, err)
 	}
 	defer file.Close()
 
@@ -55,13 +60,13 @@ func getFileOwner(path string) (string, error) {
 		windows.OWNER_SECURITY_INFORMATION,
 	)
 	if err != nil {
-		return "", fmt.Errorf("failed to get security info for the file:%v, error: %v", path, err)
+		return "", fmt.Errorf("failed to get security info for the file:%v, error: %w", path, err)
 	}
 
 	// Get the owner SID from the security descriptor
 	ownerSid, _, err := securityDescriptor.Owner()
 	if err != nil {
-		return "", fmt.Errorf("failed to get owner SID for the file: %v, error: %v", path, err)
+		return "", fmt.Errorf("failed to get owner SID for the file: %v, error: %w", path, err)
 	}
 
 	// Allocate buffers to hold the account name and domain name
@@ -76,7 +81,7 @@ func getFileOwner(path string) (string, error) {
 		// Lookup the account name and domain associated with the SID
 		err = windows.LookupAccountSid(nil, ownerSid, &accountName[0], &accountNameSize, &domainName[0], &domainNameSize, &sidType)
 		if err != nil {
-			return "", fmt.Errorf("failed to lookup account SID: %v", err)
+			return "", fmt.Errorf("failed to lookup account SID: %w", err)
 		}
 
 		// Convert account name and domain name from UTF-16 to string
@@ -0,0 +1,107 @@
+//go:build windows || !windows
+// +build windows !windows
+
+package scanner
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+)
+
+// FileTimesFS is the optional stat extension of an fs.FS scanned through Options.FS. fs.FileInfo only has
+// the last write time, an FS implementing it also gives the creation and last access times and the owner.
+type FileTimesFS interface {
+	fs.FS
+	FileTimes(name string, info fs.FileInfo) (ctime time.Time, atime time.Time, owner string, err error)
+}
+
+// the filesystem calls of the walker, on the local disks or on an fs.FS
+type scanFS interface {
+	stat(name string) (fs.FileInfo, error)  // folder stat, following links
+	lstat(name string) (fs.FileInfo, error) // file stat for StatFile
+	openDir(name string) (scanDir, error)
+	open(name string) (fs.File, error) // file contents, for the archive members
+	join(dir string, name string) string
+	fileTimes(name string, info fs.FileInfo) (time.Time, time.Time, time.Time, string, error)
+}
+
+// an open folder being listed
+type scanDir interface {
+	ReadDir(n int) ([]fs.DirEntry, error)
+	lstat(entry fs.DirEntry) (fs.FileInfo, error) // stat of one of the listed entries
+	Close() error
+}
+
+// the local disks, with the platform specific single stat per entry and the owner lookup
+type osFS struct {
+	fileOwner bool
+}
+
+func (osFS) stat(name string) (fs.FileInfo, error)  { return os.Stat(name) }
+func (osFS) lstat(name string) (fs.FileInfo, error) { return os.Lstat(name) }
+func (osFS) join(dir string, name string) string    { return filepath.Join(dir, name) }
+func (osFS) open(name string) (fs.File, error)      { return os.Open(name) }
+
+func (osFS) openDir(name string) (scanDir, error) {
+	dir, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return osDir{dir}, nil
+}
+
+func (fsys osFS) fileTimes(name string, info fs.FileInfo) (time.Time, time.Time, time.Time, string, error) {
+	return getFileTimes(name, info, fsys.fileOwner)
+}
+
+type osDir struct {
+	*os.File
+}
+
+func (dir osDir) lstat(entry fs.DirEntry) (fs.FileInfo, error) {
+	return lstatAt(dir.File, entry)
+}
+
+// any fs.FS, e.g. fstest.MapFS or zip.Reader. The paths are slash separated and relative to its root ".".
+type ioFS struct {
+	fsys fs.FS
+}
+
+func (fsys ioFS) stat(name string) (fs.FileInfo, error)  { return fs.Stat(fsys.fsys, name) }
+func (fsys ioFS) lstat(name string) (fs.FileInfo, error) { return fs.Stat(fsys.fsys, name) }
+func (ioFS) join(dir string, name string) string         { return path.Join(dir, name) }
+func (fsys ioFS) open(name string) (fs.File, error)      { return fsys.fsys.Open(name) }
+
+func (fsys ioFS) openDir(name string) (scanDir, error) {
+	file, err := fsys.fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	dir, ok := file.(fs.ReadDirFile)
+	if !ok {
+		file.Close()
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: errors.New("not implemented")}
+	}
+	return ioDir{dir}, nil
+}
+
+func (fsys ioFS) fileTimes(name string, info fs.FileInfo) (time.Time, time.Time, time.Time, string, error) {
+	timesFS, ok := fsys.fsys.(FileTimesFS)
+	if !ok {
+		return time.Time{}, time.Time{}, info.ModTime(), "", nil
+	}
+	ctime, atime, owner, err := timesFS.FileTimes(name, info)
+	return ctime, atime, info.ModTime(), owner, err
+}
+
+type ioDir struct {
+	fs.ReadDirFile
+}
+
+func (ioDir) lstat(entry fs.DirEntry) (fs.FileInfo, error) {
+	return entry.Info()
+}
@@ -0,0 +1,109 @@
+//go:build windows || !windows
+// +build windows !windows
+
+package scanner
+
+import (
+	"context"
+	"sync"
+)
+
+// FolderQueue is the work queue of the folders waiting to be read by the readFolder workers.
+// It is a LIFO stack, so the traversal goes depth first and the queue stays small on wide trees.
+type FolderQueue struct {
+	mutex     sync.Mutex
+	cond      *sync.Cond
+	folders   []Folder
+	reading   int // folders taken by a worker and not finished yet
+	peakDepth int
+}
+
+func NewFolderQueue() *FolderQueue {
+	queue := new(FolderQueue)
+	queue.cond = sync.NewCond(&queue.mutex)
+	return queue
+}
+
+// adds a folder to be read
+func (queue *FolderQueue) Push(path string, depth int) {
+	queue.mutex.Lock()
+	queue.folders = append(queue.folders, Folder{Path: path, ObjectDepth: depth})
+	if len(queue.folders) > queue.peakDepth {
+		queue.peakDepth = len(queue.folders)
+	}
+	queue.mutex.Unlock()
+	queue.cond.Signal()
+}
+
+// takes the latest folder, waiting while other workers may still push new ones.
+// Returns false once the queue is empty and no folder is being read anymore.
+func (queue *FolderQueue) Pop() (Folder, bool) {
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+	for len(queue.folders) == 0 {
+		if queue.reading == 0 {
+			return Folder{}, false
+		}
+		queue.cond.Wait()
+	}
+	last := len(queue.folders) - 1
+	folder := queue.folders[last]
+	queue.folders = queue.folders[:last]
+	queue.reading++
+	return folder, true
+}
+
+// marks a folder taken with Pop as finished
+func (queue *FolderQueue) Done() {
+	queue.mutex.Lock()
+	queue.reading--
+	finished := queue.reading == 0 && len(queue.folders) == 0
+	queue.mutex.Unlock()
+	if finished {
+		// wake up the idle workers so that they can exit
+		queue.cond.Broadcast()
+	}
+}
+
+// returns the number of folders waiting in the queue
+func (queue *FolderQueue) Len() int {
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+	return len(queue.folders)
+}
+
+// returns the highest number of folders waiting in the queue so far
+func (queue *FolderQueue) PeakDepth() int {
+	queue.mutex.Lock()
+	defer queue.mutex.Unlock()
+	return queue.peakDepth
+}
+
+// starts the readFolder workers, wg is done when the whole queue has been read.
+// workerLimit optionally caps the number of workers reading at the same time.
+func (scanner *Scanner) startFolderWorkers(ctx context.Context, workers int, workerLimit *WorkerLimit, queue *FolderQueue, results chan<- ObjectInfo, wg *sync.WaitGroup) {
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				if workerLimit != nil {
+					workerLimit.Acquire()
+				}
+				folder, ok := queue.Pop()
+				if ok {
+					scanner.counters.ActiveWorkers.Add(1)
+					scanner.readFolder(ctx, folder.Path, results, folder.ObjectDepth, queue)
+					scanner.counters.ActiveWorkers.Add(-1)
+					queue.Done()
+				}
+				if workerLimit != nil {
+					workerLimit.Release()
+				}
+				if !ok {
+					return
+				}
+			}
+		}()
+	}
+}
@@ -0,0 +1,101 @@
+//go:build windows || !windows
+// +build windows !windows
+
+package scanner
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestFolderQueueLIFO(t *testing.T) {
+	tests := []struct {
+		name   string
+		pushed []string
+		popped []string
+	}{
+		{"single", []string{"a"}, []string{"a"}},
+		{"latest first", []string{"a", "b", "c"}, []string{"c", "b", "a"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			queue := NewFolderQueue()
+			for i, path := range test.pushed {
+				queue.Push(path, i+1)
+			}
+			if queue.Len() != len(test.pushed) || queue.PeakDepth() != len(test.pushed) {
+				t.Fatalf("Len() = %d, PeakDepth() = %d, want %d", queue.Len(), queue.PeakDepth(), len(test.pushed))
+			}
+			for _, want := range test.popped {
+				folder, ok := queue.Pop()
+				if !ok || folder.Path != want {
+					t.Fatalf("Pop() = %q, %v, want %q, true", folder.Path, ok, want)
+				}
+				queue.Done()
+			}
+			if _, ok := queue.Pop(); ok {
+				t.Fatal("Pop() on an empty queue with no folder being read returned true")
+			}
+		})
+	}
+}
+
+func TestFolderQueueDepthAndPeak(t *testing.T) {
+	queue := NewFolderQueue()
+	queue.Push("root", 1)
+	folder, _ := queue.Pop()
+	queue.Push("root/a", folder.ObjectDepth+1)
+	queue.Push("root/b", folder.ObjectDepth+1)
+	queue.Done()
+	folder, _ = queue.Pop()
+	if folder.Path != "root/b" || folder.ObjectDepth != 2 {
+		t.Fatalf("Pop() = %+v, want root/b at depth 2", folder)
+	}
+	queue.Done()
+	if queue.PeakDepth() != 2 {
+		t.Fatalf("PeakDepth() = %d, want 2", queue.PeakDepth())
+	}
+}
+
+// an idle worker waits while another one reads a folder, it gets the folders pushed meanwhile
+// and returns once the queue is empty and nothing is being read anymore
+func TestFolderQueueWaitsForReadingWorkers(t *testing.T) {
+	queue := NewFolderQueue()
+	queue.Push("root", 1)
+	if _, ok := queue.Pop(); !ok {
+		t.Fatal("Pop() returned false")
+	}
+
+	var popped []string
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			folder, ok := queue.Pop()
+			if !ok {
+				return
+			}
+			popped = append(popped, folder.Path)
+			queue.Done()
+		}
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+	queue.Push("root/sub", 2)
+	queue.Done()
+	finished := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(finished)
+	}()
+	select {
+	case <-finished:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the idle worker did not return once the queue was finished")
+	}
+	if len(popped) != 1 || popped[0] != "root/sub" {
+		t.Fatalf("popped %v, want [root/sub]", popped)
+	}
+}
@@ -0,0 +1,130 @@
+//go:build windows || !windows
+// +build windows !windows
+
+package scanner
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"sync"
+	"time"
+)
+
+// ErrFSTimeout is wrapped by the errors of the filesystem calls abandoned after Options.FSTimeout
+var ErrFSTimeout = errors.New("filesystem call timed out")
+
+// runs a filesystem call with the FSTimeout limit of the scanner. A call which doesn't return in time is
+// left running in its own goroutine, so a hung network mount costs a goroutine instead of the whole scan.
+func withFSTimeout[T any](scanner *Scanner, op string, path string, call func() (T, error)) (T, error) {
+	fsTimeout := scanner.options.FSTimeout
+	if fsTimeout <= 0 {
+		return call()
+	}
+
+	type callResult struct {
+		value T
+		err   error
+	}
+	resultChan := make(chan callResult, 1) // buffered, so an abandoned call can still finish and exit
+	go func() {
+		value, err := call()
+		resultChan <- callResult{value, err}
+	}()
+
+	timer := time.NewTimer(fsTimeout)
+	defer timer.Stop()
+	select {
+	case result := <-resultChan:
+		return result.value, result.err
+	case <-timer.C:
+		scanner.abandonedCalls.Add(1)
+		go func() {
+			result := <-resultChan
+			// nobody gets a late result, e.g. a folder opened after the timeout, so it is closed here
+			if closer, ok := any(result.value).(io.Closer); ok && result.err == nil {
+				closer.Close()
+			}
+			scanner.abandonedCalls.Add(-1)
+		}()
+		var zero T
+		return zero, fmt.Errorf("%s %s: %w after %v", op, path, ErrFSTimeout, fsTimeout)
+	}
+}
+
+// an open folder whose Close waits for the calls still using it. A ReadDir or lstat abandoned by
+// withFSTimeout keeps running on the folder fd, which must not be closed and reused under it.
+type guardedDir struct {
+	scanDir
+	mutex  sync.Mutex
+	calls  int // calls running on the folder
+	closed bool
+}
+
+// takes the folder for a call, false once it is closed
+func (dir *guardedDir) acquire() bool {
+	dir.mutex.Lock()
+	defer dir.mutex.Unlock()
+	if dir.closed {
+		return false
+	}
+	dir.calls++
+	return true
+}
+
+// ends a call taken with acquire, the last call after Close closes the folder
+func (dir *guardedDir) release() {
+	dir.mutex.Lock()
+	dir.calls--
+	closeNow := dir.closed && dir.calls == 0
+	dir.mutex.Unlock()
+	if closeNow {
+		dir.scanDir.Close()
+	}
+}
+
+func (dir *guardedDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	if !dir.acquire() {
+		return nil, fs.ErrClosed
+	}
+	defer dir.release()
+	return dir.scanDir.ReadDir(n)
+}
+
+func (dir *guardedDir) lstat(entry fs.DirEntry) (fs.FileInfo, error) {
+	if !dir.acquire() {
+		return nil, fs.ErrClosed
+	}
+	defer dir.release()
+	return dir.scanDir.lstat(entry)
+}
+
+// closes the folder now, or hands the close to the last abandoned call still running on it
+func (dir *guardedDir) Close() error {
+	dir.mutex.Lock()
+	if dir.closed {
+		dir.mutex.Unlock()
+		return fs.ErrClosed
+	}
+	dir.closed = true
+	closeNow := dir.calls == 0
+	dir.mutex.Unlock()
+	if closeNow {
+		return dir.scanDir.Close()
+	}
+	return nil
+}
+
+// getFileTimes with the FSTimeout limit, the owner lookup may still go to the file server
+func (scanner *Scanner) getFileTimesWithTimeout(path string, info fs.FileInfo) (time.Time, time.Time, time.Time, string, error) {
+	type fileTimes struct {
+		ctime, atime, wtime time.Time
+		owner               string
+	}
+	times, err := withFSTimeout(scanner, "stat", path, func() (fileTimes, error) {
+		ctime, atime, wtime, owner, err := scanner.fs.fileTimes(path, info)
+		return fileTimes{ctime, atime, wtime, owner}, err
+	})
+	return times.ctime, times.atime, times.wtime, times.owner, err
+}
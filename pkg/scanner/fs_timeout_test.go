@@ -0,0 +1,137 @@
+//go:build windows || !windows
+// +build windows !windows
+
+package scanner
+
+import (
+	"errors"
+	"io/fs"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// scanDir whose calls block until release is closed, counting the Close calls
+type blockingDir struct {
+	release chan struct{}
+	closes  atomic.Int32
+}
+
+func (dir *blockingDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	<-dir.release
+	return nil, nil
+}
+
+func (dir *blockingDir) lstat(entry fs.DirEntry) (fs.FileInfo, error) {
+	<-dir.release
+	return nil, nil
+}
+
+func (dir *blockingDir) Close() error {
+	dir.closes.Add(1)
+	return nil
+}
+
+// waits until condition is true, failing the test after a few seconds
+func waitFor(t *testing.T, what string, condition func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestWithFSTimeout(t *testing.T) {
+	tests := []struct {
+		name      string
+		fsTimeout time.Duration
+		callTime  time.Duration
+		wantValue int
+		wantErr   error
+	}{
+		{"no timeout", 0, 30 * time.Millisecond, 1, nil},
+		{"in time", time.Second, 0, 1, nil},
+		{"call error", time.Second, 0, 0, fs.ErrPermission},
+		{"timed out", 10 * time.Millisecond, 200 * time.Millisecond, 0, ErrFSTimeout},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			scanner := New(Options{FSTimeout: test.fsTimeout})
+			value, err := withFSTimeout(scanner, "stat", "x", func() (int, error) {
+				time.Sleep(test.callTime)
+				if test.wantErr == fs.ErrPermission {
+					return 0, fs.ErrPermission
+				}
+				return 1, nil
+			})
+			if value != test.wantValue || !errors.Is(err, test.wantErr) {
+				t.Fatalf("withFSTimeout() = %d, %v, want %d, %v", value, err, test.wantValue, test.wantErr)
+			}
+			if test.wantErr == ErrFSTimeout {
+				if scanner.AbandonedCalls() != 1 {
+					t.Fatalf("AbandonedCalls() = %d right after the timeout, want 1", scanner.AbandonedCalls())
+				}
+				waitFor(t, "the abandoned call", func() bool { return scanner.AbandonedCalls() == 0 })
+			}
+		})
+	}
+}
+
+// a folder opened after the timeout is closed by the abandoned goroutine, nobody else gets it
+func TestWithFSTimeoutClosesLateResult(t *testing.T) {
+	scanner := New(Options{FSTimeout: 10 * time.Millisecond})
+	dir := &blockingDir{release: make(chan struct{})}
+	opened := make(chan struct{})
+	_, err := withFSTimeout(scanner, "open", "x", func() (scanDir, error) {
+		<-opened
+		return dir, nil
+	})
+	if !errors.Is(err, ErrFSTimeout) {
+		t.Fatalf("withFSTimeout() error = %v, want ErrFSTimeout", err)
+	}
+	close(opened)
+	waitFor(t, "the late folder to be closed", func() bool { return dir.closes.Load() == 1 })
+}
+
+// the Close of a folder with an abandoned ReadDir waits for the call, the fd must not be reused under it
+func TestGuardedDirClose(t *testing.T) {
+	scanner := New(Options{FSTimeout: 50 * time.Millisecond})
+	dir := &blockingDir{release: make(chan struct{})}
+	guarded := &guardedDir{scanDir: dir}
+	if _, err := withFSTimeout(scanner, "readdir", "x", func() ([]fs.DirEntry, error) { return guarded.ReadDir(100) }); !errors.Is(err, ErrFSTimeout) {
+		t.Fatalf("ReadDir error = %v, want ErrFSTimeout", err)
+	}
+	if err := guarded.Close(); err != nil {
+		t.Fatalf("Close() = %v", err)
+	}
+	if dir.closes.Load() != 0 {
+		t.Fatal("the folder was closed while the abandoned ReadDir was still running")
+	}
+	if _, err := guarded.lstat(nil); !errors.Is(err, fs.ErrClosed) {
+		t.Fatalf("lstat() after Close = %v, want fs.ErrClosed", err)
+	}
+	close(dir.release)
+	waitFor(t, "the close handed to the abandoned call", func() bool { return dir.closes.Load() == 1 })
+	if err := guarded.Close(); !errors.Is(err, fs.ErrClosed) {
+		t.Fatalf("second Close() = %v, want fs.ErrClosed", err)
+	}
+	if dir.closes.Load() != 1 {
+		t.Fatalf("the folder was closed %d times", dir.closes.Load())
+	}
+}
+
+func TestGuardedDirCloseIdle(t *testing.T) {
+	dir := &blockingDir{release: make(chan struct{})}
+	close(dir.release)
+	guarded := &guardedDir{scanDir: dir}
+	if _, err := guarded.ReadDir(10); err != nil {
+		t.Fatal(err)
+	}
+	guarded.Close()
+	if dir.closes.Load() != 1 {
+		t.Fatalf("Close() without running calls closed the folder %d times, want 1", dir.closes.Load())
+	}
+}
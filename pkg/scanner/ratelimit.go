@@ -0,0 +1,91 @@
+//go:build windows || !windows
+// +build windows !windows
+
+package scanner
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+)
+
+// RateLimiter is a token bucket shared by all the readFolder workers. A rate of 0 means unlimited.
+type RateLimiter struct {
+	mutex  sync.Mutex
+	rate   float64 // tokens added per second
+	tokens float64
+	last   time.Time
+}
+
+func (limiter *RateLimiter) Rate() float64 {
+	limiter.mutex.Lock()
+	defer limiter.mutex.Unlock()
+	return limiter.rate
+}
+
+// changes the rate, the bucket starts again from one second worth of tokens
+func (limiter *RateLimiter) SetRate(rate float64) {
+	limiter.mutex.Lock()
+	limiter.rate = rate
+	limiter.tokens = rate
+	limiter.last = time.Now()
+	limiter.mutex.Unlock()
+}
+
+// takes n tokens, sleeping until they are available or ctx is done.
+// Bigger requests than the bucket are allowed and just wait longer.
+func (limiter *RateLimiter) Wait(ctx context.Context, n int) error {
+	limiter.mutex.Lock()
+	if limiter.rate <= 0 {
+		limiter.mutex.Unlock()
+		return nil
+	}
+	now := time.Now()
+	// refill, the bucket holds at most one second worth of tokens
+	limiter.tokens += now.Sub(limiter.last).Seconds() * limiter.rate
+	if limiter.tokens > limiter.rate {
+		limiter.tokens = limiter.rate
+	}
+	limiter.last = now
+	limiter.tokens -= float64(n)
+	var wait time.Duration
+	if limiter.tokens < 0 {
+		wait = time.Duration(-limiter.tokens / limiter.rate * float64(time.Second))
+	}
+	limiter.mutex.Unlock()
+
+	if wait == 0 {
+		return nil
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// wraps a reader so that every read goes through the read bytes limiter
+type limitedReader struct {
+	ctx     context.Context
+	reader  io.Reader
+	limiter *RateLimiter
+}
+
+// NewLimitedReader returns a reader taking a token of the limiter for every byte read
+func NewLimitedReader(ctx context.Context, reader io.Reader, limiter *RateLimiter) io.Reader {
+	return &limitedReader{ctx: ctx, reader: reader, limiter: limiter}
+}
+
+func (reader *limitedReader) Read(p []byte) (int, error) {
+	n, err := reader.reader.Read(p)
+	if n > 0 {
+		if waitErr := reader.limiter.Wait(reader.ctx, n); waitErr != nil && err == nil {
+			err = waitErr
+		}
+	}
+	return n, err
+}
@@ -0,0 +1,72 @@
+//go:build windows || !windows
+// +build windows !windows
+
+package scanner
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRateLimiterWait(t *testing.T) {
+	tests := []struct {
+		name     string
+		rate     float64
+		requests []int
+		minWait  time.Duration
+		maxWait  time.Duration
+	}{
+		{"unlimited", 0, []int{1 << 30, 1 << 30}, 0, 50 * time.Millisecond},
+		{"within the bucket", 100, []int{50, 50}, 0, 50 * time.Millisecond},
+		{"beyond the bucket", 100, []int{100, 20}, 150 * time.Millisecond, time.Second},
+		{"request bigger than the bucket", 100, []int{130}, 250 * time.Millisecond, time.Second},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			limiter := new(RateLimiter)
+			limiter.SetRate(test.rate)
+			start := time.Now()
+			for _, n := range test.requests {
+				if err := limiter.Wait(context.Background(), n); err != nil {
+					t.Fatalf("Wait(%d) error: %v", n, err)
+				}
+			}
+			if waited := time.Since(start); waited < test.minWait || waited > test.maxWait {
+				t.Fatalf("waited %v, want between %v and %v", waited, test.minWait, test.maxWait)
+			}
+		})
+	}
+}
+
+func TestRateLimiterWaitCancelled(t *testing.T) {
+	limiter := new(RateLimiter)
+	limiter.SetRate(1)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := limiter.Wait(ctx, 100); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Wait() = %v, want the context error", err)
+	}
+}
+
+func TestLimitedReader(t *testing.T) {
+	limiter := new(RateLimiter)
+	limiter.SetRate(1000)
+	data := strings.Repeat("x", 1200)
+	start := time.Now()
+	var out bytes.Buffer
+	if _, err := io.Copy(&out, NewLimitedReader(context.Background(), strings.NewReader(data), limiter)); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != data {
+		t.Fatal("the limited reader changed the data")
+	}
+	// one second worth of bytes is available at once, the other 200 take 0.2s
+	if waited := time.Since(start); waited < 150*time.Millisecond {
+		t.Fatalf("read 1200 bytes at 1000/s in %v", waited)
+	}
+}
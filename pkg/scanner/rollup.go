@@ -0,0 +1,76 @@
+//go:build windows || !windows
+// +build windows !windows
+
+package scanner
+
+import (
+	"strings"
+	"time"
+)
+
+// FolderTotals holds the values of a folder calculated from everything below it
+type FolderTotals struct {
+	TotalCalFolderSize int       // size of all the files in the folder and its subfolders
+	CalLastWriteTime   time.Time // latest LastWriteTime of the folder and its subfolders
+}
+
+// Rollup carries the ThisFolderSize and LastWriteTime of every folder up to all its ancestors
+// within the scanned root, giving the TotalCalFolderSize and CalLastWriteTime of each folder.
+// The folders can be added in any order. The root is a local path, or "." for an Options.FS scan.
+type Rollup struct {
+	root   string
+	totals map[string]FolderTotals
+}
+
+// NewRollup returns an empty Rollup for the folders below root
+func NewRollup(root string) *Rollup {
+	return &Rollup{root: root, totals: make(map[string]FolderTotals)}
+}
+
+// adds the own size and last write time of a folder to it and to all its ancestors
+func (rollup *Rollup) Add(path string, size int, lastWriteTime time.Time) {
+	for {
+		if folderInfo, exists := rollup.totals[path]; exists {
+			// If it exists, update the existing struct
+			folderInfo.TotalCalFolderSize += size // Modify size
+			if lastWriteTime.After(folderInfo.CalLastWriteTime) {
+				folderInfo.CalLastWriteTime = lastWriteTime // Update last write time
+			}
+			rollup.totals[path] = folderInfo // Save back updated struct
+		} else {
+			// If it does not exist, initialize and insert a new struct
+			rollup.totals[path] = FolderTotals{
+				TotalCalFolderSize: size,          // Initial size
+				CalLastWriteTime:   lastWriteTime, // Current time
+			}
+		}
+
+		if path == rollup.root {
+			break // the scanned root is the last folder
+		}
+		// Find the last separator (either '/' or '\')
+		lastSeparator := strings.LastIndexAny(path, `\/`)
+		if lastSeparator == -1 {
+			if rollup.root == "." {
+				path = "." // the top entries of an io/fs scan sit in its "." root
+				continue
+			}
+			break // No more separators, so we're at the root
+		}
+
+		if rollup.root == "." {
+			path = path[:lastSeparator] // io/fs paths are all below the "." root
+		} else if rollup.root == path[:lastSeparator+1] {
+			path = path[:lastSeparator+1] // the root keeps its separator, e.g. C:\ or /
+		} else if path[:lastSeparator] < rollup.root {
+			break // we have crossed the user provided directory
+		} else {
+			path = path[:lastSeparator] // Move up to the parent directory
+		}
+	}
+}
+
+// returns the calculated totals of every folder added so far and of their ancestors
+func (rollup *Rollup) Totals() map[string]FolderTotals {
+	return rollup.totals
+}
@@ -0,0 +1,81 @@
+//go:build windows || !windows
+// +build windows !windows
+
+package scanner
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRollup(t *testing.T) {
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	t1, t2, t3 := t0.Add(time.Hour), t0.Add(2*time.Hour), t0.Add(3*time.Hour)
+	type folder struct {
+		path          string
+		size          int
+		lastWriteTime time.Time
+	}
+	tests := []struct {
+		name    string
+		root    string
+		folders []folder
+		want    map[string]FolderTotals
+	}{
+		{"unix root", "/data", []folder{
+			{"/data/a/b", 4, t3}, {"/data", 1, t1}, {"/data/a", 2, t2}, {"/data/c", 8, t0},
+		}, map[string]FolderTotals{
+			"/data":     {15, t3},
+			"/data/a":   {6, t3},
+			"/data/a/b": {4, t3},
+			"/data/c":   {8, t0},
+		}},
+		{"windows root", `C:\Temp`, []folder{
+			{`C:\Temp`, 1, t0}, {`C:\Temp\a`, 2, t2}, {`C:\Temp\a\b`, 4, t1},
+		}, map[string]FolderTotals{
+			`C:\Temp`:     {7, t2},
+			`C:\Temp\a`:   {6, t2},
+			`C:\Temp\a\b`: {4, t1},
+		}},
+		{"drive root", `C:\`, []folder{
+			{`C:\`, 1, t0}, {`C:\a`, 2, t1}, {`C:\a\b`, 4, t2},
+		}, map[string]FolderTotals{
+			`C:\`:    {7, t2},
+			`C:\a`:   {6, t2},
+			`C:\a\b`: {4, t2},
+		}},
+		{"filesystem root", "/", []folder{
+			{"/", 1, t0}, {"/a", 2, t1}, {"/a/b", 4, t0},
+		}, map[string]FolderTotals{
+			"/":    {7, t1},
+			"/a":   {6, t1},
+			"/a/b": {4, t0},
+		}},
+		{"io/fs root", ".", []folder{
+			{"a/b", 4, t1}, {".", 1, t0}, {"a", 2, t0}, {"c", 8, t2},
+		}, map[string]FolderTotals{
+			".":   {15, t2},
+			"a":   {6, t1},
+			"a/b": {4, t1},
+			"c":   {8, t2},
+		}},
+		{"root with a similar sibling", "/data/a", []folder{
+			{"/data/a", 1, t0}, {"/data/a/x", 2, t1},
+		}, map[string]FolderTotals{
+			"/data/a":   {3, t1},
+			"/data/a/x": {2, t1},
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rollup := NewRollup(test.root)
+			for _, folder := range test.folders {
+				rollup.Add(folder.path, folder.size, folder.lastWriteTime)
+			}
+			if got := rollup.Totals(); !reflect.DeepEqual(got, test.want) {
+				t.Fatalf("Totals() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,383 @@
+//go:build windows || !windows
+// +build windows !windows
+
+// Package scanner walks folder trees and gathers the meta data of every folder and file in them:
+// size, creation/last write/last access times, owner and errors. It is the scanning engine of
+// FolderInsight, which writes the results to its SQLite report DB.
+//
+//	results := make(chan scanner.ObjectInfo, 1000)
+//	folderScanner := scanner.New(scanner.Options{Workers: 32})
+//	go func() {
+//		folderScanner.Scan(ctx, results, scanner.Folder{Path: `C:\Temp`, ObjectDepth: 1})
+//		close(results)
+//	}()
+//	for info := range results {
+//		...
+//	}
+//
+// Options.FS replaces the local disks with any io/fs.FS, e.g. a zip.Reader or a fstest.MapFS. The scan
+// then starts at Folder{Path: ".", ObjectDepth: 1} and the paths are slash separated below it.
+package scanner
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// entries read from a directory at once
+const readDirChunkSize = 1024
+
+// default number of folders read in parallel
+const DefaultWorkers = 64
+
+// Represents the scan data gathered for a folder or a file
+type ObjectInfo struct {
+	ObjType            string // d- directory, f- file, l- link, o- other, a- archive member
+	Path               string
+	ObjectDepth        int
+	FileSize           int //size of a file
+	CompressedSize     int // stored size of an archive member, 0 when the whole archive is compressed
+	ThisFolderSize     int //folder size with all the containing files only
+	TotalCalFolderSize int //Total folder size(with all the containing files & subfolders), see Rollup
+	HasError           bool
+	ErrorMessage       string
+	ErrorCode          string // errno name like EACCES, see ClassifyError
+	ErrorStage         string // stat, readdir or owner
+	Owner              string
+	CreationTime       time.Time
+	LastWriteTime      time.Time
+	CalLastWriteTime   time.Time // latest LastWriteTime of the folder and everything below it, see Rollup
+	LastAccessTime     time.Time
+}
+
+// Represents a folder to read, or a file to stat again with StatFile
+type Folder struct {
+	Path        string
+	ObjectDepth int // 1 for the root of the scan, files have the depth of their folder
+}
+
+// Options of a Scanner, the zero value reads 64 folders in parallel without any limit
+type Options struct {
+	Workers           int           // folders read in parallel, DefaultWorkers when 0
+	AutoTune          bool          // adjust the active workers to the storage latency, between MinWorkers and MaxWorkers
+	MinWorkers        int           // 4 when 0
+	MaxWorkers        int           // 512 when 0
+	FSTimeout         time.Duration // give up on a stat/readdir call after this long, 0 waits forever
+	LargeDirThreshold int           // folders with more entries are logged, 0 disables it
+	FileOwner         bool          // look up the owner name of every entry (windows only, unix always has uid:gid)
+	FS                fs.FS         // filesystem to scan instead of the local disks, e.g. fstest.MapFS or zip.Reader
+	ExpandArchives    bool          // record the files in .zip, .tar and .tar.gz files as "a" rows below them
+	MaxArchiveDepth   int           // archive nesting levels expanded, DefaultMaxArchiveDepth when 0
+	MaxArchiveSize    int64         // uncompressed member bytes read from one archive, DefaultMaxArchiveSize when 0
+	OpsLimiter        *RateLimiter  // caps the filesystem calls per second, nil is unlimited
+	ReadLimiter       *RateLimiter  // caps the archive bytes read per second, nil is unlimited
+	Counters          *Counters     // counters to update, e.g. shared with a progress reporter, nil uses new ones
+	Logger            *slog.Logger  // failed entries as errors, large directories as info and the details as debug, nil discards them
+}
+
+// Counters of a scan, updated while the scan is running
+type Counters struct {
+	Folders        atomic.Int64
+	Files          atomic.Int64
+	Bytes          atomic.Int64
+	Errors         atomic.Int64
+	LargeFolders   atomic.Int64 // folders above Options.LargeDirThreshold entries
+	ArchiveMembers atomic.Int64 // "a" rows of Options.ExpandArchives
+	ActiveWorkers  atomic.Int64 // workers reading a folder right now
+
+	errorClasses sync.Map // ErrorClass -> *atomic.Int64
+}
+
+// Scanner reads folder trees with a pool of workers. It holds no global state, so several
+// scanners can run side by side, but a Scanner runs one Scan at a time.
+type Scanner struct {
+	options        Options
+	fs             scanFS
+	counters       *Counters
+	latency        FSLatency
+	abandonedCalls atomic.Int64 // calls still blocked in the background after their timeout
+
+	queueMutex   sync.Mutex
+	queue        *FolderQueue
+	pendingMutex sync.Mutex
+	pending      []Folder // folders not read because the scan was cancelled
+}
+
+// New returns a Scanner with the given options
+func New(options Options) *Scanner {
+	if options.Workers < 1 {
+		options.Workers = DefaultWorkers
+	}
+	if options.MinWorkers < 1 {
+		options.MinWorkers = 4
+	}
+	if options.MaxWorkers < 1 {
+		options.MaxWorkers = 512
+	}
+	if options.MaxWorkers < options.MinWorkers {
+		options.MaxWorkers = options.MinWorkers
+	}
+	if options.MaxArchiveDepth < 1 {
+		options.MaxArchiveDepth = DefaultMaxArchiveDepth
+	}
+	if options.MaxArchiveSize < 1 {
+		options.MaxArchiveSize = DefaultMaxArchiveSize
+	}
+	if options.OpsLimiter == nil {
+		options.OpsLimiter = new(RateLimiter)
+	}
+	if options.ReadLimiter == nil {
+		options.ReadLimiter = new(RateLimiter)
+	}
+	if options.Logger == nil {
+		options.Logger = slog.New(discardHandler{})
+	}
+	scanner := &Scanner{options: options, counters: options.Counters}
+	if options.FS != nil {
+		scanner.fs = ioFS{options.FS}
+	} else {
+		scanner.fs = osFS{fileOwner: options.FileOwner}
+	}
+	if scanner.counters == nil {
+		scanner.counters = new(Counters)
+	}
+	return scanner
+}
+
+// slog.Handler of the nil Options.Logger, which drops every record
+type discardHandler struct{}
+
+func (discardHandler) Enabled(context.Context, slog.Level) bool   { return false }
+func (discardHandler) Handle(context.Context, slog.Record) error  { return nil }
+func (handler discardHandler) WithAttrs([]slog.Attr) slog.Handler { return handler }
+func (handler discardHandler) WithGroup(string) slog.Handler      { return handler }
+
+// Scan reads the given folders and everything below them and sends one ObjectInfo per folder and
+// file to results, which is not closed. It returns once the whole tree has been read, or with the
+// context error once ctx is cancelled, in which case Pending returns the folders not read yet.
+func (scanner *Scanner) Scan(ctx context.Context, results chan<- ObjectInfo, folders ...Folder) error {
+	queue := NewFolderQueue()
+	for _, folder := range folders {
+		queue.Push(folder.Path, folder.ObjectDepth)
+	}
+	scanner.queueMutex.Lock()
+	scanner.queue = queue
+	scanner.queueMutex.Unlock()
+	scanner.pendingMutex.Lock()
+	scanner.pending = nil
+	scanner.pendingMutex.Unlock()
+
+	var wg sync.WaitGroup
+	if scanner.options.AutoTune {
+		// all the workers are started, the limit decides how many of them are reading
+		workerLimit := NewWorkerLimit(min(max(scanner.options.Workers, scanner.options.MinWorkers), scanner.options.MaxWorkers))
+		tunerDone := make(chan struct{})
+		defer close(tunerDone)
+		scanner.startFolderWorkers(ctx, scanner.options.MaxWorkers, workerLimit, queue, results, &wg)
+		go scanner.autoTuneWorkers(workerLimit, tunerDone)
+	} else {
+		scanner.startFolderWorkers(ctx, scanner.options.Workers, nil, queue, results, &wg)
+	}
+	wg.Wait()
+	return ctx.Err()
+}
+
+// StatFile stats a single file again, e.g. one which failed in an earlier scan, without reading its folder
+func (scanner *Scanner) StatFile(ctx context.Context, file Folder) ObjectInfo {
+	newFileData := new(ObjectInfo)
+	newFileData.ObjType = "f"
+	newFileData.Path = file.Path
+	newFileData.ObjectDepth = file.ObjectDepth
+
+	scanner.options.OpsLimiter.Wait(ctx, 1)
+	callStart := time.Now()
+	info, err := withFSTimeout(scanner, "lstat", file.Path, func() (fs.FileInfo, error) { return scanner.fs.lstat(file.Path) })
+	scanner.latency.Record(time.Since(callStart))
+	scanner.counters.Files.Add(1)
+	if err != nil {
+		scanner.setObjectError(newFileData, StageStat, err, "Failed to read file again")
+	} else {
+		newFileData.FileSize = int(info.Size())
+		scanner.counters.Bytes.Add(int64(newFileData.FileSize))
+		scanner.setFileTimes(newFileData, info)
+	}
+	return *newFileData
+}
+
+// returns the counters of the scan
+func (scanner *Scanner) Counters() *Counters {
+	return scanner.counters
+}
+
+// returns the folders which were not read because the last Scan was cancelled
+func (scanner *Scanner) Pending() []Folder {
+	scanner.pendingMutex.Lock()
+	defer scanner.pendingMutex.Unlock()
+	return append([]Folder(nil), scanner.pending...)
+}
+
+// returns the highest number of folders waiting in the queue during the last Scan
+func (scanner *Scanner) PeakQueueDepth() int {
+	scanner.queueMutex.Lock()
+	defer scanner.queueMutex.Unlock()
+	if scanner.queue == nil {
+		return 0
+	}
+	return scanner.queue.PeakDepth()
+}
+
+// returns the number of timed out filesystem calls still hanging in the background
+func (scanner *Scanner) AbandonedCalls() int64 {
+	return scanner.abandonedCalls.Load()
+}
+
+// To read the folder contents
+func (scanner *Scanner) readFolder(ctx context.Context, path string, results chan<- ObjectInfo, depth int, folderQueue *FolderQueue) {
+	if scanner.options.Logger.Enabled(ctx, slog.LevelDebug) {
+		scanner.options.Logger.Debug("Reading folder", "path", path, "depth", depth,
+			"queued_folders", folderQueue.Len(), "pending_results", len(results))
+	}
+
+	if ctx.Err() != nil {
+		scanner.options.Logger.Debug("Scan cancelled, folder kept as pending", "path", path, "depth", depth)
+		scanner.addPending(path, depth)
+		return
+	}
+	// build new ObjectInfo for the current folder
+	currentFolderData := new(ObjectInfo)
+	currentFolderData.ObjType = "d"
+	currentFolderData.HasError = false
+	currentFolderData.Path = path
+	currentFolderData.ObjectDepth = depth
+	currentFolderData.FileSize = 0
+	currentFolderData.ThisFolderSize = 0
+
+	// Get folder information
+	scanner.options.OpsLimiter.Wait(ctx, 1)
+	callStart := time.Now()
+	info, err := withFSTimeout(scanner, "stat", path, func() (fs.FileInfo, error) { return scanner.fs.stat(path) })
+	scanner.latency.Record(time.Since(callStart))
+	scanner.counters.Folders.Add(1)
+	if err != nil {
+		scanner.setObjectError(currentFolderData, StageStat, err, "Failed to get directory info")
+	} else {
+		//set the folder size which will just be the meta data size
+		currentFolderData.ThisFolderSize = int(info.Size())
+		scanner.setFileTimes(currentFolderData, info)
+
+		// Read the directory contents in chunks, the directory stays open for the entry stats
+		scanner.options.OpsLimiter.Wait(ctx, 1)
+		callStart = time.Now()
+		dir, err := withFSTimeout(scanner, "open", path, func() (scanDir, error) { return scanner.fs.openDir(path) })
+		if err == nil && scanner.options.FSTimeout > 0 {
+			dir = &guardedDir{scanDir: dir}
+		}
+		scanner.latency.Record(time.Since(callStart))
+		if err != nil {
+			scanner.setObjectError(currentFolderData, StageReadDir, err, "Failed to read contents of directory")
+		} else {
+			defer dir.Close()
+			totalCurrentFolderSize := 0
+			entryCount := 0
+			for {
+				// the entries are streamed chunk by chunk, so huge folders neither sit in memory nor hold back the output
+				scanner.options.OpsLimiter.Wait(ctx, 1)
+				callStart = time.Now()
+				entries, err := withFSTimeout(scanner, "readdir", path, func() ([]fs.DirEntry, error) { return dir.ReadDir(readDirChunkSize) })
+				scanner.latency.Record(time.Since(callStart))
+				entryCount += len(entries)
+				// Iterate over the directory entries
+				for _, entry := range entries {
+					// Join the directory and file name
+					fullPath := scanner.fs.join(path, entry.Name())
+					if entry.IsDir() {
+						folderQueue.Push(fullPath, depth+1)
+					} else {
+						totalCurrentFolderSize += scanner.readFile(ctx, dir, entry, fullPath, depth, results)
+					}
+				}
+				if errors.Is(err, io.EOF) {
+					break
+				} else if err != nil {
+					scanner.setObjectError(currentFolderData, StageReadDir, err, "Failed to read contents of directory", "entries", entryCount)
+					break
+				}
+			}
+			currentFolderData.ThisFolderSize = totalCurrentFolderSize
+			if scanner.options.LargeDirThreshold > 0 && entryCount > scanner.options.LargeDirThreshold {
+				scanner.counters.LargeFolders.Add(1)
+				scanner.options.Logger.Info("Large directory", "path", path, "depth", depth, "entries", entryCount)
+			}
+		}
+	}
+	if !sendResult(ctx, results, *currentFolderData) {
+		// cancelled while the folder was read, it is read again on resume
+		scanner.options.Logger.Debug("Scan cancelled, folder kept as pending", "path", path, "depth", depth)
+		scanner.addPending(path, depth)
+	}
+}
+
+// keeps a folder which was not (completely) sent for Pending
+func (scanner *Scanner) addPending(path string, depth int) {
+	scanner.pendingMutex.Lock()
+	scanner.pending = append(scanner.pending, Folder{Path: path, ObjectDepth: depth})
+	scanner.pendingMutex.Unlock()
+}
+
+// sends an entry unless the scan is cancelled, so that the workers never block on a DB writer which stopped
+func sendResult(ctx context.Context, results chan<- ObjectInfo, data ObjectInfo) bool {
+	select {
+	case results <- data:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// sends the ObjectInfo of a file in the folder being read and returns its size
+func (scanner *Scanner) readFile(ctx context.Context, dir scanDir, entry fs.DirEntry, fullPath string, depth int, results chan<- ObjectInfo) int {
+	// build new ObjectInfo for the file
+	newFileData := new(ObjectInfo)
+	newFileData.ObjType = "f"
+	newFileData.HasError = false
+	newFileData.Path = fullPath
+	newFileData.ObjectDepth = depth
+	newFileData.FileSize = 0
+	newFileData.ThisFolderSize = 0
+	// Get file information, a single stat gives the size and the times
+	scanner.options.OpsLimiter.Wait(ctx, 1)
+	callStart := time.Now()
+	info, err := withFSTimeout(scanner, "lstat", fullPath, func() (fs.FileInfo, error) { return dir.lstat(entry) })
+	scanner.latency.Record(time.Since(callStart))
+	scanner.counters.Files.Add(1)
+	if err != nil {
+		scanner.setObjectError(newFileData, StageStat, err, "Failed to read file")
+	} else {
+		newFileData.FileSize = int(info.Size())
+		scanner.counters.Bytes.Add(int64(newFileData.FileSize))
+		scanner.setFileTimes(newFileData, info)
+		if scanner.options.ExpandArchives && info.Mode().IsRegular() && archiveKind(entry.Name()) != "" {
+			scanner.expandArchive(ctx, newFileData, results)
+		}
+	}
+	sendResult(ctx, results, *newFileData) // its folder is kept as pending when this fails
+	return newFileData.FileSize
+}
+
+// sets the times and the owner of an entry from its info
+func (scanner *Scanner) setFileTimes(data *ObjectInfo, info fs.FileInfo) {
+	ctime, atime, wtime, owner, err := scanner.getFileTimesWithTimeout(data.Path, info)
+	if err != nil {
+		scanner.setObjectError(data, fileTimesErrorStage(err), err, "Failed to read the file times or owner")
+	}
+	data.CreationTime = ctime
+	data.LastAccessTime = atime
+	data.LastWriteTime = wtime
+	data.Owner = owner
+}
@@ -0,0 +1,326 @@
+//go:build windows || !windows
+// +build windows !windows
+
+package scanner
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+// runs a Scan of the folders and returns the ObjectInfo sent for every path
+func scanAll(t testing.TB, scanner *Scanner, folders ...Folder) map[string]ObjectInfo {
+	t.Helper()
+	results := make(chan ObjectInfo, 100)
+	done := make(chan error, 1)
+	go func() {
+		done <- scanner.Scan(context.Background(), results, folders...)
+		close(results)
+	}()
+	objects := make(map[string]ObjectInfo)
+	for object := range results {
+		objects[object.Path] = object
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("Scan() error: %v", err)
+	}
+	return objects
+}
+
+// scanFS counting the stat calls of the walker. restat adds the second stat per entry
+// the file times took before they were read from the listing info.
+type countingFS struct {
+	scanFS
+	stats  *atomic.Int64
+	restat bool
+}
+
+func (fsys countingFS) stat(name string) (fs.FileInfo, error) {
+	fsys.stats.Add(1)
+	return fsys.scanFS.stat(name)
+}
+
+func (fsys countingFS) openDir(name string) (scanDir, error) {
+	dir, err := fsys.scanFS.openDir(name)
+	if err != nil {
+		return nil, err
+	}
+	return countingDir{dir, fsys.stats}, nil
+}
+
+func (fsys countingFS) fileTimes(name string, info fs.FileInfo) (time.Time, time.Time, time.Time, string, error) {
+	if fsys.restat {
+		fsys.stats.Add(1)
+		if _, err := os.Lstat(name); err != nil {
+			return time.Time{}, time.Time{}, time.Time{}, "", err
+		}
+	}
+	return fsys.scanFS.fileTimes(name, info)
+}
+
+type countingDir struct {
+	scanDir
+	stats *atomic.Int64
+}
+
+func (dir countingDir) lstat(entry fs.DirEntry) (fs.FileInfo, error) {
+	dir.stats.Add(1)
+	return dir.scanDir.lstat(entry)
+}
+
+// creates folders with files on the local disk and returns the number of entries
+func buildTree(b *testing.B, root string, folders int, filesPerFolder int) int {
+	b.Helper()
+	for i := 0; i < folders; i++ {
+		folder := filepath.Join(root, fmt.Sprintf("d%d", i/10), fmt.Sprintf("e%d", i))
+		if err := os.MkdirAll(folder, 0755); err != nil {
+			b.Fatal(err)
+		}
+		for j := 0; j < filesPerFolder; j++ {
+			if err := os.WriteFile(filepath.Join(folder, fmt.Sprintf("f%d", j)), []byte("data"), 0644); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+	entries := 0
+	filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		entries++
+		return err
+	})
+	return entries
+}
+
+// compares the single stat per entry with the former second stat for the file times,
+// the stats/entry metric shows the calls saved
+func BenchmarkScan(b *testing.B) {
+	root := b.TempDir()
+	entries := buildTree(b, root, 100, 50)
+	for _, mode := range []struct {
+		name   string
+		restat bool
+	}{{"single_stat", false}, {"double_stat", true}} {
+		b.Run(mode.name, func(b *testing.B) {
+			var stats atomic.Int64
+			for i := 0; i < b.N; i++ {
+				scanner := New(Options{Workers: 8})
+				scanner.fs = countingFS{scanner.fs, &stats, mode.restat}
+				if objects := scanAll(b, scanner, Folder{Path: root, ObjectDepth: 1}); len(objects) != entries {
+					b.Fatalf("scanned %d entries, want %d", len(objects), entries)
+				}
+			}
+			b.ReportMetric(float64(stats.Load())/float64(b.N*entries), "stats/entry")
+			b.ReportMetric(float64(entries), "entries")
+		})
+	}
+}
+
+// returns a MapFS with one folder holding n small files, and their total size
+func wideFolder(n int) (fstest.MapFS, int) {
+	fsys := fstest.MapFS{}
+	total := 0
+	for i := 1; i <= n; i++ {
+		fsys[fmt.Sprintf("wide/f%d", i)] = &fstest.MapFile{Data: make([]byte, i%7)}
+		total += i % 7
+	}
+	return fsys, total
+}
+
+func TestReadFolderChunks(t *testing.T) {
+	const files = 2*readDirChunkSize + 10
+	tests := []struct {
+		threshold     int
+		wantLarge     int64
+		wantLogRecord bool
+	}{
+		{0, 0, false},
+		{files, 0, false},
+		{files - 1, 1, true},
+	}
+	for _, test := range tests {
+		t.Run(fmt.Sprintf("threshold %d", test.threshold), func(t *testing.T) {
+			fsys, total := wideFolder(files)
+			var log strings.Builder
+			scanner := New(Options{FS: fsys, LargeDirThreshold: test.threshold, Logger: slog.New(slog.NewTextHandler(&log, nil))})
+			objects := scanAll(t, scanner, Folder{Path: "wide", ObjectDepth: 1})
+			if len(objects) != files+1 {
+				t.Fatalf("scanned %d entries, want %d", len(objects), files+1)
+			}
+			if folder := objects["wide"]; folder.ThisFolderSize != total || folder.HasError {
+				t.Fatalf("folder = %+v, want ThisFolderSize %d without error", folder, total)
+			}
+			if large := scanner.Counters().LargeFolders.Load(); large != test.wantLarge {
+				t.Fatalf("LargeFolders = %d, want %d", large, test.wantLarge)
+			}
+			if logged := strings.Contains(log.String(), "Large directory"); logged != test.wantLogRecord {
+				t.Fatalf("large directory logged = %v, want %v: %s", logged, test.wantLogRecord, log.String())
+			}
+		})
+	}
+}
+
+// fs.FS whose folder listings fail after the first chunk
+type failingListFS struct {
+	fstest.MapFS
+}
+
+func (fsys failingListFS) Open(name string) (fs.File, error) {
+	file, err := fsys.MapFS.Open(name)
+	if dir, ok := file.(fs.ReadDirFile); ok && err == nil {
+		return &failingListDir{ReadDirFile: dir}, nil
+	}
+	return file, err
+}
+
+type failingListDir struct {
+	fs.ReadDirFile
+	calls int
+}
+
+func (dir *failingListDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	dir.calls++
+	if dir.calls > 1 {
+		return nil, fs.ErrPermission
+	}
+	return dir.ReadDirFile.ReadDir(n)
+}
+
+// the entries of the chunks read before a listing error are kept, the folder gets the error
+func TestReadFolderChunkError(t *testing.T) {
+	fsys, _ := wideFolder(readDirChunkSize + 5)
+	scanner := New(Options{FS: failingListFS{fsys}})
+	objects := scanAll(t, scanner, Folder{Path: "wide", ObjectDepth: 1})
+	if len(objects) != readDirChunkSize+1 {
+		t.Fatalf("scanned %d entries, want the first chunk and the folder", len(objects))
+	}
+	folder := objects["wide"]
+	if !folder.HasError || folder.ErrorStage != StageReadDir || folder.ErrorCode != "EACCES" {
+		t.Fatalf("folder = %+v, want a readdir EACCES error", folder)
+	}
+}
+
+// fs.FS whose folders in locked cannot be opened, their stat still works through fstest.MapFS.Stat
+type lockedFS struct {
+	fstest.MapFS
+	locked map[string]bool
+}
+
+func (fsys lockedFS) Open(name string) (fs.File, error) {
+	if fsys.locked[name] {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrPermission}
+	}
+	return fsys.MapFS.Open(name)
+}
+
+func TestScanMapFS(t *testing.T) {
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	fsys := lockedFS{fstest.MapFS{
+		".":         {Mode: fs.ModeDir, ModTime: t0},
+		"top.txt":   {Data: make([]byte, 11), ModTime: t0.Add(time.Hour)},
+		"a":         {Mode: fs.ModeDir, ModTime: t0.Add(2 * time.Hour)},
+		"a/x.txt":   {Data: make([]byte, 3), ModTime: t0},
+		"a/b":       {Mode: fs.ModeDir, ModTime: t0.Add(5 * time.Hour)},
+		"a/b/y.txt": {Data: make([]byte, 5), ModTime: t0},
+		"a/b/z.txt": {Data: make([]byte, 7), ModTime: t0},
+		"empty":     {Mode: fs.ModeDir, ModTime: t0.Add(3 * time.Hour)},
+		"locked":    {Mode: fs.ModeDir, ModTime: t0.Add(4 * time.Hour)},
+		"locked/s":  {Data: make([]byte, 100), ModTime: t0},
+	}, map[string]bool{"locked": true}}
+
+	scanner := New(Options{FS: fsys, Workers: 4})
+	objects := scanAll(t, scanner, Folder{Path: ".", ObjectDepth: 1})
+
+	want := []struct {
+		path           string
+		objType        string
+		depth          int
+		fileSize       int
+		thisFolderSize int
+		errorStage     string
+	}{
+		{".", "d", 1, 0, 11, ""},
+		{"top.txt", "f", 1, 11, 0, ""},
+		{"a", "d", 2, 0, 3, ""},
+		{"a/x.txt", "f", 2, 3, 0, ""},
+		{"a/b", "d", 3, 0, 12, ""},
+		{"a/b/y.txt", "f", 3, 5, 0, ""},
+		{"a/b/z.txt", "f", 3, 7, 0, ""},
+		{"empty", "d", 2, 0, 0, ""},
+		{"locked", "d", 2, 0, 0, StageReadDir},
+	}
+	if len(objects) != len(want) {
+		t.Fatalf("scanned %d entries, want %d: %v", len(objects), len(want), objects)
+	}
+	for _, entry := range want {
+		object, ok := objects[entry.path]
+		if !ok {
+			t.Fatalf("%s was not scanned", entry.path)
+		}
+		if object.ObjType != entry.objType || object.ObjectDepth != entry.depth || object.FileSize != entry.fileSize ||
+			object.ThisFolderSize != entry.thisFolderSize || object.ErrorStage != entry.errorStage || object.HasError != (entry.errorStage != "") {
+			t.Errorf("%s = %+v, want %+v", entry.path, object, entry)
+		}
+	}
+	if locked := objects["locked"]; locked.ErrorCode != "EACCES" || locked.LastWriteTime != t0.Add(4*time.Hour) {
+		t.Errorf("locked = %+v, want an EACCES error with its stat times", locked)
+	}
+
+	counters := scanner.Counters()
+	if counters.Folders.Load() != 5 || counters.Files.Load() != 4 || counters.Bytes.Load() != 26 || counters.Errors.Load() != 1 {
+		t.Errorf("counters: %d folders, %d files, %d bytes, %d errors, want 5, 4, 26, 1",
+			counters.Folders.Load(), counters.Files.Load(), counters.Bytes.Load(), counters.Errors.Load())
+	}
+	if classes := counters.ErrorCounts(); classes[ErrorClass{StageReadDir, "EACCES"}] != 1 || len(classes) != 1 {
+		t.Errorf("ErrorCounts() = %v", classes)
+	}
+
+	// the folder rows rolled up like the report DB does after the scan
+	rollup := NewRollup(".")
+	for _, object := range objects {
+		if object.ObjType == "d" {
+			rollup.Add(object.Path, object.ThisFolderSize, object.LastWriteTime)
+		}
+	}
+	wantTotals := map[string]FolderTotals{
+		".":      {26, t0.Add(5 * time.Hour)},
+		"a":      {15, t0.Add(5 * time.Hour)},
+		"a/b":    {12, t0.Add(5 * time.Hour)},
+		"empty":  {0, t0.Add(3 * time.Hour)},
+		"locked": {0, t0.Add(4 * time.Hour)},
+	}
+	totals := rollup.Totals()
+	if len(totals) != len(wantTotals) {
+		t.Fatalf("Totals() = %v, want %v", totals, wantTotals)
+	}
+	for path, want := range wantTotals {
+		if got := totals[path]; got.TotalCalFolderSize != want.TotalCalFolderSize || !got.CalLastWriteTime.Equal(want.CalLastWriteTime) {
+			t.Errorf("Totals()[%s] = %+v, want %+v", path, got, want)
+		}
+	}
+}
+
+// a cancelled scan reads nothing and keeps the folders for Pending
+func TestScanCancelled(t *testing.T) {
+	fsys := fstest.MapFS{"a/x.txt": {Data: []byte("x")}}
+	scanner := New(Options{FS: fsys})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	results := make(chan ObjectInfo, 10)
+	if err := scanner.Scan(ctx, results, Folder{Path: ".", ObjectDepth: 1}); err != context.Canceled {
+		t.Fatalf("Scan() = %v, want context.Canceled", err)
+	}
+	if len(results) != 0 {
+		t.Fatalf("a cancelled scan sent %d entries", len(results))
+	}
+	if pending := scanner.Pending(); len(pending) != 1 || pending[0] != (Folder{Path: ".", ObjectDepth: 1}) {
+		t.Fatalf("Pending() = %v, want the root", pending)
+	}
+}
@@ -0,0 +1,14 @@
+//go:build darwin || freebsd || netbsd
+// +build darwin freebsd netbsd
+
+package scanner
+
+import (
+	"syscall"
+	"time"
+)
+
+// returns the access and change times of a stat, named Atimespec and Ctimespec on these systems
+func statTimes(stat *syscall.Stat_t) (time.Time, time.Time) {
+	return time.Unix(stat.Atimespec.Unix()), time.Unix(stat.Ctimespec.Unix())
+}
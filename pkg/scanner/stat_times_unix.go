@@ -0,0 +1,14 @@
+//go:build !windows && !linux && !darwin && !freebsd && !netbsd
+// +build !windows,!linux,!darwin,!freebsd,!netbsd
+
+package scanner
+
+import (
+	"syscall"
+	"time"
+)
+
+// returns the access and change times of a stat
+func statTimes(stat *syscall.Stat_t) (time.Time, time.Time) {
+	return time.Unix(stat.Atim.Unix()), time.Unix(stat.Ctim.Unix())
+}
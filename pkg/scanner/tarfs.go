@@ -0,0 +1,228 @@
+//go:build windows || !windows
+// +build windows !windows
+
+package scanner
+
+import (
+	"archive/tar"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// TarFS is the folder tree of a tar stream, read once from its headers without keeping the file
+// contents. It is scanned through Options.FS, the folders missing from the stream are added with
+// zero times and its files cannot be opened.
+type TarFS struct {
+	entries  map[string]*tarEntry // by slash separated path, "." is the root
+	skipped  int
+	promoted int
+}
+
+type tarEntry struct {
+	header   *tar.Header
+	implicit bool            // a folder which is only in the stream through the members below it
+	children map[string]bool // names of the entries of a folder
+}
+
+// the tar header info with the name of the entry in the tree, which may differ after stripComponents
+type tarFileInfo struct {
+	fs.FileInfo
+	name string
+}
+
+func (info tarFileInfo) Name() string { return info.name }
+
+// NewTarFS reads all the headers of a tar stream. stripComponents leading path elements are
+// removed from the member names, like tar --strip-components does.
+func NewTarFS(reader io.Reader, stripComponents int) (*TarFS, error) {
+	tarFS := &TarFS{entries: make(map[string]*tarEntry)}
+	tarFS.entries["."] = &tarEntry{header: implicitFolderHeader("."), implicit: true, children: make(map[string]bool)}
+	tarReader := tar.NewReader(reader)
+	for {
+		header, err := tarReader.Next()
+		if errors.Is(err, io.EOF) {
+			return tarFS, nil
+		} else if err != nil {
+			return tarFS, fmt.Errorf("failed to read the tar headers after %d entries: %w", len(tarFS.entries)-1, err)
+		}
+		if header.Typeflag == tar.TypeXGlobalHeader {
+			continue
+		}
+		name := strings.ReplaceAll(header.Name, `\`, "/")
+		name = strings.TrimPrefix(strings.Trim(name, "/"), "./")
+		for i := 0; i < stripComponents && name != ""; i++ {
+			_, name, _ = strings.Cut(name, "/")
+		}
+		if name == "" || name == "." {
+			if header.Typeflag == tar.TypeDir && stripComponents == 0 {
+				tarFS.entries["."].header = header // the times of the root itself
+				tarFS.entries["."].implicit = false
+			}
+			continue
+		}
+		if !fs.ValidPath(name) {
+			tarFS.skipped++ // e.g. ../x, which would leave the archive
+			continue
+		}
+		tarFS.add(name, header)
+	}
+}
+
+// adds an entry and the folders above it which are not in the stream (yet)
+func (tarFS *TarFS) add(name string, header *tar.Header) {
+	entry, exists := tarFS.entries[name]
+	if !exists {
+		entry = new(tarEntry)
+		tarFS.entries[name] = entry
+	}
+	entry.header = header // a later copy of the same member wins, like with tar -x
+	entry.implicit = false
+	if header.Typeflag == tar.TypeDir && entry.children == nil {
+		entry.children = make(map[string]bool)
+	} else if header.Typeflag != tar.TypeDir && entry.children != nil {
+		tarFS.promote(entry) // members below it came first
+	}
+	for {
+		parent := path.Dir(name)
+		parentEntry, exists := tarFS.entries[parent]
+		if !exists {
+			parentEntry = &tarEntry{header: implicitFolderHeader(parent), implicit: true, children: make(map[string]bool)}
+			tarFS.entries[parent] = parentEntry
+		} else if parentEntry.children == nil {
+			parentEntry.children = make(map[string]bool) // a file member followed by members below it
+			tarFS.promote(parentEntry)
+		}
+		if parentEntry.children[path.Base(name)] {
+			return // the folders above are already linked
+		}
+		parentEntry.children[path.Base(name)] = true
+		if parent == "." {
+			return
+		}
+		name = parent
+	}
+}
+
+// turns a file member with members below it into a folder with the times and owner of the member,
+// otherwise the scan would take it for a file and never list the members
+func (tarFS *TarFS) promote(entry *tarEntry) {
+	header := *entry.header
+	header.Typeflag = tar.TypeDir
+	header.Size = 0
+	header.Linkname = ""
+	entry.header = &header
+	tarFS.promoted++
+}
+
+func implicitFolderHeader(name string) *tar.Header {
+	return &tar.Header{Typeflag: tar.TypeDir, Name: name, Mode: 0755}
+}
+
+// Len returns the number of folders and files in the tree, without the root
+func (tarFS *TarFS) Len() int {
+	return len(tarFS.entries) - 1
+}
+
+// Skipped returns the number of members left out because their name would leave the archive
+func (tarFS *TarFS) Skipped() int {
+	return tarFS.skipped
+}
+
+// Promoted returns the number of file members turned into folders because other members are below them
+func (tarFS *TarFS) Promoted() int {
+	return tarFS.promoted
+}
+
+func (tarFS *TarFS) lookup(op string, name string) (*tarEntry, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	entry, exists := tarFS.entries[name]
+	if !exists {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+	return entry, nil
+}
+
+// Open implements fs.FS, the folders can be listed and the files only stat'ed
+func (tarFS *TarFS) Open(name string) (fs.File, error) {
+	entry, err := tarFS.lookup("open", name)
+	if err != nil {
+		return nil, err
+	}
+	file := &tarFile{tarFS: tarFS, name: name, entry: entry}
+	if entry.children != nil {
+		for childName := range entry.children {
+			file.children = append(file.children, childName)
+		}
+		sort.Strings(file.children)
+	}
+	return file, nil
+}
+
+// Stat implements fs.StatFS
+func (tarFS *TarFS) Stat(name string) (fs.FileInfo, error) {
+	entry, err := tarFS.lookup("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	return tarFileInfo{entry.header.FileInfo(), path.Base(name)}, nil
+}
+
+// FileTimes implements FileTimesFS with the PAX/GNU change and access times and the owner of the members
+func (tarFS *TarFS) FileTimes(name string, info fs.FileInfo) (time.Time, time.Time, string, error) {
+	entry, err := tarFS.lookup("stat", name)
+	if err != nil {
+		return time.Time{}, time.Time{}, "", err
+	}
+	owner := entry.header.Uname
+	if owner == "" && !entry.implicit {
+		owner = fmt.Sprintf("%d:%d", entry.header.Uid, entry.header.Gid)
+	}
+	return entry.header.ChangeTime, entry.header.AccessTime, owner, nil
+}
+
+// an opened entry of a TarFS
+type tarFile struct {
+	tarFS    *TarFS
+	name     string
+	entry    *tarEntry
+	children []string // sorted names not returned by ReadDir yet
+}
+
+func (file *tarFile) Stat() (fs.FileInfo, error) { return file.tarFS.Stat(file.name) }
+func (file *tarFile) Close() error               { return nil }
+
+func (file *tarFile) Read([]byte) (int, error) {
+	if file.entry.children != nil {
+		return 0, &fs.PathError{Op: "read", Path: file.name, Err: errors.New("is a directory")}
+	}
+	return 0, &fs.PathError{Op: "read", Path: file.name, Err: errors.New("the contents of a tar stream are not kept")}
+}
+
+// ReadDir implements fs.ReadDirFile
+func (file *tarFile) ReadDir(n int) ([]fs.DirEntry, error) {
+	if file.entry.children == nil {
+		return nil, &fs.PathError{Op: "readdir", Path: file.name, Err: errors.New("not a directory")}
+	}
+	count := len(file.children)
+	if n > 0 && n < count {
+		count = n
+	}
+	if n > 0 && count == 0 {
+		return nil, io.EOF
+	}
+	dirEntries := make([]fs.DirEntry, 0, count)
+	for _, childName := range file.children[:count] {
+		child := file.tarFS.entries[path.Join(file.name, childName)]
+		dirEntries = append(dirEntries, fs.FileInfoToDirEntry(tarFileInfo{child.header.FileInfo(), childName}))
+	}
+	file.children = file.children[count:]
+	return dirEntries, nil
+}
@@ -0,0 +1,168 @@
+//go:build windows || !windows
+// +build windows !windows
+
+package scanner
+
+import (
+	"archive/tar"
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// tar stream of the headers, with size bytes of content for the files
+func tarHeaders(t *testing.T, headers ...*tar.Header) io.Reader {
+	t.Helper()
+	var buf bytes.Buffer
+	writer := tar.NewWriter(&buf)
+	for _, header := range headers {
+		if header.Mode == 0 {
+			header.Mode = 0644
+		}
+		if err := writer.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		if header.Typeflag == tar.TypeReg {
+			if _, err := writer.Write(make([]byte, header.Size)); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return &buf
+}
+
+func readDirNames(t *testing.T, fsys fs.FS, name string) []string {
+	t.Helper()
+	entries, err := fs.ReadDir(fsys, name)
+	if err != nil {
+		t.Fatalf("ReadDir(%s) error = %v", name, err)
+	}
+	names := []string{}
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	return names
+}
+
+func TestTarFSLookup(t *testing.T) {
+	modTime := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tarFS, err := NewTarFS(tarHeaders(t,
+		&tar.Header{Name: "./", Typeflag: tar.TypeDir, ModTime: modTime},
+		&tar.Header{Name: "a/", Typeflag: tar.TypeDir, ModTime: modTime},
+		&tar.Header{Name: "a/x.txt", Typeflag: tar.TypeReg, Size: 3, ModTime: modTime, Uname: "alice"},
+		&tar.Header{Name: "b/c/y.txt", Typeflag: tar.TypeReg, Size: 5, ModTime: modTime, Uid: 7, Gid: 8},
+		&tar.Header{Name: "../z.txt", Typeflag: tar.TypeReg},
+		&tar.Header{Name: "a/x.txt", Typeflag: tar.TypeReg, Size: 4, ModTime: modTime, Uname: "alice"},
+	), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tarFS.Len() != 5 || tarFS.Skipped() != 1 {
+		t.Fatalf("Len() = %d, Skipped() = %d, want 5 and 1", tarFS.Len(), tarFS.Skipped())
+	}
+
+	tests := []struct {
+		name    string
+		isDir   bool
+		size    int64
+		modTime time.Time
+		owner   string
+	}{
+		{".", true, 0, modTime, "0:0"},
+		{"a", true, 0, modTime, "0:0"},
+		{"a/x.txt", false, 4, modTime, "alice"}, // the later copy wins
+		{"b", true, 0, time.Time{}, ""},         // only in the stream through b/c/y.txt
+		{"b/c/y.txt", false, 5, modTime, "7:8"},
+	}
+	for _, test := range tests {
+		info, err := tarFS.Stat(test.name)
+		if err != nil {
+			t.Fatalf("Stat(%s) error = %v", test.name, err)
+		}
+		if info.IsDir() != test.isDir || info.Size() != test.size || !info.ModTime().Equal(test.modTime) {
+			t.Fatalf("Stat(%s) = dir %v, size %d, time %v, want %v, %d, %v", test.name, info.IsDir(), info.Size(), info.ModTime(),
+				test.isDir, test.size, test.modTime)
+		}
+		if _, _, owner, err := tarFS.FileTimes(test.name, info); err != nil || owner != test.owner {
+			t.Fatalf("FileTimes(%s) owner = %q, %v, want %q", test.name, owner, err, test.owner)
+		}
+	}
+
+	if names := readDirNames(t, tarFS, "."); !reflect.DeepEqual(names, []string{"a", "b"}) {
+		t.Fatalf("ReadDir(.) = %q", names)
+	}
+	if names := readDirNames(t, tarFS, "b/c"); !reflect.DeepEqual(names, []string{"y.txt"}) {
+		t.Fatalf("ReadDir(b/c) = %q", names)
+	}
+	if _, err := fs.ReadDir(tarFS, "a/x.txt"); err == nil {
+		t.Fatal("ReadDir of a file did not fail")
+	}
+	if _, err := fs.ReadFile(tarFS, "a/x.txt"); err == nil {
+		t.Fatal("ReadFile of a tar member did not fail")
+	}
+	if _, err := tarFS.Stat("missing"); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("Stat(missing) error = %v, want fs.ErrNotExist", err)
+	}
+	if _, err := tarFS.Open("../z.txt"); !errors.Is(err, fs.ErrInvalid) {
+		t.Fatalf("Open(../z.txt) error = %v, want fs.ErrInvalid", err)
+	}
+}
+
+func TestTarFSStripComponents(t *testing.T) {
+	tarFS, err := NewTarFS(tarHeaders(t,
+		&tar.Header{Name: "srv/", Typeflag: tar.TypeDir},
+		&tar.Header{Name: "srv/share/", Typeflag: tar.TypeDir},
+		&tar.Header{Name: "srv/share/a/x.txt", Typeflag: tar.TypeReg, Size: 1},
+		&tar.Header{Name: "srv/y.txt", Typeflag: tar.TypeReg, Size: 1},
+	), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names := readDirNames(t, tarFS, "."); !reflect.DeepEqual(names, []string{"a"}) {
+		t.Fatalf("ReadDir(.) = %q, want [a]", names)
+	}
+	if _, err := tarFS.Stat("a/x.txt"); err != nil {
+		t.Fatalf("Stat(a/x.txt) error = %v", err)
+	}
+}
+
+// a file member with members below it is listed as a folder, in both orders of the stream
+func TestTarFSFileWithMembersBelow(t *testing.T) {
+	modTime := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	file := &tar.Header{Name: "x", Typeflag: tar.TypeReg, Size: 3, ModTime: modTime}
+	below := &tar.Header{Name: "x/y.txt", Typeflag: tar.TypeReg, Size: 1}
+	deeper := &tar.Header{Name: "x/z/w.txt", Typeflag: tar.TypeReg, Size: 1}
+	tests := []struct {
+		name    string
+		headers []*tar.Header
+	}{
+		{"file first", []*tar.Header{file, below, deeper}},
+		{"file last", []*tar.Header{below, deeper, file}},
+		{"file in between", []*tar.Header{below, file, deeper}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tarFS, err := NewTarFS(tarHeaders(t, test.headers...), 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			info, err := tarFS.Stat("x")
+			if err != nil || !info.IsDir() || info.Size() != 0 || !info.ModTime().Equal(modTime) {
+				t.Fatalf("Stat(x) = %v, %v, want a folder with the member time", info, err)
+			}
+			if names := readDirNames(t, tarFS, "x"); !reflect.DeepEqual(names, []string{"y.txt", "z"}) {
+				t.Fatalf("ReadDir(x) = %q", names)
+			}
+			if tarFS.Promoted() != 1 {
+				t.Fatalf("Promoted() = %d, want 1", tarFS.Promoted())
+			}
+		})
+	}
+}
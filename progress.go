@@ -0,0 +1,101 @@
+//go:build windows || !windows
+// +build windows !windows
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/abhilash945/FolderInsight/pkg/scanner"
+)
+
+// Counters updated by the scanner and the DB writer, read by the progress reporter
+type ScanCounters struct {
+	scanner.Counters
+	RowsWritten atomic.Int64
+}
+
+var counters ScanCounters
+
+// returns true if the file is an interactive terminal
+func isTerminal(file *os.File) bool {
+	info, err := file.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// prints the scan progress every interval until done is closed.
+// expectedEntries is the entry count of a previous scan of the same root, 0 if unknown.
+func reportProgress(interval time.Duration, backlog func() int, expectedEntries int64, done <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	refreshLine := isTerminal(os.Stdout)
+	startTime := time.Now()
+	for {
+		select {
+		case <-done:
+			if refreshLine {
+				fmt.Println()
+			}
+			return
+		case <-ticker.C:
+			line := progressLine(time.Since(startTime), backlog(), expectedEntries)
+			if refreshLine {
+				// \r and clear the line so that the progress keeps overwriting itself
+				fmt.Print("\r\033[K" + line)
+				fileLogger.Info(line)
+			} else {
+				logger.Info(line)
+			}
+		}
+	}
+}
+
+// builds a single line progress summary from the counters
+func progressLine(elapsed time.Duration, backlog int, expectedEntries int64) string {
+	folders := counters.Folders.Load()
+	files := counters.Files.Load()
+	entries := folders + files
+	rate := float64(entries) / elapsed.Seconds()
+
+	var line strings.Builder
+	fmt.Fprintf(&line, "%s elapsed, %d folders, %d files, %s, %.0f entries/s, backlog %d, %d rows written, %d errors",
+		elapsed.Round(time.Second), folders, files, formatBytes(counters.Bytes.Load()), rate, backlog,
+		counters.RowsWritten.Load(), counters.Errors.Load())
+	if archiveMembers := counters.ArchiveMembers.Load(); archiveMembers > 0 {
+		fmt.Fprintf(&line, ", %d archive members", archiveMembers)
+	}
+
+	// the scan is complete once everything is in the DB, so the estimate follows the written rows
+	rowsWritten := counters.RowsWritten.Load()
+	writeRate := float64(rowsWritten) / elapsed.Seconds()
+	if expectedEntries > 0 && writeRate > 0 {
+		if remaining := expectedEntries - rowsWritten; remaining > 0 {
+			eta := time.Duration(float64(remaining)/writeRate) * time.Second
+			fmt.Fprintf(&line, ", ~%.0f%% done, ETA %s", float64(rowsWritten)*100/float64(expectedEntries), eta.Round(time.Second))
+		} else {
+			line.WriteString(", ETA unknown (more entries than the previous scan)")
+		}
+	}
+	return line.String()
+}
+
+// formats a byte count with binary units, e.g. 1.5 GiB
+func formatBytes(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,137 @@
+//go:build windows || !windows
+// +build windows !windows
+
+package main
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// runQuery implements the "query" subcommand and returns the process exit code
+func runQuery(args []string) int {
+	var queryDBfile, querySQL, format string
+
+	queryFlags := flag.NewFlagSet("query", flag.ExitOnError)
+	queryFlags.Usage = func() {
+		fmt.Fprintln(queryFlags.Output(), "Usage of query: query -DBfile=<report DB> [flags] [SQL]")
+		queryFlags.PrintDefaults()
+	}
+	queryFlags.StringVar(&queryDBfile, "DBfile", "", "Scan report DB file to query (mandatory)")
+	queryFlags.StringVar(&querySQL, "SQL", "", "SQL query, can also be given after the flags (mandatory)")
+	queryFlags.StringVar(&format, "Format", "table", "Output format: table or csv (optional)")
+	if exitCode, ok := parseCommandFlags(queryFlags, args); !ok {
+		return exitCode
+	}
+
+	if querySQL == "" {
+		querySQL = strings.Join(queryFlags.Args(), " ")
+	}
+	if queryDBfile == "" || strings.TrimSpace(querySQL) == "" {
+		fmt.Println("Mandatory fields are missing, check with query -help")
+		return 2
+	}
+	if format != "table" && format != "csv" {
+		fmt.Println("-Format must be table or csv")
+		return 2
+	}
+	db, err := openReportDB(queryDBfile)
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	defer db.Close()
+
+	// the DB is opened read only, so the query cannot change the report
+	rows, err := db.Query(querySQL)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "failed to execute query:", err)
+		return 1
+	}
+	defer rows.Close()
+	if err := printQueryRows(os.Stdout, rows, format); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+// prints all the rows of a query as an aligned table or as CSV with a header line
+func printQueryRows(w io.Writer, rows *sql.Rows, format string) error {
+	columns, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+
+	var writeRow func([]string) error
+	var flush func() error
+	if format == "csv" {
+		csvWriter := csv.NewWriter(w)
+		writeRow = csvWriter.Write
+		flush = func() error {
+			csvWriter.Flush()
+			return csvWriter.Error()
+		}
+	} else {
+		tableWriter := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		writeRow = func(record []string) error {
+			_, err := fmt.Fprintln(tableWriter, strings.Join(record, "\t"))
+			return err
+		}
+		flush = tableWriter.Flush
+	}
+
+	if err := writeRow(columns); err != nil {
+		return err
+	}
+	values := make([]interface{}, len(columns))
+	pointers := make([]interface{}, len(columns))
+	for i := range values {
+		pointers[i] = &values[i]
+	}
+	record := make([]string, len(columns))
+	rowCount := 0
+	for rows.Next() {
+		if err := rows.Scan(pointers...); err != nil {
+			return fmt.Errorf("failed to scan a row: %v", err)
+		}
+		for i, value := range values {
+			record[i] = formatQueryValue(value)
+		}
+		if err := writeRow(record); err != nil {
+			return err
+		}
+		rowCount++
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if err := flush(); err != nil {
+		return err
+	}
+	if format == "table" {
+		fmt.Fprintf(w, "(%d rows)\n", rowCount)
+	}
+	return nil
+}
+
+// formats a column value of a query result, NULL is printed as an empty string
+func formatQueryValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case []byte:
+		return string(v)
+	case time.Time:
+		return v.Format("2006-01-02 15:04:05.999999999")
+	default:
+		return fmt.Sprint(v)
+	}
+}
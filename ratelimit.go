@@ -0,0 +1,166 @@
+//go:build windows || !windows
+// +build windows !windows
+
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/abhilash945/FolderInsight/pkg/scanner"
+)
+
+var (
+	opsLimiter       = new(scanner.RateLimiter) // filesystem calls (stat, readdir)
+	readBytesLimiter = new(scanner.RateLimiter) // bytes of file content read (hashing, type sniffing, archives)
+)
+
+// Represents one time-of-day window of the -RateSchedule option
+type RateWindow struct {
+	Start, End         time.Duration // since midnight, End before Start means the window wraps past midnight
+	MaxOpsPerSec       float64
+	MaxReadBytesPerSec float64
+}
+
+// returns true if the time of day falls in the window
+func (window RateWindow) contains(timeOfDay time.Duration) bool {
+	if window.Start <= window.End {
+		return timeOfDay >= window.Start && timeOfDay < window.End
+	}
+	return timeOfDay >= window.Start || timeOfDay < window.End
+}
+
+// parses the -RateSchedule value, e.g. "08:00-18:00=200/10MB,18:00-08:00=0/0".
+// Each window is start-end=ops per second[/read bytes per second], 0 meaning unlimited.
+func parseRateSchedule(schedule string) ([]RateWindow, error) {
+	var windows []RateWindow
+	for _, item := range strings.Split(schedule, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		timeRange, limits, found := strings.Cut(item, "=")
+		if !found {
+			return nil, fmt.Errorf("missing '=' in the schedule window %q", item)
+		}
+		startText, endText, found := strings.Cut(timeRange, "-")
+		if !found {
+			return nil, fmt.Errorf("missing '-' in the time range of %q", item)
+		}
+		var window RateWindow
+		var err error
+		if window.Start, err = parseTimeOfDay(startText); err != nil {
+			return nil, err
+		}
+		if window.End, err = parseTimeOfDay(endText); err != nil {
+			return nil, err
+		}
+		opsText, bytesText, _ := strings.Cut(limits, "/")
+		if window.MaxOpsPerSec, err = strconv.ParseFloat(strings.TrimSpace(opsText), 64); err != nil {
+			return nil, fmt.Errorf("invalid ops per second in %q: %v", item, err)
+		}
+		if bytesText != "" {
+			bytesPerSec, err := parseByteSize(bytesText)
+			if err != nil {
+				return nil, fmt.Errorf("invalid read bytes per second in %q: %v", item, err)
+			}
+			window.MaxReadBytesPerSec = float64(bytesPerSec)
+		}
+		if window.MaxOpsPerSec < 0 || window.MaxReadBytesPerSec < 0 {
+			return nil, fmt.Errorf("negative limit in %q, 0 is unlimited", item)
+		}
+		for _, other := range windows {
+			if window.overlaps(other) {
+				return nil, fmt.Errorf("the schedule window %q overlaps an earlier window", item)
+			}
+		}
+		windows = append(windows, window)
+	}
+	return windows, nil
+}
+
+// returns true if both windows share a time of day
+func (window RateWindow) overlaps(other RateWindow) bool {
+	for _, segment := range window.segments() {
+		for _, otherSegment := range other.segments() {
+			if segment[0] < otherSegment[1] && otherSegment[0] < segment[1] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// returns the window as start-end ranges within a day, two of them when it wraps past midnight
+func (window RateWindow) segments() [][2]time.Duration {
+	if window.Start <= window.End {
+		return [][2]time.Duration{{window.Start, window.End}}
+	}
+	return [][2]time.Duration{{window.Start, 24 * time.Hour}, {0, window.End}}
+}
+
+// parses HH:MM into the duration since midnight
+func parseTimeOfDay(text string) (time.Duration, error) {
+	parsed, err := time.Parse("15:04", strings.TrimSpace(text))
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day %q, expected HH:MM", text)
+	}
+	return time.Duration(parsed.Hour())*time.Hour + time.Duration(parsed.Minute())*time.Minute, nil
+}
+
+// parses a byte count with an optional K, M, G or T suffix (binary units), e.g. 10MB or 512K
+func parseByteSize(text string) (int64, error) {
+	text = strings.ToUpper(strings.TrimSpace(text))
+	text = strings.TrimSuffix(strings.TrimSuffix(text, "B"), "I")
+	multiplier := int64(1)
+	if text != "" {
+		switch text[len(text)-1] {
+		case 'K':
+			multiplier = 1 << 10
+		case 'M':
+			multiplier = 1 << 20
+		case 'G':
+			multiplier = 1 << 30
+		case 'T':
+			multiplier = 1 << 40
+		}
+		if multiplier > 1 {
+			text = text[:len(text)-1]
+		}
+	}
+	value, err := strconv.ParseFloat(strings.TrimSpace(text), 64)
+	if err != nil {
+		return 0, err
+	}
+	return int64(value * float64(multiplier)), nil
+}
+
+// applies the limits of the current time-of-day window, or the default ones outside all windows,
+// and keeps following the schedule until done is closed
+func followRateSchedule(windows []RateWindow, defaultOps float64, defaultReadBytes float64, done <-chan struct{}) {
+	ticker := time.NewTicker(30 * time.Second)
+	defer ticker.Stop()
+	for {
+		now := time.Now()
+		timeOfDay := now.Sub(time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location()))
+		ops, readBytes := defaultOps, defaultReadBytes
+		for _, window := range windows {
+			if window.contains(timeOfDay) {
+				ops, readBytes = window.MaxOpsPerSec, window.MaxReadBytesPerSec
+				break
+			}
+		}
+		if ops != opsLimiter.Rate() || readBytes != readBytesLimiter.Rate() {
+			logger.Info("Rate limits changed, 0 is unlimited", "max_ops_per_sec", ops, "max_read_bytes_per_sec", readBytes)
+			opsLimiter.SetRate(ops)
+			readBytesLimiter.SetRate(readBytes)
+		}
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+	}
+}
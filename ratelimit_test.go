@@ -0,0 +1,100 @@
+//go:build windows || !windows
+// +build windows !windows
+
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseByteSize(t *testing.T) {
+	tests := []struct {
+		text    string
+		want    int64
+		wantErr bool
+	}{
+		{"0", 0, false},
+		{"512", 512, false},
+		{"512K", 512 << 10, false},
+		{"10MB", 10 << 20, false},
+		{"10mb", 10 << 20, false},
+		{"1.5G", 3 << 29, false},
+		{"2TiB", 2 << 40, false},
+		{" 20 MB ", 20 << 20, false},
+		{"", 0, true},
+		{"MB", 0, true},
+		{"10XB", 0, true},
+		{"10 megabytes", 0, true},
+	}
+	for _, test := range tests {
+		got, err := parseByteSize(test.text)
+		if (err != nil) != test.wantErr || got != test.want {
+			t.Errorf("parseByteSize(%q) = %d, %v, want %d, error %v", test.text, got, err, test.want, test.wantErr)
+		}
+	}
+}
+
+func TestParseRateSchedule(t *testing.T) {
+	tests := []struct {
+		schedule string
+		want     []RateWindow
+		wantErr  string
+	}{
+		{"", nil, ""},
+		{"08:00-18:00=200/10MB", []RateWindow{{8 * time.Hour, 18 * time.Hour, 200, 10 << 20}}, ""},
+		{"08:00-18:00=200, 18:00-08:00=0/0", []RateWindow{{8 * time.Hour, 18 * time.Hour, 200, 0}, {18 * time.Hour, 8 * time.Hour, 0, 0}}, ""},
+		{"22:30-06:15=50/1M", []RateWindow{{22*time.Hour + 30*time.Minute, 6*time.Hour + 15*time.Minute, 50, 1 << 20}}, ""},
+		{"08:00-18:00", nil, "missing '='"},
+		{"08:00=200", nil, "missing '-'"},
+		{"8h-18:00=200", nil, "invalid time of day"},
+		{"08:00-24:30=200", nil, "invalid time of day"},
+		{"08:00-18:00=fast", nil, "invalid ops per second"},
+		{"08:00-18:00=200/10XB", nil, "invalid read bytes per second"},
+		{"08:00-18:00=-1", nil, "negative limit"},
+		{"08:00-18:00=200,12:00-20:00=100", nil, "overlaps"},
+		{"22:00-06:00=200,05:00-07:00=100", nil, "overlaps"},
+		{"22:00-06:00=200,23:00-01:00=100", nil, "overlaps"},
+		{"22:00-06:00=200,06:00-22:00=100", []RateWindow{{22 * time.Hour, 6 * time.Hour, 200, 0}, {6 * time.Hour, 22 * time.Hour, 100, 0}}, ""},
+	}
+	for _, test := range tests {
+		got, err := parseRateSchedule(test.schedule)
+		if test.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("parseRateSchedule(%q) error = %v, want %q", test.schedule, err, test.wantErr)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(got, test.want) {
+			t.Errorf("parseRateSchedule(%q) = %+v, %v, want %+v", test.schedule, got, err, test.want)
+		}
+	}
+}
+
+func TestRateWindowContains(t *testing.T) {
+	day := RateWindow{Start: 8 * time.Hour, End: 18 * time.Hour}
+	night := RateWindow{Start: 22 * time.Hour, End: 6 * time.Hour}
+	tests := []struct {
+		window    RateWindow
+		timeOfDay time.Duration
+		want      bool
+	}{
+		{day, 8 * time.Hour, true},
+		{day, 17*time.Hour + 59*time.Minute, true},
+		{day, 18 * time.Hour, false},
+		{day, 7 * time.Hour, false},
+		{night, 22 * time.Hour, true},
+		{night, 23*time.Hour + 59*time.Minute, true},
+		{night, 0, true},
+		{night, 5*time.Hour + 59*time.Minute, true},
+		{night, 6 * time.Hour, false},
+		{night, 12 * time.Hour, false},
+	}
+	for _, test := range tests {
+		if got := test.window.contains(test.timeOfDay); got != test.want {
+			t.Errorf("%+v.contains(%v) = %v, want %v", test.window, test.timeOfDay, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,215 @@
+//go:build windows || !windows
+// +build windows !windows
+
+package main
+
+import (
+	"database/sql"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+)
+
+// runReport implements the "report" subcommand and returns the process exit code
+func runReport(args []string) int {
+	if len(args) > 0 && args[0] == "top" {
+		return runReportTop(args[1:])
+	}
+	var reportDBfile, htmlFile string
+	var top int
+
+	reportFlags := flag.NewFlagSet("report", flag.ExitOnError)
+	reportFlags.StringVar(&reportDBfile, "DBfile", "", "Scan report DB file to summarize (mandatory)")
+	reportFlags.IntVar(&top, "Top", 10, "Number of largest folders (and files in the HTML report) listed (optional)")
+	reportFlags.StringVar(&htmlFile, "html", "", "Write a self-contained HTML report to this file instead of printing the summary (optional)")
+	reportFlags.Usage = func() {
+		fmt.Fprintf(reportFlags.Output(), "Usage of report (see 'report top -help' for the top entries by size, file count, age or growth):\n")
+		reportFlags.PrintDefaults()
+	}
+	if exitCode, ok := parseCommandFlags(reportFlags, args); !ok {
+		return exitCode
+	}
+
+	if reportDBfile == "" {
+		fmt.Println("Mandatory fields are missing, check with report -help")
+		return 2
+	}
+	db, err := openReportDB(reportDBfile)
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	defer db.Close()
+
+	if htmlFile != "" {
+		if err := writeHTMLReport(db, htmlFile, top); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		fmt.Println("HTML report written to", htmlFile)
+		return 0
+	}
+	if err := printReportSummary(db, top); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+// prints the scan runs, totals, largest folders and errors of a report DB
+func printReportSummary(db *sql.DB, top int) error {
+	rootPath, rootDepth, err := getScanRoot(db)
+	if err != nil {
+		return err
+	}
+	columns, err := fileinfoColumns(db)
+	if err != nil {
+		return err
+	}
+
+	out := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	defer out.Flush()
+
+	fmt.Fprintf(out, "Scan root:\t%s\n", rootPath)
+	totals, err := readReportTotals(db, rootPath)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "Folders:\t%d\n", totals.Folders)
+	fmt.Fprintf(out, "Files:\t%d\n", totals.Files)
+	if totals.ArchiveMembers > 0 {
+		fmt.Fprintf(out, "Archive members:\t%d\n", totals.ArchiveMembers)
+	}
+	fmt.Fprintf(out, "Total size:\t%s (%d bytes)\n", formatBytes(totals.TotalSize), totals.TotalSize)
+	fmt.Fprintf(out, "Last write:\t%s\n", formatReportTime(totals.LastWrite))
+	fmt.Fprintf(out, "Errors:\t%d\n", totals.Errors)
+
+	if exists, err := tableExists(db, "scan_runs"); err != nil {
+		return err
+	} else if exists {
+		fmt.Fprintln(out, "\nScan runs:")
+		fmt.Fprintln(out, "RunID\tMode\tStatus\tStart\tDuration\tFolders\tFiles\tErrors")
+		rows, err := db.Query(`SELECT RunID, Mode, Status, StartTime, EndTime, Folders, Files, Errors FROM scan_runs ORDER BY RunID;`)
+		if err != nil {
+			return fmt.Errorf("failed to read the scan runs, error: %v", err)
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var run ScanRun
+			var endTime sql.NullTime
+			var runFolders, runFiles, runErrors sql.NullInt64
+			if err := rows.Scan(&run.RunID, &run.Mode, &run.Status, &run.StartTime, &endTime, &runFolders, &runFiles, &runErrors); err != nil {
+				return fmt.Errorf("failed to scan a scan_runs row: %v", err)
+			}
+			duration := "-"
+			if endTime.Valid {
+				duration = endTime.Time.Sub(run.StartTime).Round(time.Second).String()
+			}
+			fmt.Fprintf(out, "%d\t%s\t%s\t%s\t%s\t%d\t%d\t%d\n", run.RunID, run.Mode, run.Status,
+				formatReportTime(sql.NullTime{Time: run.StartTime, Valid: true}), duration, runFolders.Int64, runFiles.Int64, runErrors.Int64)
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+	}
+
+	if top > 0 {
+		fmt.Fprintf(out, "\nLargest folders:\n")
+		fmt.Fprintln(out, "Size\tDepth\tLast write\tPath")
+		rows, err := db.Query(`SELECT Path, ObjectDepth, TotalCalFolderSize, CalLastWriteTime FROM fileinfo
+			WHERE ObjType = 'd' AND ObjectDepth > ? ORDER BY TotalCalFolderSize DESC LIMIT ?;`, rootDepth, top)
+		if err != nil {
+			return fmt.Errorf("failed to read the largest folders, error: %v", err)
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var path string
+			var depth int
+			var size sql.NullInt64
+			var folderLastWrite sql.NullTime
+			if err := rows.Scan(&path, &depth, &size, &folderLastWrite); err != nil {
+				return fmt.Errorf("failed to scan a fileinfo row: %v", err)
+			}
+			fmt.Fprintf(out, "%s\t%d\t%s\t%s\n", formatBytes(size.Int64), depth, formatReportTime(folderLastWrite), path)
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+	}
+
+	if totals.Errors > 0 {
+		fmt.Fprintln(out, "\nErrors:")
+		query := `SELECT ObjType, '', '', COUNT(*) FROM fileinfo WHERE hasError = 1 GROUP BY ObjType ORDER BY 4 DESC;`
+		if columns["ErrorCode"] && columns["ErrorStage"] {
+			query = `SELECT ObjType, IFNULL(ErrorStage, ''), IFNULL(ErrorCode, ''), COUNT(*) FROM fileinfo
+				WHERE hasError = 1 GROUP BY ObjType, ErrorStage, ErrorCode ORDER BY 4 DESC;`
+		}
+		fmt.Fprintln(out, "Type\tStage\tCode\tCount")
+		rows, err := db.Query(query)
+		if err != nil {
+			return fmt.Errorf("failed to read the error summary, error: %v", err)
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var objType, stage, code string
+			var count int64
+			if err := rows.Scan(&objType, &stage, &code, &count); err != nil {
+				return fmt.Errorf("failed to scan a fileinfo row: %v", err)
+			}
+			fmt.Fprintf(out, "%s\t%s\t%s\t%d\n", objType, stage, code, count)
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		fmt.Fprintln(out, "Details are in the v_errors view, failed entries can be scanned again with the retry command.")
+	}
+	return nil
+}
+
+// Represents the entry counts and the size of a report DB
+type ReportTotals struct {
+	Folders        int64
+	Files          int64
+	ArchiveMembers int64
+	Errors         int64
+	TotalSize      int64        // TotalCalFolderSize of the scan root
+	LastWrite      sql.NullTime // CalLastWriteTime of the scan root
+}
+
+// counts the entries of a report DB and reads the size of its scan root
+func readReportTotals(db *sql.DB, rootPath string) (ReportTotals, error) {
+	var totals ReportTotals
+	err := db.QueryRow(`SELECT COUNT(*) FILTER (WHERE ObjType = 'd'), COUNT(*) FILTER (WHERE ObjType NOT IN ('d', 'a')),
+		COUNT(*) FILTER (WHERE ObjType = 'a'), COUNT(*) FILTER (WHERE hasError = 1) FROM fileinfo;`).Scan(
+		&totals.Folders, &totals.Files, &totals.ArchiveMembers, &totals.Errors)
+	if err != nil {
+		return totals, fmt.Errorf("failed to count the entries, error: %v", err)
+	}
+	var totalSize sql.NullInt64
+	err = db.QueryRow(`SELECT TotalCalFolderSize, CalLastWriteTime FROM fileinfo WHERE Path = ?;`, rootPath).Scan(&totalSize, &totals.LastWrite)
+	if err != nil {
+		return totals, fmt.Errorf("failed to read the scan root, error: %v", err)
+	}
+	totals.TotalSize = totalSize.Int64
+	return totals, nil
+}
+
+// returns true if the report DB has the given table
+func tableExists(db *sql.DB, table string) (bool, error) {
+	var count int
+	err := db.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?;`, table).Scan(&count)
+	if err != nil {
+		return false, fmt.Errorf("failed to look up the %s table: %v", table, err)
+	}
+	return count > 0, nil
+}
+
+// formats a time of the report DB for the console, "-" when missing
+func formatReportTime(t sql.NullTime) string {
+	if !t.Valid || t.Time.IsZero() {
+		return "-"
+	}
+	return t.Time.Format("2006-01-02 15:04:05")
+}
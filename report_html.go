@@ -0,0 +1,350 @@
+//go:build windows || !windows
+// +build windows !windows
+
+package main
+
+import (
+	"database/sql"
+	_ "embed"
+	"fmt"
+	"html/template"
+	"os"
+	"strings"
+	"time"
+)
+
+// the page of report -html, the data is filled in by html/template and drawn by its inline script
+//
+//go:embed report_html.tmpl
+var reportHTMLTemplate string
+
+// biggest folders drawn in the treemap of the HTML report, which keeps the file small for any scan
+const htmlTreemapFolders = 3000
+
+// owners listed by name in the HTML report, the others are added up
+const htmlReportOwners = 15
+
+// age buckets of the folders by CalLastWriteTime, relative to the report time
+var reportAgeBuckets = []struct {
+	Label  string
+	MaxAge time.Duration
+}{
+	{"Under 30 days", 30 * 24 * time.Hour},
+	{"30 to 90 days", 90 * 24 * time.Hour},
+	{"90 days to 1 year", 365 * 24 * time.Hour},
+	{"1 to 2 years", 2 * 365 * 24 * time.Hour},
+	{"2 to 5 years", 5 * 365 * 24 * time.Hour},
+	{"Over 5 years", 1<<63 - 1},
+}
+
+// Represents the data of the HTML report
+type HTMLReport struct {
+	RootPath     string
+	Separator    string
+	Generated    string
+	LastScan     string
+	Totals       ReportTotals
+	Treemap      *TreemapNode
+	TopFolders   []HTMLReportEntry
+	TopFiles     []HTMLReportEntry
+	Ages         []HTMLReportBar
+	Owners       []HTMLReportBar
+	ErrorSummary []HTMLReportBar
+	ErrorSamples []HTMLReportEntry
+}
+
+// Represents one folder of the treemap, short JSON names as it can have thousands of them
+type TreemapNode struct {
+	Name     string         `json:"n"` // relative to the parent node
+	Size     int64          `json:"s"` // TotalCalFolderSize
+	Children []*TreemapNode `json:"c,omitempty"`
+	path     string
+}
+
+// Represents one row of the top-N and error lists
+type HTMLReportEntry struct {
+	Path      string
+	Size      int64
+	LastWrite string
+	Owner     string
+	Error     string
+}
+
+// Represents one bar of the charts and the error summary
+type HTMLReportBar struct {
+	Label string
+	Count int64
+	Size  int64
+	Share float64 // percent of the biggest bar
+}
+
+// writes a single offline HTML file with the treemap, top-N lists, age and owner charts and errors of a report DB
+func writeHTMLReport(db *sql.DB, htmlFile string, top int) error {
+	rootPath, rootDepth, err := getScanRoot(db)
+	if err != nil {
+		return err
+	}
+	report := HTMLReport{RootPath: rootPath, Separator: reportPathSeparator(rootPath), Generated: time.Now().Format("2006-01-02 15:04:05")}
+	if report.Totals, err = readReportTotals(db, rootPath); err != nil {
+		return err
+	}
+	if report.LastScan, err = readLastScanRun(db); err != nil {
+		return err
+	}
+	if report.Treemap, err = readTreemap(db, rootPath); err != nil {
+		return err
+	}
+	if top > 0 {
+		report.TopFolders, err = readHTMLReportEntries(db, `SELECT Path, TotalCalFolderSize, CalLastWriteTime, IFNULL(Owner, ''), '' FROM fileinfo
+			WHERE ObjType = 'd' AND ObjectDepth > ? ORDER BY TotalCalFolderSize DESC LIMIT ?;`, rootDepth, top)
+		if err != nil {
+			return fmt.Errorf("failed to read the largest folders, error: %v", err)
+		}
+		report.TopFiles, err = readHTMLReportEntries(db, `SELECT Path, FileSize, LastWriteTime, IFNULL(Owner, ''), '' FROM fileinfo
+			WHERE ObjType = 'f' ORDER BY FileSize DESC LIMIT ?;`, top)
+		if err != nil {
+			return fmt.Errorf("failed to read the largest files, error: %v", err)
+		}
+	}
+	if report.Ages, err = readAgeDistribution(db, time.Now()); err != nil {
+		return err
+	}
+	if report.Owners, err = readSizeByOwner(db); err != nil {
+		return err
+	}
+	if report.Totals.Errors > 0 {
+		if err := readHTMLErrors(db, &report, top); err != nil {
+			return err
+		}
+	}
+
+	page, err := template.New("report").Funcs(template.FuncMap{"formatBytes": formatBytes}).Parse(reportHTMLTemplate)
+	if err != nil {
+		return fmt.Errorf("failed to parse the HTML template, error: %v", err)
+	}
+	file, err := os.Create(htmlFile)
+	if err != nil {
+		return fmt.Errorf("failed to create %s, error: %v", htmlFile, err)
+	}
+	defer file.Close()
+	if err := page.Execute(file, report); err != nil {
+		return fmt.Errorf("failed to write %s, error: %v", htmlFile, err)
+	}
+	return file.Close()
+}
+
+// returns the start, mode and status of the last scan run, "" for report DBs without the scan_runs table
+func readLastScanRun(db *sql.DB) (string, error) {
+	if exists, err := tableExists(db, "scan_runs"); err != nil || !exists {
+		return "", err
+	}
+	var run ScanRun
+	err := db.QueryRow(`SELECT Mode, Status, StartTime FROM scan_runs ORDER BY RunID DESC LIMIT 1;`).Scan(&run.Mode, &run.Status, &run.StartTime)
+	if err == sql.ErrNoRows {
+		return "", nil
+	} else if err != nil {
+		return "", fmt.Errorf("failed to read the scan runs, error: %v", err)
+	}
+	return fmt.Sprintf("%s (%s, %s)", formatReportTime(sql.NullTime{Time: run.StartTime, Valid: true}), run.Mode, run.Status), nil
+}
+
+// reads the biggest folders into a tree below the scan root. A folder whose parent is not among
+// them is attached to its closest ancestor which is, its name then has several path elements.
+func readTreemap(db *sql.DB, rootPath string) (*TreemapNode, error) {
+	// a folder is never bigger than its parent, ties are broken by the depth so that the parent comes first
+	rows, err := db.Query(`SELECT Path, IFNULL(TotalCalFolderSize, 0) FROM fileinfo WHERE ObjType = 'd'
+		ORDER BY TotalCalFolderSize DESC, ObjectDepth LIMIT ?;`, htmlTreemapFolders)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the folders for the treemap, error: %v", err)
+	}
+	defer rows.Close()
+
+	separator := reportPathSeparator(rootPath)
+	nodes := make(map[string]*TreemapNode)
+	var folders []*TreemapNode
+	for rows.Next() {
+		node := new(TreemapNode)
+		if err := rows.Scan(&node.path, &node.Size); err != nil {
+			return nil, fmt.Errorf("failed to scan a fileinfo row: %v", err)
+		}
+		nodes[node.path] = node
+		folders = append(folders, node)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	root, exists := nodes[rootPath]
+	if !exists {
+		return nil, fmt.Errorf("the scan root %s is not among the biggest folders", rootPath)
+	}
+	root.Name = rootPath
+	for _, node := range folders {
+		if node == root {
+			continue
+		}
+		parentPath := node.path
+		var parent *TreemapNode
+		for parent == nil {
+			parentPath = pathParent(parentPath, rootPath, separator)
+			parent = nodes[parentPath]
+		}
+		node.Name = strings.TrimPrefix(node.path, childPathPrefix(parentPath, separator))
+		parent.Children = append(parent.Children, node)
+	}
+	return root, nil
+}
+
+// reads the path, size, time, owner and error of a top-N or error list
+func readHTMLReportEntries(db *sql.DB, query string, args ...interface{}) ([]HTMLReportEntry, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var entries []HTMLReportEntry
+	for rows.Next() {
+		var entry HTMLReportEntry
+		var size sql.NullInt64
+		var lastWrite sql.NullTime
+		if err := rows.Scan(&entry.Path, &size, &lastWrite, &entry.Owner, &entry.Error); err != nil {
+			return nil, err
+		}
+		entry.Size = size.Int64
+		entry.LastWrite = formatReportTime(lastWrite)
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+// returns the number of folders and the size of the files directly in them by the age of CalLastWriteTime
+func readAgeDistribution(db *sql.DB, now time.Time) ([]HTMLReportBar, error) {
+	rows, err := db.Query(`SELECT CalLastWriteTime, IFNULL(ThisFolderSize, 0) FROM fileinfo WHERE ObjType = 'd';`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the folder times, error: %v", err)
+	}
+	defer rows.Close()
+
+	bars := make([]HTMLReportBar, len(reportAgeBuckets)+1)
+	for i, bucket := range reportAgeBuckets {
+		bars[i].Label = bucket.Label
+	}
+	unknown := &bars[len(reportAgeBuckets)]
+	unknown.Label = "Unknown"
+	for rows.Next() {
+		var lastWrite sql.NullTime
+		var size int64
+		if err := rows.Scan(&lastWrite, &size); err != nil {
+			return nil, fmt.Errorf("failed to scan a fileinfo row: %v", err)
+		}
+		bar := unknown
+		if lastWrite.Valid && !lastWrite.Time.IsZero() {
+			age := now.Sub(lastWrite.Time)
+			for i, bucket := range reportAgeBuckets {
+				if age < bucket.MaxAge {
+					bar = &bars[i]
+					break
+				}
+			}
+		}
+		bar.Count++
+		bar.Size += size
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if unknown.Count == 0 {
+		bars = bars[:len(reportAgeBuckets)]
+	}
+	setBarShares(bars)
+	return bars, nil
+}
+
+// returns the number and size of the files per owner, biggest first
+func readSizeByOwner(db *sql.DB) ([]HTMLReportBar, error) {
+	rows, err := db.Query(`SELECT IFNULL(Owner, ''), COUNT(*), SUM(IFNULL(FileSize, 0)) AS Size FROM fileinfo
+		WHERE ObjType = 'f' GROUP BY Owner ORDER BY Size DESC;`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the owners, error: %v", err)
+	}
+	defer rows.Close()
+	var bars []HTMLReportBar
+	for rows.Next() {
+		var bar HTMLReportBar
+		if err := rows.Scan(&bar.Label, &bar.Count, &bar.Size); err != nil {
+			return nil, fmt.Errorf("failed to scan a fileinfo row: %v", err)
+		}
+		if bar.Label == "" {
+			bar.Label = "(unknown)"
+		}
+		if len(bars) == htmlReportOwners {
+			bars = append(bars, HTMLReportBar{Label: "Other owners"})
+		}
+		if len(bars) > htmlReportOwners {
+			bars[htmlReportOwners].Count += bar.Count
+			bars[htmlReportOwners].Size += bar.Size
+			continue
+		}
+		bars = append(bars, bar)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	setBarShares(bars)
+	return bars, nil
+}
+
+// reads the error counts by stage and code and the first failed paths
+func readHTMLErrors(db *sql.DB, report *HTMLReport, top int) error {
+	columns, err := fileinfoColumns(db)
+	if err != nil {
+		return err
+	}
+	summaryQuery := `SELECT 'errors', COUNT(*) FROM fileinfo WHERE hasError = 1;`
+	samplesQuery := `SELECT Path, FileSize, LastWriteTime, IFNULL(Owner, ''), IFNULL(ErrorMessage, '') FROM fileinfo
+		WHERE hasError = 1 ORDER BY Path LIMIT ?;`
+	if columns["ErrorCode"] && columns["ErrorStage"] {
+		summaryQuery = `SELECT IFNULL(ErrorStage, '') || ' ' || IFNULL(ErrorCode, ''), COUNT(*) AS ErrorCount FROM fileinfo
+			WHERE hasError = 1 GROUP BY ErrorStage, ErrorCode ORDER BY ErrorCount DESC;`
+		samplesQuery = `SELECT Path, FileSize, LastWriteTime, IFNULL(Owner, ''), IFNULL(ErrorCode, '') || ': ' || IFNULL(ErrorMessage, '')
+			FROM fileinfo WHERE hasError = 1 ORDER BY Path LIMIT ?;`
+	}
+	rows, err := db.Query(summaryQuery)
+	if err != nil {
+		return fmt.Errorf("failed to read the error summary, error: %v", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var bar HTMLReportBar
+		if err := rows.Scan(&bar.Label, &bar.Count); err != nil {
+			return fmt.Errorf("failed to scan a fileinfo row: %v", err)
+		}
+		report.ErrorSummary = append(report.ErrorSummary, bar)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	setBarShares(report.ErrorSummary)
+
+	if top > 0 {
+		if report.ErrorSamples, err = readHTMLReportEntries(db, samplesQuery, top); err != nil {
+			return fmt.Errorf("failed to read the errors, error: %v", err)
+		}
+	}
+	return nil
+}
+
+// sets the bar widths relative to the biggest size, or count when there are no sizes
+func setBarShares(bars []HTMLReportBar) {
+	var maxSize, maxCount int64
+	for _, bar := range bars {
+		maxSize = max(maxSize, bar.Size)
+		maxCount = max(maxCount, bar.Count)
+	}
+	for i := range bars {
+		if maxSize > 0 {
+			bars[i].Share = float64(bars[i].Size) * 100 / float64(maxSize)
+		} else if maxCount > 0 {
+			bars[i].Share = float64(bars[i].Count) * 100 / float64(maxCount)
+		}
+	}
+}
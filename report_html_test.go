@@ -0,0 +1,72 @@
+//go:build windows || !windows
+// +build windows !windows
+
+package main
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWriteHTMLReport(t *testing.T) {
+	dir := t.TempDir()
+	dbFile := filepath.Join(dir, "report.db")
+	writeBrowseTestDB(t, dbFile)
+	db, err := sql.Open("sqlite", dbFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Exec(`UPDATE fileinfo SET hasError = 1, ErrorCode = 'EACCES', ErrorStage = 'stat', ErrorMessage = 'permission denied'
+		WHERE Path = '/data/c.txt';
+		UPDATE fileinfo SET Owner = '<script>alert(1)</script>' WHERE Path = '/data/a/y.log';`)
+	db.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db, err = openReportDB(dbFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	htmlFile := filepath.Join(dir, "report.html")
+	if err := writeHTMLReport(db, htmlFile, 2); err != nil {
+		t.Fatal(err)
+	}
+	content, err := os.ReadFile(htmlFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	page := string(content)
+	for _, want := range []string{
+		"<title>FolderInsight report - /data</title>",
+		"<b>2</b><span>folders</span>",
+		"<b>5</b><span>files</span>",
+		"<b>1</b><span>archive members</span>",
+		"<b>1</b><span>errors</span>",
+		`<td class="path">/data/a</td>`,       // the biggest folder below the root
+		`<td class="path">/data/a/x.txt</td>`, // the two biggest files
+		`<td class="path">/data/b.txt</td>`,
+		`<td>stat EACCES</td>`,
+		`<td class="path">/data/c.txt</td><td>EACCES: permission denied</td>`,
+		`&lt;script&gt;alert(1)&lt;/script&gt;`,
+		`"n":"/data"`,
+		`"n":"a"`,
+		`const separator = "/";`,
+	} {
+		if !strings.Contains(page, want) {
+			t.Errorf("the HTML report has no %s", want)
+		}
+	}
+	for _, unwanted := range []string{
+		`<td class="path">/data/c.txt</td></tr>`, // only the 2 biggest files are listed, c.txt is an error sample
+		"<script>alert(1)</script>",
+	} {
+		if strings.Contains(page, unwanted) {
+			t.Errorf("the HTML report has %s", unwanted)
+		}
+	}
+}
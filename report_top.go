@@ -0,0 +1,340 @@
+//go:build windows || !windows
+// +build windows !windows
+
+package main
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// Represents one entry of report top
+type TopEntry struct {
+	Path          string
+	ObjType       string
+	ObjectDepth   int
+	Size          int64      // TotalCalFolderSize of a folder, FileSize otherwise
+	Files         *int64     `json:",omitempty"` // files below the folder, with -Metric=count
+	PreviousSize  *int64     `json:",omitempty"` // size in the -Previous report DB, 0 for new entries, with -Metric=growth
+	Growth        *int64     `json:",omitempty"`
+	Owner         string     `json:",omitempty"`
+	LastWriteTime *time.Time `json:",omitempty"` // CalLastWriteTime of a folder
+}
+
+// Represents the options of report top
+type TopOptions struct {
+	ObjType  string
+	MinDepth int
+	MaxDepth int // 0 is no limit
+	Metric   string
+	Owner    string // glob pattern, "" matches all
+	N        int
+}
+
+// runReportTop implements "report top" and returns the process exit code
+func runReportTop(args []string) int {
+	var reportDBfile, previousDBfile, format string
+	var options TopOptions
+
+	topFlags := flag.NewFlagSet("report top", flag.ExitOnError)
+	topFlags.StringVar(&reportDBfile, "DBfile", "", "Scan report DB file (mandatory)")
+	topFlags.StringVar(&options.ObjType, "ObjType", "d", "Entries listed: d (folders), f (files) or a (archive members) (optional)")
+	topFlags.IntVar(&options.MinDepth, "MinDepth", 0, "Lowest ObjectDepth listed, the scan root is depth 1 (optional)")
+	topFlags.IntVar(&options.MaxDepth, "MaxDepth", 0, "Highest ObjectDepth listed, 0 is no limit (optional)")
+	topFlags.StringVar(&options.Metric, "Metric", "size", "Ranking: size (largest), count (most files below a folder), age (oldest last write) or growth (size change since -Previous) (optional)")
+	topFlags.StringVar(&options.Owner, "Owner", "", "Only the entries of this owner, * and ? match any characters, e.g. CORP\\j* (optional)")
+	topFlags.IntVar(&options.N, "N", 50, "Number of entries listed (optional)")
+	topFlags.StringVar(&previousDBfile, "Previous", "", "Report DB of an earlier scan of the same folder, for -Metric=growth (optional)")
+	topFlags.StringVar(&format, "Format", "table", "Output format: table, csv or json (optional)")
+	if exitCode, ok := parseCommandFlags(topFlags, args); !ok {
+		return exitCode
+	}
+
+	if reportDBfile == "" {
+		fmt.Println("Mandatory fields are missing, check with report top -help")
+		return 2
+	}
+	switch {
+	case options.ObjType != "d" && options.ObjType != "f" && options.ObjType != "a":
+		fmt.Println("-ObjType must be d, f or a")
+		return 2
+	case options.Metric != "size" && options.Metric != "count" && options.Metric != "age" && options.Metric != "growth":
+		fmt.Println("-Metric must be size, count, age or growth")
+		return 2
+	case options.Metric == "count" && options.ObjType != "d":
+		fmt.Println("-Metric=count needs -ObjType=d")
+		return 2
+	case (options.Metric == "growth") != (previousDBfile != ""):
+		fmt.Println("-Metric=growth needs the -Previous report DB, which is only used for it")
+		return 2
+	case options.MinDepth < 0 || options.MaxDepth < 0 || (options.MaxDepth > 0 && options.MaxDepth < options.MinDepth):
+		fmt.Println("-MinDepth and -MaxDepth must be positive and -MinDepth not above -MaxDepth")
+		return 2
+	case options.N <= 0:
+		fmt.Println("-N must be greater than 0")
+		return 2
+	case format != "table" && format != "csv" && format != "json":
+		fmt.Println("-Format must be table, csv or json")
+		return 2
+	}
+
+	var db *sql.DB
+	var err error
+	if options.Metric == "growth" {
+		db, err = openGrowthDBs(reportDBfile, previousDBfile)
+	} else {
+		db, err = openReportDB(reportDBfile)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer db.Close()
+
+	entries, err := readTopEntries(db, options)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if err := writeTopEntries(os.Stdout, entries, options.Metric, format); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+// opens a private in-memory DB with the report DB attached read only as report, and the entries of the
+// previous report DB, attached as old, in the old_entries temp table
+func openGrowthDBs(reportDBfile string, previousDBfile string) (*sql.DB, error) {
+	reportDBfile, err := reportDBPath(reportDBfile)
+	if err != nil {
+		return nil, err
+	}
+	if previousDBfile, err = reportDBPath(previousDBfile); err != nil {
+		return nil, err
+	}
+	db, err := sql.Open("sqlite", "file::memory:")
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(1) // attached DBs and temp tables belong to a single connection
+	if _, err := db.Exec(`ATTACH DATABASE ? AS report;`, "file:"+filepath.ToSlash(reportDBfile)+"?mode=ro"); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to open %s error message: %v", reportDBfile, err)
+	}
+	if _, err := attachDiffDB(db, "old", previousDBfile); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// returns the top entries of the report DB by options.Metric
+func readTopEntries(db *sql.DB, options TopOptions) ([]TopEntry, error) {
+	table := "fileinfo"
+	if options.Metric == "growth" {
+		table = "report.fileinfo" // the previous report DB is attached too, see openGrowthDBs
+	}
+	rootPath, _, err := getTableScanRoot(db, table)
+	if err != nil {
+		return nil, err
+	}
+	// ?1 and ?2 are taken by diffRelPathSQL
+	filters := []string{`ObjType = ?3`}
+	args := []interface{}{rootPath, diffPathPrefix(rootPath), options.ObjType}
+	if options.MinDepth > 0 {
+		args = append(args, options.MinDepth)
+		filters = append(filters, fmt.Sprintf(`ObjectDepth >= ?%d`, len(args)))
+	}
+	if options.MaxDepth > 0 {
+		args = append(args, options.MaxDepth)
+		filters = append(filters, fmt.Sprintf(`ObjectDepth <= ?%d`, len(args)))
+	}
+	if options.Owner != "" {
+		args = append(args, options.Owner)
+		filters = append(filters, fmt.Sprintf(`Owner GLOB ?%d`, len(args)))
+	}
+
+	columns := `Path, ObjType, ObjectDepth, ` + apiEntrySizeSQL + ` AS Size, IFNULL(Owner, ''), ` + apiEntryTimeSQL + ` AS LastWrite`
+	order := ``
+	switch options.Metric {
+	case "size":
+		order = `Size DESC, Path`
+	case "age":
+		// entries without a time, e.g. the folders missing from a tar, have the zero time 0001-01-01
+		filters = append(filters, `substr(`+apiEntryTimeSQL+`, 1, 4) > '0001'`)
+		// the times are stored with the zone of the scan, they are compared in UTC like v_stale_files does
+		order = utcTimeSQL(apiEntryTimeSQL) + `, Path`
+	case "growth":
+		// a lookup rather than a join, old_entries has columns named like those of fileinfo
+		previousSize := `IFNULL((SELECT old.Size FROM temp.old_entries old WHERE old.RelPath = ` + diffRelPathSQL + `), 0)`
+		columns += `, ` + previousSize + ` AS PreviousSize, ` + apiEntrySizeSQL + ` - ` + previousSize + ` AS Growth`
+		order = `Growth DESC, Path`
+	case "count":
+		// every folder matching the filters is needed, they are ranked by their file count afterwards
+		order = `Path`
+	}
+	query := `SELECT ` + columns + ` FROM ` + table + ` WHERE ` + strings.Join(filters, ` AND `) + ` ORDER BY ` + order
+	if options.Metric != "count" {
+		args = append(args, options.N)
+		query += fmt.Sprintf(` LIMIT ?%d`, len(args))
+	}
+	rows, err := db.Query(query+`;`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the top entries, error: %v", err)
+	}
+	defer rows.Close()
+
+	var entries []TopEntry
+	for rows.Next() {
+		var entry TopEntry
+		var lastWrite sql.NullString
+		values := []interface{}{&entry.Path, &entry.ObjType, &entry.ObjectDepth, &entry.Size, &entry.Owner, &lastWrite}
+		if options.Metric == "growth" {
+			entry.PreviousSize, entry.Growth = new(int64), new(int64)
+			values = append(values, entry.PreviousSize, entry.Growth)
+		}
+		if err := rows.Scan(values...); err != nil {
+			return nil, fmt.Errorf("failed to scan a fileinfo row: %v", err)
+		}
+		entry.LastWriteTime = apiTime(parseReportTime(lastWrite.String))
+		entries = append(entries, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if options.Metric == "count" {
+		fileCounts, err := readFolderFileCounts(db, table, rootPath)
+		if err != nil {
+			return nil, err
+		}
+		for i := range entries {
+			files := fileCounts[entries[i].Path]
+			entries[i].Files = &files
+		}
+		sort.SliceStable(entries, func(i, j int) bool { return *entries[i].Files > *entries[j].Files })
+		entries = entries[:min(len(entries), options.N)]
+	}
+	return entries, nil
+}
+
+// returns the number of files below every folder, its sub folders included. Archive members are not counted.
+func readFolderFileCounts(db *sql.DB, table string, rootPath string) (map[string]int64, error) {
+	separator := reportPathSeparator(rootPath)
+	fileCounts := make(map[string]int64)
+	rows, err := db.Query(`SELECT Path FROM ` + table + ` WHERE ObjType NOT IN ('d', 'a');`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the files, error: %v", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var path string
+		if err := rows.Scan(&path); err != nil {
+			return nil, fmt.Errorf("failed to scan a fileinfo row: %v", err)
+		}
+		fileCounts[pathParent(path, rootPath, separator)]++
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	// the deepest folders first, so that a folder has all its sub folders added when it is added to its parent
+	rows, err = db.Query(`SELECT Path FROM ` + table + ` WHERE ObjType = 'd' ORDER BY ObjectDepth DESC;`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the folders, error: %v", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var path string
+		if err := rows.Scan(&path); err != nil {
+			return nil, fmt.Errorf("failed to scan a fileinfo row: %v", err)
+		}
+		if path != rootPath {
+			fileCounts[pathParent(path, rootPath, separator)] += fileCounts[path]
+		}
+	}
+	return fileCounts, rows.Err()
+}
+
+// writes the top entries as an aligned table, CSV with a header line or a JSON array
+func writeTopEntries(w io.Writer, entries []TopEntry, metric string, format string) error {
+	if format == "json" {
+		if entries == nil {
+			entries = []TopEntry{}
+		}
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(entries)
+	}
+
+	header := []string{"Size"}
+	switch metric {
+	case "count":
+		header = append(header, "Files")
+	case "growth":
+		header = append(header, "Previous size", "Growth")
+	}
+	header = append(header, "Depth", "Last write", "Owner", "Path")
+	if format == "csv" {
+		header = append([]string{"Path", "ObjType"}, header[:len(header)-1]...)
+		csvWriter := csv.NewWriter(w)
+		csvWriter.Write(header)
+		for _, entry := range entries {
+			record := []string{entry.Path, entry.ObjType, strconv.FormatInt(entry.Size, 10)}
+			if entry.Files != nil {
+				record = append(record, strconv.FormatInt(*entry.Files, 10))
+			}
+			if entry.Growth != nil {
+				record = append(record, strconv.FormatInt(*entry.PreviousSize, 10), strconv.FormatInt(*entry.Growth, 10))
+			}
+			record = append(record, strconv.Itoa(entry.ObjectDepth), formatTopTime(entry.LastWriteTime), entry.Owner)
+			csvWriter.Write(record)
+		}
+		csvWriter.Flush()
+		return csvWriter.Error()
+	}
+
+	out := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(out, strings.Join(header, "\t"))
+	for _, entry := range entries {
+		record := []string{formatBytes(entry.Size)}
+		if entry.Files != nil {
+			record = append(record, strconv.FormatInt(*entry.Files, 10))
+		}
+		if entry.Growth != nil {
+			record = append(record, formatBytes(*entry.PreviousSize), formatSizeChange(*entry.Growth))
+		}
+		owner := entry.Owner
+		if owner == "" {
+			owner = "-"
+		}
+		lastWrite := formatTopTime(entry.LastWriteTime)
+		if lastWrite == "" {
+			lastWrite = "-"
+		}
+		record = append(record, strconv.Itoa(entry.ObjectDepth), lastWrite, owner, entry.Path)
+		fmt.Fprintln(out, strings.Join(record, "\t"))
+	}
+	return out.Flush()
+}
+
+// formats a time of report top, "" when missing
+func formatTopTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format("2006-01-02 15:04:05")
+}
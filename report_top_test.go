@@ -0,0 +1,103 @@
+//go:build windows || !windows
+// +build windows !windows
+
+package main
+
+import (
+	"database/sql"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// writes a report DB with the files of sizes below root, and the root folder with their total
+func writeTopTestDB(t *testing.T, file string, root string, sizes map[string]int64) {
+	t.Helper()
+	db, err := sql.Open("sqlite", file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := db.Exec(`CREATE TABLE fileinfo (ObjType TEXT, Path TEXT PRIMARY KEY, ObjectDepth INTEGER, FileSize INTEGER,
+		TotalCalFolderSize INTEGER, Owner TEXT, LastWriteTime DATETIME, CalLastWriteTime DATETIME);`); err != nil {
+		t.Fatal(err)
+	}
+	var total int64
+	for name, size := range sizes {
+		total += size
+		if _, err := db.Exec(`INSERT INTO fileinfo VALUES ('f', ?, 2, ?, 0, 'alice', NULL, NULL);`, root+"/"+name, size); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := db.Exec(`INSERT INTO fileinfo VALUES ('d', ?, 1, 0, ?, 'alice', NULL, NULL);`, root, total); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// the previous scan had another root, the entries are matched by their path below it
+func TestReadTopEntriesGrowth(t *testing.T) {
+	dir := t.TempDir()
+	previous, current := filepath.Join(dir, "previous.db"), filepath.Join(dir, "current.db")
+	writeTopTestDB(t, previous, "/old", map[string]int64{"a": 100, "b": 50, "gone": 70})
+	writeTopTestDB(t, current, "/new", map[string]int64{"a": 110, "b": 500, "c": 20})
+
+	db, err := openGrowthDBs(current, previous)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	entries, err := readTopEntries(db, TopOptions{ObjType: "f", Metric: "growth", N: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		path   string
+		growth int64
+	}{{"/new/b", 450}, {"/new/c", 20}}
+	if len(entries) != len(want) {
+		t.Fatalf("readTopEntries() = %d entries, want %d", len(entries), len(want))
+	}
+	for i, entry := range entries {
+		if entry.Path != want[i].path || entry.Growth == nil || *entry.Growth != want[i].growth {
+			t.Fatalf("entry %d = %s %v, want %s %+d", i, entry.Path, entry.Growth, want[i].path, want[i].growth)
+		}
+	}
+}
+
+// the last write times of other zones are ranked in UTC, the zero time of the entries without one is skipped
+func TestReadTopEntriesAge(t *testing.T) {
+	dbFile := filepath.Join(t.TempDir(), "top.db")
+	writeTopTestDB(t, dbFile, "/data", map[string]int64{"a": 1, "b": 1, "c": 1, "none": 1})
+	db, err := sql.Open("sqlite", dbFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for path, lastWrite := range map[string]string{
+		"/data/a":    "2024-01-02 05:04:05 +0200 EET", // 03:04:05 UTC
+		"/data/b":    "2024-01-02 01:04:05 -0500 EST", // 06:04:05 UTC
+		"/data/c":    "2024-01-02 04:00:00.5 +0000 UTC",
+		"/data/none": "0001-01-01 00:00:00 +0000 UTC",
+	} {
+		if _, err := db.Exec(`UPDATE fileinfo SET LastWriteTime = ? WHERE Path = ?;`, lastWrite, path); err != nil {
+			t.Fatal(err)
+		}
+	}
+	db.Close()
+
+	db, err = openReportDB(dbFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	entries, err := readTopEntries(db, TopOptions{ObjType: "f", Metric: "age", N: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var paths []string
+	for _, entry := range entries {
+		paths = append(paths, entry.Path)
+	}
+	if want := []string{"/data/a", "/data/c", "/data/b"}; !reflect.DeepEqual(paths, want) {
+		t.Fatalf("readTopEntries() = %q, want %q", paths, want)
+	}
+}
@@ -0,0 +1,81 @@
+//go:build windows || !windows
+// +build windows !windows
+
+package main
+
+import (
+	"database/sql"
+)
+
+// secondary indexes for the usual ad-hoc queries, created after the rollup so that the inserts stay fast
+var reportIndexesSQL = []string{
+	`CREATE INDEX IF NOT EXISTS idx_fileinfo_objtype ON fileinfo (ObjType);`,
+	`CREATE INDEX IF NOT EXISTS idx_fileinfo_depth ON fileinfo (ObjectDepth);`,
+	`CREATE INDEX IF NOT EXISTS idx_fileinfo_owner ON fileinfo (Owner);`,
+	`CREATE INDEX IF NOT EXISTS idx_fileinfo_totalcalfoldersize ON fileinfo (TotalCalFolderSize);`,
+	`CREATE INDEX IF NOT EXISTS idx_fileinfo_lastwritetime ON fileinfo (LastWriteTime);`,
+}
+
+// returns column, a Go time string like "2024-01-02 15:04:05.999 -0700 MST", as the UTC "YYYY-MM-DD HH:MM:SS"
+// of datetime(), so that it compares with datetime('now', ...) whatever the offset and the fractional seconds
+func utcTimeSQL(column string) string {
+	offset := `20 + instr(substr(` + column + `, 20), ' ')` // the sign of the -0700 offset after the seconds and their fraction
+	return `datetime(substr(` + column + `, 1, 19) || substr(` + column + `, ` + offset + `, 3) || ':' || substr(` + column + `, ` + offset + ` + 3, 2))`
+}
+
+// prebuilt views, recreated on every run so that older report DBs get the latest definitions
+var reportViewsSQL = map[string]string{
+	// folders with the biggest total size (including the sub folders)
+	"v_largest_folders": `SELECT Path, ObjectDepth, TotalCalFolderSize, ThisFolderSize, CalLastWriteTime, Owner
+		FROM fileinfo WHERE ObjType = 'd' ORDER BY TotalCalFolderSize DESC`,
+	// files with the biggest size
+	"v_largest_files": `SELECT Path, ObjectDepth, FileSize, LastWriteTime, Owner
+		FROM fileinfo WHERE ObjType = 'f' ORDER BY FileSize DESC`,
+	// files not written for more than a year, oldest first
+	"v_stale_files": `SELECT Path, ObjectDepth, FileSize, LastWriteTime, LastAccessTime, Owner
+		FROM fileinfo WHERE ObjType = 'f' AND ` + utcTimeSQL("LastWriteTime") + ` < datetime('now', '-365 days') ORDER BY ` + utcTimeSQL("LastWriteTime"),
+	// everything which failed during the scan
+	"v_errors": `SELECT ObjType, Path, ObjectDepth, ErrorCode, ErrorStage, ErrorMessage
+		FROM fileinfo WHERE hasError = 1 ORDER BY Path`,
+	// number of errors per stage and code
+	"v_error_summary": `SELECT ErrorStage, ErrorCode, COUNT(*) AS ErrorCount
+		FROM fileinfo WHERE hasError = 1 GROUP BY ErrorStage, ErrorCode ORDER BY ErrorCount DESC`,
+	// number and size of the files per owner
+	"v_by_owner": `SELECT Owner, COUNT(*) AS FileCount, SUM(FileSize) AS TotalFileSize, MAX(LastWriteTime) AS LastWriteTime
+		FROM fileinfo WHERE ObjType = 'f' GROUP BY Owner ORDER BY TotalFileSize DESC`,
+	// number and size of the folders per depth
+	"v_by_depth": `SELECT ObjectDepth, COUNT(*) AS FolderCount, SUM(ThisFolderSize) AS TotalFileSize
+		FROM fileinfo WHERE ObjType = 'd' GROUP BY ObjectDepth ORDER BY ObjectDepth`,
+}
+
+// creates the analysis views and, when enabled, the secondary indexes on the report DB
+func createIndexesAndViews(createIndexes bool) {
+	logger.Info("Starting the createIndexesAndViews now")
+	// Open the database connection
+	db, err := sql.Open("sqlite", DBfile)
+	if err != nil {
+		logger.Error("Failed to open the report DB", "db", DBfile, "error", err)
+		return
+	}
+	defer db.Close()
+
+	if createIndexes {
+		for _, indexSQL := range reportIndexesSQL {
+			if _, err := db.Exec(indexSQL); err != nil {
+				logger.Error("Failed to create index", "query", indexSQL, "error", err)
+			}
+		}
+	}
+
+	for viewName, viewSQL := range reportViewsSQL {
+		if _, err := db.Exec(`DROP VIEW IF EXISTS ` + viewName + `;`); err != nil {
+			logger.Error("Failed to drop the old view", "view", viewName, "error", err)
+			continue
+		}
+		if _, err := db.Exec(`CREATE VIEW ` + viewName + ` AS ` + viewSQL + `;`); err != nil {
+			logger.Error("Failed to create the view", "view", viewName, "error", err)
+		}
+	}
+
+	logger.Info("End of createIndexesAndViews")
+}
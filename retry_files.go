@@ -0,0 +1,178 @@
+//go:build windows || !windows
+// +build windows !windows
+
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/abhilash945/FolderInsight/pkg/scanner"
+)
+
+// Represents a failed file to stat again with -UpdateErrorOnly
+type ErrorFileInfo struct {
+	Path        string
+	ObjectDepth int
+	FileSize    int // size stored by the failed scan, replaced by the new one in its parent folder
+}
+
+var (
+	folderSizeDeltas      = make(map[string]int) // parent folder => change of ThisFolderSize after the file retries
+	folderSizeDeltasMutex sync.Mutex
+)
+
+// flag.Value of -RetryErrorCodes
+type errorCodeList []string
+
+func (codes *errorCodeList) String() string {
+	return strings.Join(*codes, ",")
+}
+
+func (codes *errorCodeList) Set(value string) error {
+	*codes = parseErrorCodes(value)
+	return nil
+}
+
+// parses the -RetryErrorCodes value, e.g. "EACCES, etimedout"
+func parseErrorCodes(list string) []string {
+	var codes []string
+	for _, code := range strings.Split(list, ",") {
+		if code = strings.ToUpper(strings.TrimSpace(code)); code != "" {
+			codes = append(codes, code)
+		}
+	}
+	return codes
+}
+
+// returns the " AND ErrorCode IN (...)" condition and its arguments for -RetryErrorCodes, empty when all codes are retried
+func errorCodeFilter() (string, []interface{}) {
+	if len(retryErrorCodes) == 0 {
+		return "", nil
+	}
+	args := make([]interface{}, 0, len(retryErrorCodes))
+	for _, code := range retryErrorCodes {
+		args = append(args, code)
+	}
+	return ` AND ErrorCode IN (?` + strings.Repeat(", ?", len(retryErrorCodes)-1) + `)`, args
+}
+
+// returns the failed files which are not inside one of the folders being scanned again anyway
+func readErrorFiles(db *sql.DB, errorFolders []ErrorObjectInfo) ([]ErrorFileInfo, error) {
+	retriedFolders := make(map[string]bool, len(errorFolders))
+	for _, folder := range errorFolders {
+		retriedFolders[folder.Path] = true
+	}
+
+	codeFilter, args := errorCodeFilter()
+	// archive errors are about the contents, a new stat would only clear them
+	query := `SELECT Path, ObjectDepth, FileSize FROM fileinfo WHERE ObjType = 'f' and hasError = '1'
+	AND IFNULL(ErrorStage, '') <> 'archive'` + codeFilter + `;`
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query: %s error is %v", query, err)
+	}
+	defer rows.Close()
+
+	var errorFiles []ErrorFileInfo
+	for rows.Next() {
+		var errorFile ErrorFileInfo
+		var fileSize sql.NullInt64
+		if err := rows.Scan(&errorFile.Path, &errorFile.ObjectDepth, &fileSize); err != nil {
+			return nil, fmt.Errorf("failed to scan a row: %v", err)
+		}
+		errorFile.FileSize = int(fileSize.Int64)
+		if !insideRetriedFolder(errorFile.Path, retriedFolders) {
+			errorFiles = append(errorFiles, errorFile)
+		}
+	}
+	return errorFiles, rows.Err()
+}
+
+// returns true if one of the ancestors of path is in retriedFolders
+func insideRetriedFolder(path string, retriedFolders map[string]bool) bool {
+	for parent := filepath.Dir(path); ; parent = filepath.Dir(parent) {
+		if retriedFolders[parent] {
+			return true
+		}
+		if grandParent := filepath.Dir(parent); grandParent == parent {
+			return false
+		}
+	}
+}
+
+// stats the failed files again without listing their folders, wg is done when all of them are sent to FSdata
+func retryErrorFiles(ctx context.Context, folderScanner *scanner.Scanner, errorFiles []ErrorFileInfo, FSdata chan<- ObjectInfo, wg *sync.WaitGroup) {
+	fileChan := make(chan ErrorFileInfo)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for errorFile := range fileChan {
+				retryErrorFile(ctx, folderScanner, errorFile, FSdata)
+			}
+		}()
+	}
+	go func() {
+		defer close(fileChan)
+		for _, errorFile := range errorFiles {
+			if ctx.Err() != nil {
+				// the remaining files keep their error and are retried next time
+				return
+			}
+			fileChan <- errorFile
+		}
+	}()
+}
+
+// stats one failed file again and records the size change of its folder
+func retryErrorFile(ctx context.Context, folderScanner *scanner.Scanner, errorFile ErrorFileInfo, FSdata chan<- ObjectInfo) {
+	newFileData := folderScanner.StatFile(ctx, scanner.Folder{Path: errorFile.Path, ObjectDepth: errorFile.ObjectDepth})
+	select {
+	case FSdata <- newFileData:
+	case <-ctx.Done():
+		return // the DB writer may have stopped, the file stays failed in the report
+	}
+	if delta := newFileData.FileSize - errorFile.FileSize; delta != 0 {
+		folderSizeDeltasMutex.Lock()
+		folderSizeDeltas[filepath.Dir(errorFile.Path)] += delta
+		folderSizeDeltasMutex.Unlock()
+	}
+}
+
+// applies the size changes of the retried files to the ThisFolderSize of their folders,
+// updateSizeLastWriteDate then carries them up to all the ancestors
+func applyFolderSizeDeltas() error {
+	folderSizeDeltasMutex.Lock()
+	defer folderSizeDeltasMutex.Unlock()
+	if len(folderSizeDeltas) == 0 {
+		return nil
+	}
+
+	db, err := sql.Open("sqlite", DBfile)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %v", err)
+	}
+	for folderPath, delta := range folderSizeDeltas {
+		_, err := tx.Exec(`UPDATE fileinfo SET ThisFolderSize = ThisFolderSize + ? WHERE Path = ? AND ObjType = 'd';`, delta, folderPath)
+		if err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to update ThisFolderSize of %s: %v", folderPath, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	logger.Info("Updated the size of the folders with retried files", "folders", len(folderSizeDeltas))
+	return nil
+}
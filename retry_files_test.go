@@ -0,0 +1,75 @@
+//go:build windows || !windows
+// +build windows !windows
+
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestInsideRetriedFolder(t *testing.T) {
+	root := filepath.FromSlash("/data")
+	retried := map[string]bool{
+		filepath.Join(root, "a"):      true,
+		filepath.Join(root, "b", "c"): true,
+	}
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{filepath.Join(root, "a", "f.txt"), true},
+		{filepath.Join(root, "a", "x", "y", "f.txt"), true},
+		{filepath.Join(root, "b", "c", "f.txt"), true},
+		{filepath.Join(root, "b", "f.txt"), false},
+		{filepath.Join(root, "ab", "f.txt"), false},
+		{filepath.Join(root, "f.txt"), false},
+		{"f.txt", false},
+	}
+	for _, test := range tests {
+		if got := insideRetriedFolder(test.path, retried); got != test.want {
+			t.Errorf("insideRetriedFolder(%q) = %v, want %v", test.path, got, test.want)
+		}
+	}
+	if insideRetriedFolder(filepath.Join(root, "a", "f.txt"), nil) {
+		t.Error("insideRetriedFolder() without retried folders returned true")
+	}
+}
+
+func TestParseErrorCodes(t *testing.T) {
+	tests := []struct {
+		list string
+		want []string
+	}{
+		{"", nil},
+		{"EACCES", []string{"EACCES"}},
+		{"EACCES, etimedout", []string{"EACCES", "ETIMEDOUT"}},
+		{" ,eio,, ", []string{"EIO"}},
+	}
+	for _, test := range tests {
+		if got := parseErrorCodes(test.list); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("parseErrorCodes(%q) = %q, want %q", test.list, got, test.want)
+		}
+	}
+}
+
+func TestErrorCodeFilter(t *testing.T) {
+	defer func(codes []string) { retryErrorCodes = codes }(retryErrorCodes)
+	tests := []struct {
+		codes    []string
+		want     string
+		wantArgs []interface{}
+	}{
+		{nil, "", nil},
+		{[]string{"EACCES"}, " AND ErrorCode IN (?)", []interface{}{"EACCES"}},
+		{[]string{"EACCES", "EIO"}, " AND ErrorCode IN (?, ?)", []interface{}{"EACCES", "EIO"}},
+	}
+	for _, test := range tests {
+		retryErrorCodes = test.codes
+		got, args := errorCodeFilter()
+		if got != test.want || !reflect.DeepEqual(args, test.wantArgs) {
+			t.Errorf("errorCodeFilter() with %q = %q, %v, want %q, %v", test.codes, got, args, test.want, test.wantArgs)
+		}
+	}
+}
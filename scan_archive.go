@@ -0,0 +1,228 @@
+//go:build windows || !windows
+// +build windows !windows
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/abhilash945/FolderInsight/pkg/scanner"
+	"github.com/klauspost/compress/zstd"
+)
+
+// magic numbers of the compressed tar streams detected by -Compression=auto
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// runScanArchive implements the "scan-archive" subcommand, a report of a tar file or stream without
+// extracting it. The paths are relative to the "." root of the archive. Returns the process exit code.
+func runScanArchive(args []string) int {
+	var archivePath, compression string
+	var stripComponents int
+
+	archiveFlags := flag.NewFlagSet("scan-archive", flag.ExitOnError)
+	archiveFlags.StringVar(&archivePath, "Archive", "", "Tar file to scan, - reads it from stdin (mandatory)")
+	archiveFlags.StringVar(&DBfile, "DBfile", "", "Result report DB file (mandatory)")
+	archiveFlags.StringVar(&compression, "Compression", "auto", "Compression of the tar: none, gzip, zstd or auto to detect it (optional)")
+	archiveFlags.IntVar(&stripComponents, "StripComponents", 0, "Leading path elements removed from the member names, like tar --strip-components (optional)")
+	archiveFlags.StringVar(&maxReadBytesPerSec, "MaxReadBytesPerSec", "0", "Max archive bytes read per second, e.g. 20MB, 0 is unlimited (optional)")
+	archiveFlags.IntVar(&channelSize, "BufferSize", 100000, "meta data buffer size (optional)")
+	archiveFlags.IntVar(&insertionBatchSizeSQL, "SQLBatchSize", 200, "DB batch size for buffered insertions (optional)")
+	archiveFlags.BoolVar(&createIndexes, "CreateIndexes", true, "Create the secondary indexes on the report DB after the scan (optional, default is true)")
+	archiveFlags.DurationVar(&progressInterval, "ProgressInterval", 10*time.Second, "Progress report interval, 0 disables it (optional)")
+	archiveFlags.BoolVar(&debug, "debug", false, "Enable debug logging, the same as -LogLevel=debug (optional, default is false)")
+	addLogFlags(archiveFlags)
+	if exitCode, ok := parseCommandFlags(archiveFlags, args); !ok {
+		return exitCode
+	}
+
+	if archivePath == "" || DBfile == "" {
+		fmt.Println("Mandatory fields are missing, check with -help")
+		return 2
+	}
+	switch compression {
+	case "auto", "none", "gzip", "zstd":
+	default:
+		fmt.Println("-Compression must be none, gzip, zstd or auto")
+		return 2
+	}
+	if stripComponents < 0 {
+		fmt.Println("-StripComponents cannot be negative")
+		return 2
+	}
+	readBytesPerSec, err := parseByteSize(maxReadBytesPerSec)
+	if err != nil || readBytesPerSec < 0 {
+		fmt.Println("-MaxReadBytesPerSec must be 0 or a positive number")
+		return 2
+	}
+	level, err := parseLogFlags()
+	if err != nil {
+		fmt.Println(err)
+		return 2
+	}
+	if !strings.HasSuffix(DBfile, ".db") {
+		DBfile += ".db"
+	}
+	if _, err := os.Stat(DBfile); err == nil {
+		fmt.Println("Looks like the DBfile", DBfile, "already exists.")
+		fmt.Println("Run the report to a new file.")
+		return 2
+	} else if !errors.Is(err, os.ErrNotExist) {
+		fmt.Println("Error while checking", DBfile, "error message:", err)
+		return 2
+	}
+
+	archiveFile := os.Stdin
+	archiveName := "stdin"
+	if archivePath != "-" {
+		var err error
+		if archiveFile, err = os.Open(archivePath); err != nil {
+			fmt.Println("Cannot read the Archive,", archivePath, "error message:", err)
+			return 2
+		}
+		defer archiveFile.Close()
+		archiveName = filepath.Base(archivePath)
+	}
+
+	logFile, logFileName, err := openScanLog(DBfile, level)
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
+	defer logFile.Close()
+	fmt.Println("Logs will be saved to", logFileName, "file.")
+	startTime := time.Now()
+	logger.Info("Scan started", "start_time", startTime.Format("20060102_150405"))
+	logger.Info("Scanning the archive", "archive", archiveName, "db", DBfile)
+
+	// the rollup works on the slash separated paths below the "." root, like for any io/fs scan
+	dirPath = "."
+	runID, err := startScanRun(archiveName, "archive", startTime)
+	if err != nil {
+		logger.Error("Failed to record the scan run", "error", err)
+	}
+
+	// the headers are read first, the stream can only be read once
+	readBytesLimiter.SetRate(float64(readBytesPerSec))
+	limitedArchive := scanner.NewLimitedReader(context.Background(), archiveFile, readBytesLimiter)
+	tarReader, closeReader, err := decompressTar(bufio.NewReaderSize(limitedArchive, 1<<20), compression)
+	if err != nil {
+		logger.Error("Failed to read the archive", "archive", archiveName, "error", err)
+		if err := finishScanRun(runID, "interrupted"); err != nil {
+			logger.Error("Failed to record the scan run", "error", err)
+		}
+		return 1
+	}
+	tarFS, readErr := scanner.NewTarFS(tarReader, stripComponents)
+	closeReader()
+	if readErr != nil {
+		logger.Error("Failed to read the archive, scanning the entries read so far", "archive", archiveName, "error", readErr)
+	}
+	logger.Info("Read the archive entries", "archive", archiveName, "entries", tarFS.Len(), "duration", time.Since(startTime).Round(time.Millisecond).String())
+	if skipped := tarFS.Skipped(); skipped > 0 {
+		logger.Warn("Skipped members with names leaving the archive, e.g. ../x", "members", skipped)
+	}
+	if promoted := tarFS.Promoted(); promoted > 0 {
+		logger.Warn("Scanned file members as folders because other members are below them, e.g. x and x/y", "members", promoted)
+	}
+
+	FSdata := make(chan ObjectInfo, channelSize) //channel for new data
+	ctx, cancel := context.WithCancel(context.Background())
+	handleShutdownSignals(cancel)
+
+	scanOptions := scanner.Options{
+		Workers:  8, // everything is in memory already
+		FS:       tarFS,
+		Counters: &counters.Counters,
+		Logger:   logger,
+	}
+	folderScanner := scanner.New(scanOptions)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		folderScanner.Scan(ctx, FSdata, scanner.Folder{Path: ".", ObjectDepth: 1})
+	}()
+
+	var wg2 sync.WaitGroup
+	wg2.Add(1)
+	go writeMetaDataToSQliteDB(FSdata, &wg2, cancel, DBfile)
+	progressDone := make(chan struct{})
+	if progressInterval > 0 {
+		go reportProgress(progressInterval, func() int { return len(FSdata) }, int64(tarFS.Len()+1), progressDone)
+	}
+	wg.Wait()
+	close(FSdata)
+	wg2.Wait()
+	close(progressDone)
+	logger.Info(progressLine(time.Since(startTime), 0, 0))
+	if ctx.Err() != nil {
+		logger.Error("Scan interrupted, run it again to a new DBfile")
+		if err := finishScanRun(runID, "interrupted"); err != nil {
+			logger.Error("Failed to record the scan run", "error", err)
+		}
+		return 1
+	}
+	cancel()
+
+	updateSizeLastWriteDate()
+	createIndexesAndViews(createIndexes)
+	logger.Info("Scan ended", "end_time", time.Now().Format("20060102_150405"))
+	status := "completed"
+	if readErr != nil {
+		status = "interrupted"
+	}
+	if err := finishScanRun(runID, status); err != nil {
+		logger.Error("Failed to record the scan run", "error", err)
+	}
+	if readErr != nil || droppedRowCount > 0 {
+		logger.Error("The End, the report is incomplete!", "missing_entries", droppedRowCount)
+		return 1
+	}
+	logger.Info("The End!")
+	return 0
+}
+
+// returns the tar stream inside a compressed one, compression is none, gzip, zstd or auto to detect
+// it from the first bytes. The returned function releases the decompressor.
+func decompressTar(reader *bufio.Reader, compression string) (io.Reader, func(), error) {
+	if compression == "auto" {
+		compression = "none"
+		magic, _ := reader.Peek(len(zstdMagic))
+		if bytes.HasPrefix(magic, gzipMagic) {
+			compression = "gzip"
+		} else if bytes.HasPrefix(magic, zstdMagic) {
+			compression = "zstd"
+		}
+		logger.Info("Detected compression", "compression", compression)
+	}
+	switch compression {
+	case "gzip":
+		gzipReader, err := gzip.NewReader(reader)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read the gzip stream, error: %v", err)
+		}
+		return gzipReader, func() { gzipReader.Close() }, nil
+	case "zstd":
+		zstdReader, err := zstd.NewReader(reader)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read the zstd stream, error: %v", err)
+		}
+		return zstdReader, zstdReader.Close, nil
+	}
+	return reader, func() {}, nil
+}
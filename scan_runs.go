@@ -0,0 +1,114 @@
+//go:build windows || !windows
+// +build windows !windows
+
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Represents one run of the tool against a report DB, stored in the scan_runs table
+type ScanRun struct {
+	RunID       int64
+	RootPath    string
+	Mode        string // scan, retry, resume or archive
+	Status      string // running, completed or interrupted
+	StartTime   time.Time
+	EndTime     time.Time
+	Folders     int64
+	Files       int64
+	Bytes       int64
+	Errors      int64
+	RowsWritten int64
+}
+
+const createScanRunsTableSQL = `
+    CREATE TABLE IF NOT EXISTS scan_runs (
+        RunID INTEGER PRIMARY KEY AUTOINCREMENT,
+        RootPath TEXT,
+        Mode TEXT,
+        Status TEXT,
+        StartTime DATETIME,
+        EndTime DATETIME,
+        Folders INTEGER,
+        Files INTEGER,
+        Bytes INTEGER,
+        Errors INTEGER,
+        RowsWritten INTEGER
+    );`
+
+// records the start of a run and returns its RunID
+func startScanRun(rootPath string, mode string, startTime time.Time) (int64, error) {
+	db, err := sql.Open("sqlite", DBfile)
+	if err != nil {
+		return 0, err
+	}
+	defer db.Close()
+
+	if _, err := db.Exec(createScanRunsTableSQL); err != nil {
+		return 0, fmt.Errorf("failed to create scan_runs table: %v", err)
+	}
+	result, err := db.Exec(`INSERT INTO scan_runs (RootPath, Mode, Status, StartTime) VALUES (?, ?, 'running', ?);`,
+		rootPath, mode, startTime.Round(0))
+	if err != nil {
+		return 0, fmt.Errorf("failed to insert into scan_runs: %v", err)
+	}
+	return result.LastInsertId()
+}
+
+// records the end of a run with the final counters
+func finishScanRun(runID int64, status string) error {
+	db, err := sql.Open("sqlite", DBfile)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	_, err = db.Exec(`UPDATE scan_runs SET Status = ?, EndTime = ?, Folders = ?, Files = ?, Bytes = ?, Errors = ?, RowsWritten = ?
+		WHERE RunID = ?;`, status, time.Now().Round(0), counters.Folders.Load(), counters.Files.Load(),
+		counters.Bytes.Load(), counters.Errors.Load(), counters.RowsWritten.Load(), runID)
+	if err != nil {
+		return fmt.Errorf("failed to update scan_runs: %v", err)
+	}
+	return nil
+}
+
+// returns the number of entries (folders and files) found by the latest complete scan of rootPath
+// in the given report DB, 0 if there is none
+func previousScanEntries(reportDBfile string, rootPath string) (int64, error) {
+	db, err := openReportDB(reportDBfile)
+	if err != nil {
+		return 0, err
+	}
+	defer db.Close()
+
+	hasRuns, err := tableExists(db, "scan_runs")
+	if err != nil {
+		return 0, err
+	}
+	var entries int64
+	if hasRuns {
+		err = db.QueryRow(`SELECT Folders + Files FROM scan_runs WHERE RootPath = ? AND Mode = 'scan' AND Status = 'completed'
+			ORDER BY RunID DESC LIMIT 1;`, rootPath).Scan(&entries)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return 0, fmt.Errorf("failed to read the scan_runs table: %v", err)
+		}
+		return entries, nil
+	}
+
+	// report DBs from older versions have no scan_runs table, count the rows if the root matches
+	scanRoot, _, err := getScanRoot(db)
+	if err != nil {
+		return 0, err
+	}
+	if scanRoot != rootPath {
+		return 0, nil
+	}
+	if err := db.QueryRow(`SELECT COUNT(*) FROM fileinfo;`).Scan(&entries); err != nil {
+		return 0, fmt.Errorf("failed to count the fileinfo rows: %v", err)
+	}
+	return entries, nil
+}
@@ -0,0 +1,65 @@
+//go:build windows || !windows
+// +build windows !windows
+
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPreviousScanEntries(t *testing.T) {
+	dir := t.TempDir()
+	// a report DB of an older version, without the scan_runs table
+	oldDB := filepath.Join(dir, "old.db")
+	writeExportTestDB(t, oldDB, "/data/", "/data/a/", "/data/a/x.txt")
+	// a report DB with its runs, the latest complete scan of /data found 7 entries
+	runsDB := filepath.Join(dir, "runs.db")
+	writeExportTestDB(t, runsDB, "/data/")
+	db, err := sql.Open("sqlite", runsDB)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Exec(createScanRunsTableSQL + `
+		INSERT INTO scan_runs (RootPath, Mode, Status, Folders, Files) VALUES
+		('/data', 'scan', 'completed', 1, 2),
+		('/data', 'scan', 'completed', 3, 4),
+		('/data', 'scan', 'interrupted', 10, 10),
+		('/data', 'retry', 'completed', 20, 20),
+		('/other', 'scan', 'completed', 30, 30);`)
+	db.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		file     string
+		rootPath string
+		want     int64
+	}{
+		{oldDB, "/data", 3},
+		{oldDB, "/other", 0},
+		{runsDB, "/data", 7},
+		{runsDB, "/other", 60},
+		{runsDB, "/data/a", 0},
+		{filepath.Join(dir, "runs"), "/data", 7}, // the .db extension is optional
+	}
+	for _, test := range tests {
+		entries, err := previousScanEntries(test.file, test.rootPath)
+		if err != nil || entries != test.want {
+			t.Fatalf("previousScanEntries(%s, %s) = %d, %v, want %d", filepath.Base(test.file), test.rootPath, entries, err, test.want)
+		}
+	}
+
+	// a mistyped file name is an error, not an empty DB
+	missing := filepath.Join(dir, "typo.db")
+	if _, err := previousScanEntries(missing, "/data"); err == nil {
+		t.Fatal("previousScanEntries() of a missing file did not fail")
+	}
+	if _, err := os.Stat(missing); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("previousScanEntries() created %s", missing)
+	}
+}
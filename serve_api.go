@@ -0,0 +1,652 @@
+//go:build windows || !windows
+// +build windows !windows
+
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// entries per page when the request has no limit
+const defaultAPIPageSize = 100
+
+// size and time of an entry, the totals of the sub folders for a folder
+const (
+	apiEntrySizeSQL = `CASE ObjType WHEN 'd' THEN IFNULL(TotalCalFolderSize, 0) ELSE IFNULL(FileSize, 0) END`
+	apiEntryTimeSQL = `CASE ObjType WHEN 'd' THEN CalLastWriteTime ELSE LastWriteTime END`
+)
+
+// the JSON API over the served report DBs, an http.Handler which can be tested with httptest
+type apiServer struct {
+	dbs         map[string]*apiDB
+	names       []string
+	token       string
+	maxPageSize int
+}
+
+// Represents an entry in the API responses
+type APIEntry struct {
+	Path          string
+	Name          string
+	ObjType       string
+	ObjectDepth   int        `json:",omitempty"`
+	Size          int64      // TotalCalFolderSize of a folder, FileSize otherwise
+	Entries       *int64     `json:",omitempty"` // folders, files and archive members below it, in the children only
+	Owner         string     `json:",omitempty"`
+	LastWriteTime *time.Time `json:",omitempty"` // CalLastWriteTime of a folder
+	HasError      bool
+	ErrorCode     string `json:",omitempty"`
+	ErrorStage    string `json:",omitempty"`
+	ErrorMessage  string `json:",omitempty"`
+}
+
+// Represents one page of entries, NextOffset is only set when there are more
+type APIPage struct {
+	Items      []APIEntry
+	Offset     int
+	Limit      int
+	NextOffset *int `json:",omitempty"`
+}
+
+// Represents the totals of a subtree
+type APISummary struct {
+	Path              string
+	ObjType           string
+	Size              int64
+	Owner             string `json:",omitempty"`
+	Folders           int64
+	Files             int64
+	ArchiveMembers    int64
+	Errors            int64
+	FileSize          int64
+	ArchiveMemberSize int64
+	LastWriteTime     *time.Time `json:",omitempty"`
+}
+
+// Represents a served report DB and its scan runs
+type APIScan struct {
+	Name           string
+	File           string
+	RootPath       string
+	Separator      string
+	DBSize         int64
+	DBModified     time.Time
+	Folders        int64
+	Files          int64
+	Errors         int64
+	ArchiveMembers int64
+	TotalSize      int64
+	LastWriteTime  *time.Time `json:",omitempty"`
+	ScanRuns       []ScanRun
+}
+
+// returns the handler of the API. token is the bearer token required by every request, "" for none.
+func newAPIServer(dbs []*apiDB, token string, maxPageSize int) (http.Handler, error) {
+	server := &apiServer{dbs: make(map[string]*apiDB), token: token, maxPageSize: maxPageSize}
+	for _, served := range dbs {
+		if _, exists := server.dbs[served.Name]; exists {
+			return nil, fmt.Errorf("two report DBs are named %s, rename one of the files", served.Name)
+		}
+		server.dbs[served.Name] = served
+		server.names = append(server.names, served.Name)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/dbs", server.handleDBs)
+	mux.HandleFunc("GET /api/dbs/{db}/scan", server.withDB(server.handleScan))
+	mux.HandleFunc("GET /api/dbs/{db}/children", server.withDB(server.handleChildren))
+	mux.HandleFunc("GET /api/dbs/{db}/summary", server.withDB(server.handleSummary))
+	mux.HandleFunc("GET /api/dbs/{db}/search", server.withDB(server.handleSearch))
+	mux.HandleFunc("GET /api/dbs/{db}/top", server.withDB(server.handleTop))
+	mux.HandleFunc("GET /api/dbs/{db}/errors", server.withDB(server.handleErrors))
+	return server.authorize(mux), nil
+}
+
+// checks the bearer token when one is set
+func (server *apiServer) authorize(next http.Handler) http.Handler {
+	if server.token == "" {
+		return next
+	}
+	expected := []byte("Bearer " + server.token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), expected) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="FolderInsight"`)
+			writeAPIError(w, http.StatusUnauthorized, "missing or wrong bearer token")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// looks up the {db} of the request path
+func (server *apiServer) withDB(handler func(http.ResponseWriter, *http.Request, *apiDB)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		served, exists := server.dbs[r.PathValue("db")]
+		if !exists {
+			writeAPIError(w, http.StatusNotFound, fmt.Sprintf("no report DB named %q", r.PathValue("db")))
+			return
+		}
+		handler(w, r, served)
+	}
+}
+
+// writes a JSON response with an ETag of its content, a request with the same If-None-Match gets 304
+func writeAPIJSON(w http.ResponseWriter, r *http.Request, value interface{}) {
+	body, err := json.Marshal(value)
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	sum := sha256.Sum256(body)
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "no-cache") // a retry run can change the DB, so the clients check the ETag
+	for _, match := range strings.Split(r.Header.Get("If-None-Match"), ",") {
+		if match = strings.TrimPrefix(strings.TrimSpace(match), "W/"); match == etag || match == "*" {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(append(body, '\n'))
+}
+
+func writeAPIError(w http.ResponseWriter, status int, message string) {
+	if status >= http.StatusInternalServerError {
+		logger.Error("API error", "error", message)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"Error": message})
+}
+
+// reads the limit and offset parameters of a paged request
+func (server *apiServer) readPage(r *http.Request) (int, int, error) {
+	limit, offset := defaultAPIPageSize, 0
+	if text := r.URL.Query().Get("limit"); text != "" {
+		value, err := strconv.Atoi(text)
+		if err != nil || value <= 0 {
+			return 0, 0, fmt.Errorf("limit must be a positive number")
+		}
+		limit = value
+	}
+	if text := r.URL.Query().Get("offset"); text != "" {
+		value, err := strconv.Atoi(text)
+		if err != nil || value < 0 {
+			return 0, 0, fmt.Errorf("offset cannot be negative")
+		}
+		offset = value
+	}
+	return min(limit, server.maxPageSize), offset, nil
+}
+
+// returns the path parameter, the scan root when it is missing
+func (served *apiDB) readPath(r *http.Request) string {
+	if path := r.URL.Query().Get("path"); path != "" {
+		return path
+	}
+	return served.rootPath
+}
+
+// returns the condition limiting a query to the entries below a path, "" for the scan root
+func (served *apiDB) subtreeCondition(path string) (string, []interface{}) {
+	prefix, upper := subtreeBounds(path, served.separator)
+	if path == served.rootPath || prefix == "" {
+		return "", nil
+	}
+	return "Path > ? AND Path < ?", []interface{}{prefix, upper}
+}
+
+// true if the path has a row or, like the folders inside an archive, entries below it
+func (served *apiDB) pathExists(path string) (bool, error) {
+	var count int
+	err := served.db.QueryRow(`SELECT COUNT(*) FROM fileinfo WHERE Path = ?;`, path).Scan(&count)
+	if err != nil || count > 0 {
+		return count > 0, err
+	}
+	condition, args := served.subtreeCondition(path)
+	if condition == "" {
+		return false, nil
+	}
+	err = served.db.QueryRow(`SELECT COUNT(*) FROM (SELECT 1 FROM fileinfo WHERE `+condition+` LIMIT 1);`, args...).Scan(&count)
+	return count > 0, err
+}
+
+func (server *apiServer) handleDBs(w http.ResponseWriter, r *http.Request) {
+	type apiDBInfo struct {
+		Name     string
+		File     string
+		RootPath string
+	}
+	infos := make([]apiDBInfo, 0, len(server.names))
+	for _, name := range server.names {
+		served := server.dbs[name]
+		infos = append(infos, apiDBInfo{served.Name, served.File, served.rootPath})
+	}
+	writeAPIJSON(w, r, infos)
+}
+
+func (server *apiServer) handleScan(w http.ResponseWriter, r *http.Request, served *apiDB) {
+	scan := APIScan{Name: served.Name, File: served.File, RootPath: served.rootPath, Separator: served.separator, ScanRuns: []ScanRun{}}
+	if info, err := os.Stat(served.File); err == nil {
+		scan.DBSize, scan.DBModified = info.Size(), info.ModTime()
+	}
+	totals, err := readReportTotals(served.db, served.rootPath)
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	scan.Folders, scan.Files, scan.ArchiveMembers, scan.Errors = totals.Folders, totals.Files, totals.ArchiveMembers, totals.Errors
+	scan.TotalSize, scan.LastWriteTime = totals.TotalSize, apiTime(totals.LastWrite)
+
+	if exists, err := tableExists(served.db, "scan_runs"); err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err.Error())
+		return
+	} else if exists {
+		rows, err := served.db.Query(`SELECT RunID, IFNULL(RootPath, ''), IFNULL(Mode, ''), IFNULL(Status, ''), StartTime, EndTime,
+			IFNULL(Folders, 0), IFNULL(Files, 0), IFNULL(Bytes, 0), IFNULL(Errors, 0), IFNULL(RowsWritten, 0) FROM scan_runs ORDER BY RunID;`)
+		if err != nil {
+			writeAPIError(w, http.StatusInternalServerError, fmt.Sprintf("failed to read the scan runs, error: %v", err))
+			return
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var run ScanRun
+			var startTime, endTime sql.NullTime
+			if err := rows.Scan(&run.RunID, &run.RootPath, &run.Mode, &run.Status, &startTime, &endTime,
+				&run.Folders, &run.Files, &run.Bytes, &run.Errors, &run.RowsWritten); err != nil {
+				writeAPIError(w, http.StatusInternalServerError, fmt.Sprintf("failed to scan a scan_runs row: %v", err))
+				return
+			}
+			run.StartTime, run.EndTime = startTime.Time, endTime.Time
+			scan.ScanRuns = append(scan.ScanRuns, run)
+		}
+		if err := rows.Err(); err != nil {
+			writeAPIError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+	}
+	writeAPIJSON(w, r, scan)
+}
+
+// lists the entries directly below a path, sort is size, name, count, owner or time and order asc or desc
+func (server *apiServer) handleChildren(w http.ResponseWriter, r *http.Request, served *apiDB) {
+	limit, offset, err := server.readPage(r)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	column := r.URL.Query().Get("sort")
+	switch column {
+	case "":
+		column = "size"
+	case "size", "name", "count", "owner", "time":
+	default:
+		writeAPIError(w, http.StatusBadRequest, "sort must be size, name, count, owner or time")
+		return
+	}
+	descending := column == "size" || column == "count" || column == "time"
+	switch r.URL.Query().Get("order") {
+	case "asc":
+		descending = false
+	case "desc":
+		descending = true
+	case "":
+	default:
+		writeAPIError(w, http.StatusBadRequest, "order must be asc or desc")
+		return
+	}
+
+	path := served.readPath(r)
+	entries, more, err := served.readChildrenPage(path, column, descending, limit, offset)
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if len(entries) == 0 {
+		if exists, err := served.pathExists(path); err != nil {
+			writeAPIError(w, http.StatusInternalServerError, err.Error())
+			return
+		} else if !exists {
+			writeAPIError(w, http.StatusNotFound, fmt.Sprintf("no entry %q", path))
+			return
+		}
+	}
+
+	page := APIPage{Items: []APIEntry{}, Offset: offset, Limit: limit}
+	for _, entry := range entries {
+		count := entry.Entries
+		page.Items = append(page.Items, APIEntry{Path: entry.Path, Name: entry.Name, ObjType: entry.ObjType, Size: entry.Size,
+			Entries: &count, Owner: entry.Owner, LastWriteTime: apiTime(entry.LastWrite), HasError: entry.HasError})
+	}
+	if more {
+		nextOffset := offset + limit
+		page.NextOffset = &nextOffset
+	}
+	writeAPIJSON(w, r, page)
+}
+
+// the sort columns of the children, like sortBrowseEntries does it
+var apiChildrenOrderSQL = map[string][]string{
+	"size":  {"EntrySize"},
+	"name":  {"lower(Child)"},
+	"count": {"EntryCount"},
+	"owner": {"lower(EntryOwner)"},
+	"time":  {utcTimeSQL("EntryTime"), "EntryTime"},
+}
+
+// returns the number of rows below the entry at childPath, counted over its range of the Path primary key.
+// ?4 is the separator and ?7 the character after it.
+func entryCountSQL(childPath string) string {
+	return `(SELECT COUNT(*) FROM fileinfo below WHERE below.Path > ` + childPath + ` || ?4 AND below.Path < ` + childPath + ` || ?7)`
+}
+
+// reads one page of the entries directly below a folder, sorted by SQLite, and whether more entries follow it.
+// The page is read from the rows of the folders one level down and of the files in the folder, and only
+// the page rows are counted, unless they are sorted by count. The folders inside an archive have no rows
+// of their own, the entries below an archive are grouped by child like readBrowseEntries does.
+func (served *apiDB) readChildrenPage(path string, column string, descending bool, limit int, offset int) ([]BrowseEntry, bool, error) {
+	prefix, upper := subtreeBounds(path, served.separator)
+	rangeFilter := `Path <> ?1`
+	if prefix != "" {
+		rangeFilter += ` AND Path > ?2 AND Path < ?3`
+	}
+	direction := " ASC"
+	if descending {
+		direction = " DESC"
+	}
+	var orderBy []string
+	for _, key := range apiChildrenOrderSQL[column] {
+		orderBy = append(orderBy, key+direction)
+	}
+	order := strings.Join(orderBy, ", ") + `, Child`
+
+	var objType string
+	var depth int
+	err := served.db.QueryRow(`SELECT ObjType, ObjectDepth FROM fileinfo WHERE Path = ?;`, path).Scan(&objType, &depth)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, false, fmt.Errorf("failed to read %s, error: %v", path, err)
+	}
+	var query string
+	args := []interface{}{path, prefix, upper, served.separator, limit + 1, offset}
+	if err == nil && objType == "d" {
+		// a file has the depth of its folder, the archive members are below their archive file. The
+		// depth index finds the rows of the root level only, below it the Path range is the smaller one.
+		depthColumn := "ObjectDepth"
+		if path != served.rootPath {
+			depthColumn = "+ObjectDepth"
+		}
+		pageCount := `NULL`
+		if column == "count" {
+			pageCount = entryCountSQL("child.Path")
+		}
+		query = `SELECT Child, EntryType, EntrySize, IFNULL(EntryCount, ` + entryCountSQL("Child") + `), EntryOwner, EntryTime, EntryError FROM (
+			SELECT Path AS Child, ObjType AS EntryType, ` + apiEntrySizeSQL + ` AS EntrySize, ` + pageCount + ` AS EntryCount,
+				IFNULL(Owner, '') AS EntryOwner, CASE ObjType WHEN 'd' THEN IFNULL(CalLastWriteTime, LastWriteTime) ELSE LastWriteTime END AS EntryTime,
+				IFNULL(hasError, 0) AS EntryError
+			FROM fileinfo child WHERE ` + rangeFilter + ` AND ((ObjType = 'd' AND ` + depthColumn + ` = ?8 + 1) OR (ObjType NOT IN ('d', 'a') AND ` + depthColumn + ` = ?8))
+			ORDER BY ` + order + ` LIMIT ?5 OFFSET ?6)
+		ORDER BY ` + order + `;`
+		args = append(args, string(rune(served.separator[0]+1)), depth)
+	} else {
+		query = `SELECT Child, EntryType, EntrySize, EntryCount, EntryOwner, EntryTime, EntryError FROM (
+			SELECT Child, CASE WHEN MAX(Path = Child) THEN MAX(ObjType) FILTER (WHERE Path = Child) ELSE 'a' END AS EntryType,
+				CASE WHEN MAX(Path = Child) THEN MAX(RowSize) FILTER (WHERE Path = Child)
+					ELSE IFNULL(SUM(FileSize) FILTER (WHERE ObjType = 'a'), 0) END AS EntrySize,
+				COUNT(*) - SUM(Path = Child) AS EntryCount, IFNULL(MAX(Owner) FILTER (WHERE Path = Child), '') AS EntryOwner,
+				CASE WHEN MAX(Path = Child) THEN MAX(RowTime) FILTER (WHERE Path = Child) ELSE MAX(LastWriteTime) END AS EntryTime,
+				IFNULL(MAX(hasError) FILTER (WHERE Path = Child), 0) AS EntryError
+			FROM (SELECT Path, ObjType, FileSize, Owner, LastWriteTime, hasError, ` + apiEntrySizeSQL + ` AS RowSize,
+				CASE ObjType WHEN 'd' THEN IFNULL(CalLastWriteTime, LastWriteTime) ELSE LastWriteTime END AS RowTime,
+				` + childPathSQL + ` AS Child FROM fileinfo WHERE ` + rangeFilter + `)
+			GROUP BY Child)
+		ORDER BY ` + order + ` LIMIT ?5 OFFSET ?6;`
+	}
+	rows, err := served.db.Query(query, args...)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to read the entries of %s, error: %v", path, err)
+	}
+	defer rows.Close()
+
+	var entries []BrowseEntry
+	for rows.Next() {
+		var entry BrowseEntry
+		var lastWrite sql.NullString
+		if err := rows.Scan(&entry.Path, &entry.ObjType, &entry.Size, &entry.Entries, &entry.Owner, &lastWrite, &entry.HasError); err != nil {
+			return nil, false, fmt.Errorf("failed to read the entries of %s, error: %v", path, err)
+		}
+		entry.Name = strings.TrimPrefix(entry.Path, prefix)
+		entry.LastWrite = parseReportTime(lastWrite.String)
+		entries = append(entries, entry)
+	}
+	if len(entries) > limit {
+		return entries[:limit], true, rows.Err()
+	}
+	return entries, false, rows.Err()
+}
+
+// returns the totals of a folder and everything below it
+func (server *apiServer) handleSummary(w http.ResponseWriter, r *http.Request, served *apiDB) {
+	path := served.readPath(r)
+	summary := APISummary{Path: path, ObjType: "a"} // a folder inside an archive has no row
+	var lastWrite, calLastWrite sql.NullTime
+	err := served.db.QueryRow(`SELECT ObjType, `+apiEntrySizeSQL+`, IFNULL(Owner, ''), LastWriteTime, CalLastWriteTime FROM fileinfo WHERE Path = ?;`,
+		path).Scan(&summary.ObjType, &summary.Size, &summary.Owner, &lastWrite, &calLastWrite)
+	if err != nil && err != sql.ErrNoRows {
+		writeAPIError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	found := err == nil
+
+	query := `SELECT COUNT(*) FILTER (WHERE ObjType = 'd'), COUNT(*) FILTER (WHERE ObjType NOT IN ('d', 'a')),
+		COUNT(*) FILTER (WHERE ObjType = 'a'), COUNT(*) FILTER (WHERE hasError = 1),
+		IFNULL(SUM(FileSize) FILTER (WHERE ObjType NOT IN ('d', 'a')), 0), IFNULL(SUM(FileSize) FILTER (WHERE ObjType = 'a'), 0),
+		MAX(LastWriteTime) FILTER (WHERE ObjType <> 'd') FROM fileinfo`
+	condition, args := served.subtreeCondition(path)
+	if condition != "" {
+		query += ` WHERE ` + condition
+	} else {
+		query += ` WHERE Path <> ?`
+		args = append(args, path)
+	}
+	var subtreeLastWrite sql.NullString
+	err = served.db.QueryRow(query, args...).Scan(&summary.Folders, &summary.Files, &summary.ArchiveMembers, &summary.Errors,
+		&summary.FileSize, &summary.ArchiveMemberSize, &subtreeLastWrite)
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, fmt.Sprintf("failed to summarize %s, error: %v", path, err))
+		return
+	}
+	if !found && summary.Folders+summary.Files+summary.ArchiveMembers == 0 {
+		writeAPIError(w, http.StatusNotFound, fmt.Sprintf("no entry %q", path))
+		return
+	}
+	if !found {
+		summary.Size = summary.ArchiveMemberSize
+	}
+	switch {
+	case summary.ObjType == "d":
+		summary.LastWriteTime = apiTime(calLastWrite)
+	case found:
+		summary.LastWriteTime = apiTime(lastWrite)
+	default:
+		summary.LastWriteTime = apiTime(parseReportTime(subtreeLastWrite.String))
+	}
+	writeAPIJSON(w, r, summary)
+}
+
+// finds entries by name, type, size and last write time, biggest first unless sort is name or time
+func (server *apiServer) handleSearch(w http.ResponseWriter, r *http.Request, served *apiDB) {
+	query := r.URL.Query()
+	var conditions []string
+	var args []interface{}
+	if objType := query.Get("type"); objType != "" {
+		if objType != "d" && objType != "f" && objType != "a" {
+			writeAPIError(w, http.StatusBadRequest, "type must be d, f or a")
+			return
+		}
+		conditions, args = append(conditions, "ObjType = ?"), append(args, objType)
+	}
+	for _, bound := range []struct{ parameter, operator string }{{"minSize", ">="}, {"maxSize", "<="}} {
+		if text := query.Get(bound.parameter); text != "" {
+			size, err := parseByteSize(text)
+			if err != nil {
+				writeAPIError(w, http.StatusBadRequest, fmt.Sprintf("%s must be a size like 10MB, error: %v", bound.parameter, err))
+				return
+			}
+			conditions, args = append(conditions, apiEntrySizeSQL+" "+bound.operator+" ?"), append(args, size)
+		}
+	}
+	for _, bound := range []struct{ parameter, operator string }{{"modifiedAfter", ">="}, {"modifiedBefore", "<"}} {
+		if text := query.Get(bound.parameter); text != "" {
+			t, err := parseAPITime(text)
+			if err != nil {
+				writeAPIError(w, http.StatusBadRequest, fmt.Sprintf("%s must be a date like 2024-01-31 or an RFC 3339 time", bound.parameter))
+				return
+			}
+			// the times are stored as text in the time zone of the scan, compared in UTC to the second
+			conditions = append(conditions, utcTimeSQL(apiEntryTimeSQL)+" "+bound.operator+" ?")
+			args = append(args, t.UTC().Format("2006-01-02 15:04:05"))
+		}
+	}
+	orderBy := apiEntrySizeSQL + " DESC"
+	switch query.Get("sort") {
+	case "", "size":
+	case "name", "path":
+		orderBy = "Path"
+	case "time":
+		orderBy = utcTimeSQL(apiEntryTimeSQL) + " DESC, Path"
+	default:
+		writeAPIError(w, http.StatusBadRequest, "sort must be size, path or time")
+		return
+	}
+	server.writeEntryPage(w, r, served, conditions, args, orderBy, query.Get("name"))
+}
+
+// lists the biggest files (type=f, the default), folders (type=d) or archive members (type=a)
+func (server *apiServer) handleTop(w http.ResponseWriter, r *http.Request, served *apiDB) {
+	objType := r.URL.Query().Get("type")
+	if objType == "" {
+		objType = "f"
+	}
+	if objType != "d" && objType != "f" && objType != "a" {
+		writeAPIError(w, http.StatusBadRequest, "type must be d, f or a")
+		return
+	}
+	conditions := []string{"ObjType = ?", "Path <> ?"} // the scan root is the biggest folder anyway
+	args := []interface{}{objType, served.rootPath}
+	orderBy := "FileSize DESC"
+	if objType == "d" {
+		orderBy = "TotalCalFolderSize DESC"
+	}
+	server.writeEntryPage(w, r, served, conditions, args, orderBy, "")
+}
+
+// lists the failed entries by path, filtered by error code and stage
+func (server *apiServer) handleErrors(w http.ResponseWriter, r *http.Request, served *apiDB) {
+	conditions := []string{"hasError = 1"}
+	var args []interface{}
+	for _, filter := range []struct{ parameter, column string }{{"code", "ErrorCode"}, {"stage", "ErrorStage"}} {
+		if value := r.URL.Query().Get(filter.parameter); value != "" {
+			if !served.errorColumns {
+				writeAPIError(w, http.StatusBadRequest, "the report DB is too old to have error codes, run retry on it first")
+				return
+			}
+			conditions, args = append(conditions, filter.column+" = ?"), append(args, value)
+		}
+	}
+	server.writeEntryPage(w, r, served, conditions, args, "Path", "")
+}
+
+// runs an entry query below the path parameter and writes the requested page of it. name filters the
+// names containing it, ignoring the case.
+func (server *apiServer) writeEntryPage(w http.ResponseWriter, r *http.Request, served *apiDB, conditions []string,
+	args []interface{}, orderBy string, name string) {
+	limit, offset, err := server.readPage(r)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if condition, subtreeArgs := served.subtreeCondition(served.readPath(r)); condition != "" {
+		conditions, args = append(conditions, condition), append(args, subtreeArgs...)
+	}
+	if name != "" {
+		// only the name has to match, not the folders above it. rtrim drops the characters other than
+		// the separator from the end, which leaves the folder of the path.
+		conditions = append(conditions, "instr(lower(substr(Path, length(rtrim(Path, replace(Path, ?, ''))) + 1)), lower(?)) > 0")
+		args = append(args, served.separator, name)
+	}
+	errorColumns := `'', ''`
+	if served.errorColumns {
+		errorColumns = `IFNULL(ErrorCode, ''), IFNULL(ErrorStage, '')`
+	}
+	query := `SELECT Path, ObjType, ObjectDepth, ` + apiEntrySizeSQL + `, IFNULL(Owner, ''), LastWriteTime, CalLastWriteTime,
+		IFNULL(hasError, 0), ` + errorColumns + `, IFNULL(ErrorMessage, '') FROM fileinfo`
+	if len(conditions) > 0 {
+		query += ` WHERE ` + strings.Join(conditions, " AND ")
+	}
+	query += ` ORDER BY ` + orderBy + ` LIMIT ? OFFSET ?`
+	args = append(args, limit+1, offset)
+	rows, err := served.db.Query(query+`;`, args...)
+	if err != nil {
+		writeAPIError(w, http.StatusInternalServerError, fmt.Sprintf("failed to query %s, error: %v", served.Name, err))
+		return
+	}
+	defer rows.Close()
+
+	page := APIPage{Items: []APIEntry{}, Offset: offset, Limit: limit}
+	for rows.Next() {
+		var entry APIEntry
+		var lastWrite, calLastWrite sql.NullTime
+		if err := rows.Scan(&entry.Path, &entry.ObjType, &entry.ObjectDepth, &entry.Size, &entry.Owner, &lastWrite, &calLastWrite,
+			&entry.HasError, &entry.ErrorCode, &entry.ErrorStage, &entry.ErrorMessage); err != nil {
+			writeAPIError(w, http.StatusInternalServerError, fmt.Sprintf("failed to scan a fileinfo row: %v", err))
+			return
+		}
+		entry.Name = pathBaseName(entry.Path, served.separator)
+		if len(page.Items) == limit {
+			nextOffset := offset + limit
+			page.NextOffset = &nextOffset
+			break
+		}
+		entry.LastWriteTime = apiTime(lastWrite)
+		if entry.ObjType == "d" {
+			entry.LastWriteTime = apiTime(calLastWrite)
+		}
+		page.Items = append(page.Items, entry)
+	}
+	if err := rows.Err(); err != nil {
+		writeAPIError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeAPIJSON(w, r, page)
+}
+
+// returns a report DB time for the JSON responses, nil when missing
+func apiTime(t sql.NullTime) *time.Time {
+	if !t.Valid || t.Time.IsZero() {
+		return nil
+	}
+	return &t.Time
+}
+
+// parses a date (in the local time zone) or an RFC 3339 time of a request
+func parseAPITime(text string) (time.Time, error) {
+	for _, layout := range []string{"2006-01-02", "2006-01-02 15:04:05", "2006-01-02T15:04:05"} {
+		if t, err := time.ParseInLocation(layout, text, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	t, err := time.Parse(time.RFC3339, text)
+	return t.Local(), err
+}
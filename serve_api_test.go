@@ -0,0 +1,302 @@
+//go:build windows || !windows
+// +build windows !windows
+
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// serves a report DB of a small /data scan, with an expanded archive.zip holding the docs folder.
+// b.txt was written at the same time as the others in another time zone, c.txt an hour later.
+func newTestAPIServer(t *testing.T, token string) http.Handler {
+	t.Helper()
+	dbFile := filepath.Join(t.TempDir(), "test.db")
+	db, err := sql.Open("sqlite", dbFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Exec(`CREATE TABLE fileinfo (ObjType TEXT, Path TEXT PRIMARY KEY UNIQUE, ObjectDepth INTEGER, FileSize INTEGER,
+		CompressedSize INTEGER, ThisFolderSize INTEGER, TotalCalFolderSize INTEGER, hasError BOOLEAN, ErrorMessage TEXT,
+		ErrorCode TEXT, ErrorStage TEXT, Owner TEXT, CreationTime DATETIME, LastWriteTime DATETIME, CalLastWriteTime DATETIME,
+		LastAccessTime DATETIME);`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows := []struct {
+		objType   string
+		path      string
+		depth     int
+		size      int
+		writeTime string
+	}{
+		{"d", "/data", 1, 0, ""},
+		{"d", "/data/a", 2, 0, ""},
+		{"f", "/data/a/x.txt", 2, 40, ""}, // a file has the depth of its folder
+		{"f", "/data/a/y.log", 2, 10, ""},
+		{"f", "/data/b.txt", 1, 30, "2024-01-02 05:04:05 +0200 EET"},
+		{"f", "/data/c.txt", 1, 5, "2024-01-01 23:04:05.5 -0500 EST"},
+		{"f", "/data/archive.zip", 1, 20, ""},
+		{"a", "/data/archive.zip/docs/readme.txt", 3, 100, ""},
+	}
+	for _, row := range rows {
+		writeTime := row.writeTime
+		if writeTime == "" {
+			writeTime = "2024-01-02 03:04:05 +0000 UTC"
+		}
+		total := row.size
+		switch row.path {
+		case "/data":
+			total = 105
+		case "/data/a":
+			total = 50
+		}
+		_, err := db.Exec(`INSERT INTO fileinfo (ObjType, Path, ObjectDepth, FileSize, TotalCalFolderSize, hasError, Owner,
+			LastWriteTime, CalLastWriteTime) VALUES (?, ?, ?, ?, ?, 0, 'alice', ?, ?);`,
+			row.objType, row.path, row.depth, row.size, total, writeTime, writeTime)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	db.Close()
+
+	served, err := openAPIDB(dbFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { served.db.Close() })
+	handler, err := newAPIServer([]*apiDB{served}, token, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return handler
+}
+
+// runs a GET request and decodes the JSON response into value, unless it is nil
+func apiGet(t *testing.T, handler http.Handler, url string, header http.Header, value interface{}) *httptest.ResponseRecorder {
+	t.Helper()
+	request := httptest.NewRequest(http.MethodGet, url, nil)
+	for key, values := range header {
+		request.Header[key] = values
+	}
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+	if value != nil && recorder.Code == http.StatusOK {
+		if err := json.Unmarshal(recorder.Body.Bytes(), value); err != nil {
+			t.Fatalf("GET %s returned %q: %v", url, recorder.Body.String(), err)
+		}
+	}
+	return recorder
+}
+
+func pageNames(page APIPage) []string {
+	names := []string{}
+	for _, item := range page.Items {
+		names = append(names, item.Name)
+	}
+	return names
+}
+
+func TestAPIChildrenPages(t *testing.T) {
+	handler := newTestAPIServer(t, "")
+	tests := []struct {
+		url        string
+		wantNames  []string
+		wantNext   int // -1 when there is no next page
+		wantCounts []int64
+	}{
+		{"/api/dbs/test/children?limit=2", []string{"a", "b.txt"}, 2, []int64{2, 0}},
+		{"/api/dbs/test/children?limit=2&offset=2", []string{"archive.zip", "c.txt"}, -1, []int64{1, 0}},
+		{"/api/dbs/test/children?limit=2&offset=4", []string{}, -1, []int64{}},
+		{"/api/dbs/test/children?sort=name&limit=3", []string{"a", "archive.zip", "b.txt"}, 3, []int64{2, 1, 0}},
+		{"/api/dbs/test/children?sort=name&order=desc", []string{"c.txt", "b.txt", "archive.zip", "a"}, -1, []int64{0, 0, 1, 2}},
+		{"/api/dbs/test/children?sort=count&limit=1", []string{"a"}, 1, []int64{2}},
+		{"/api/dbs/test/children?sort=count&order=asc&limit=3", []string{"b.txt", "c.txt", "archive.zip"}, 3, []int64{0, 0, 1}},
+		{"/api/dbs/test/children?sort=time&limit=1", []string{"c.txt"}, 1, []int64{0}}, // newest in UTC
+		{"/api/dbs/test/children?path=/data/a", []string{"x.txt", "y.log"}, -1, []int64{0, 0}},
+		{"/api/dbs/test/children?path=/data/archive.zip", []string{"docs"}, -1, []int64{1}},
+		{"/api/dbs/test/children?path=/data/archive.zip/docs", []string{"readme.txt"}, -1, []int64{0}},
+	}
+	for _, test := range tests {
+		var page APIPage
+		if recorder := apiGet(t, handler, test.url, nil, &page); recorder.Code != http.StatusOK {
+			t.Fatalf("GET %s = %d %s", test.url, recorder.Code, recorder.Body.String())
+		}
+		if names := pageNames(page); !reflect.DeepEqual(names, test.wantNames) {
+			t.Fatalf("GET %s items = %q, want %q", test.url, names, test.wantNames)
+		}
+		counts := []int64{}
+		for _, item := range page.Items {
+			counts = append(counts, *item.Entries)
+		}
+		if !reflect.DeepEqual(counts, test.wantCounts) {
+			t.Fatalf("GET %s entry counts = %v, want %v", test.url, counts, test.wantCounts)
+		}
+		next := -1
+		if page.NextOffset != nil {
+			next = *page.NextOffset
+		}
+		if next != test.wantNext {
+			t.Fatalf("GET %s NextOffset = %d, want %d", test.url, next, test.wantNext)
+		}
+	}
+
+	// the folder inside the archive has no row, it gets the member sizes and times
+	var page APIPage
+	apiGet(t, handler, "/api/dbs/test/children?path=/data/archive.zip", nil, &page)
+	if docs := page.Items[0]; docs.ObjType != "a" || docs.Size != 100 || docs.LastWriteTime == nil {
+		t.Fatalf("archive folder = %+v, want an a entry of 100 bytes with a time", docs)
+	}
+}
+
+func TestAPISearchName(t *testing.T) {
+	handler := newTestAPIServer(t, "")
+	tests := []struct {
+		url       string
+		wantNames []string
+		wantNext  bool
+	}{
+		{"/api/dbs/test/search?name=TXT", []string{"readme.txt", "x.txt", "b.txt", "c.txt"}, false},
+		{"/api/dbs/test/search?name=txt&limit=2&offset=1", []string{"x.txt", "b.txt"}, true},
+		{"/api/dbs/test/search?name=txt&limit=2&offset=2", []string{"b.txt", "c.txt"}, false},
+		{"/api/dbs/test/search?name=data", []string{"data"}, false}, // not the entries below /data
+		{"/api/dbs/test/search?name=a&sort=name", []string{"data", "a", "archive.zip", "readme.txt"}, false},
+	}
+	for _, test := range tests {
+		var page APIPage
+		if recorder := apiGet(t, handler, test.url, nil, &page); recorder.Code != http.StatusOK {
+			t.Fatalf("GET %s = %d %s", test.url, recorder.Code, recorder.Body.String())
+		}
+		if names := pageNames(page); !reflect.DeepEqual(names, test.wantNames) || (page.NextOffset != nil) != test.wantNext {
+			t.Fatalf("GET %s = %q, next %v, want %q, next %v", test.url, names, page.NextOffset != nil, test.wantNames, test.wantNext)
+		}
+	}
+}
+
+// the time bounds and the time order use UTC, whatever the time zone of the stored times
+func TestAPISearchTime(t *testing.T) {
+	handler := newTestAPIServer(t, "")
+	tests := []struct {
+		url       string
+		wantNames []string
+	}{
+		{"/api/dbs/test/search?modifiedAfter=2024-01-02T03:30:00Z", []string{"c.txt"}},
+		{"/api/dbs/test/search?modifiedAfter=2024-01-02T05:30:00%2B02:00", []string{"c.txt"}},
+		{"/api/dbs/test/search?type=f&modifiedBefore=2024-01-02T03:30:00Z", []string{"x.txt", "b.txt", "archive.zip", "y.log"}},
+		{"/api/dbs/test/search?type=f&modifiedAfter=2024-01-02T03:04:05Z&modifiedBefore=2024-01-02T03:04:06Z",
+			[]string{"x.txt", "b.txt", "archive.zip", "y.log"}},
+		{"/api/dbs/test/search?type=f&sort=time", []string{"c.txt", "x.txt", "y.log", "archive.zip", "b.txt"}},
+	}
+	for _, test := range tests {
+		var page APIPage
+		if recorder := apiGet(t, handler, test.url, nil, &page); recorder.Code != http.StatusOK {
+			t.Fatalf("GET %s = %d %s", test.url, recorder.Code, recorder.Body.String())
+		}
+		if names := pageNames(page); !reflect.DeepEqual(names, test.wantNames) {
+			t.Fatalf("GET %s = %q, want %q", test.url, names, test.wantNames)
+		}
+	}
+}
+
+func TestAPINotFound(t *testing.T) {
+	handler := newTestAPIServer(t, "")
+	for _, url := range []string{
+		"/api/dbs/missing/scan",
+		"/api/dbs/test/children?path=/data/missing",
+		"/api/dbs/test/children?path=/data/archive.zip/missing",
+		"/api/dbs/test/summary?path=/other",
+	} {
+		if recorder := apiGet(t, handler, url, nil, nil); recorder.Code != http.StatusNotFound {
+			t.Fatalf("GET %s = %d %s, want 404", url, recorder.Code, recorder.Body.String())
+		}
+	}
+	// an existing entry without children is listed empty
+	if recorder := apiGet(t, handler, "/api/dbs/test/children?path=/data/b.txt", nil, nil); recorder.Code != http.StatusOK {
+		t.Fatalf("GET children of a file = %d, want 200", recorder.Code)
+	}
+}
+
+func TestAPIBadRequest(t *testing.T) {
+	handler := newTestAPIServer(t, "")
+	for _, url := range []string{
+		"/api/dbs/test/children?limit=0",
+		"/api/dbs/test/children?offset=-1",
+		"/api/dbs/test/children?sort=color",
+		"/api/dbs/test/children?order=up",
+		"/api/dbs/test/search?type=x",
+		"/api/dbs/test/search?minSize=lots",
+	} {
+		if recorder := apiGet(t, handler, url, nil, nil); recorder.Code != http.StatusBadRequest {
+			t.Fatalf("GET %s = %d %s, want 400", url, recorder.Code, recorder.Body.String())
+		}
+	}
+}
+
+func TestAPIETag(t *testing.T) {
+	handler := newTestAPIServer(t, "")
+	const url = "/api/dbs/test/children?limit=2"
+	first := apiGet(t, handler, url, nil, nil)
+	etag := first.Header().Get("ETag")
+	if first.Code != http.StatusOK || etag == "" {
+		t.Fatalf("GET %s = %d with ETag %q", url, first.Code, etag)
+	}
+	tests := []struct {
+		ifNoneMatch string
+		want        int
+	}{
+		{etag, http.StatusNotModified},
+		{"W/" + etag, http.StatusNotModified},
+		{`"other", ` + etag, http.StatusNotModified},
+		{"*", http.StatusNotModified},
+		{`"other"`, http.StatusOK},
+	}
+	for _, test := range tests {
+		recorder := apiGet(t, handler, url, http.Header{"If-None-Match": {test.ifNoneMatch}}, nil)
+		if recorder.Code != test.want {
+			t.Fatalf("If-None-Match %s = %d, want %d", test.ifNoneMatch, recorder.Code, test.want)
+		}
+		if test.want == http.StatusNotModified && recorder.Body.Len() != 0 {
+			t.Fatalf("304 response has a body %q", recorder.Body.String())
+		}
+	}
+	// another page has another ETag
+	if other := apiGet(t, handler, url+"&offset=2", nil, nil); other.Header().Get("ETag") == etag {
+		t.Fatal("two pages have the same ETag")
+	}
+}
+
+func TestAPIToken(t *testing.T) {
+	handler := newTestAPIServer(t, "secret")
+	tests := []struct {
+		authorization string
+		want          int
+	}{
+		{"", http.StatusUnauthorized},
+		{"Bearer wrong", http.StatusUnauthorized},
+		{"secret", http.StatusUnauthorized},
+		{"Bearer secret", http.StatusOK},
+	}
+	for _, test := range tests {
+		header := http.Header{}
+		if test.authorization != "" {
+			header.Set("Authorization", test.authorization)
+		}
+		recorder := apiGet(t, handler, "/api/dbs", header, nil)
+		if recorder.Code != test.want {
+			t.Fatalf("Authorization %q = %d, want %d", test.authorization, recorder.Code, test.want)
+		}
+		if test.want == http.StatusUnauthorized && recorder.Header().Get("WWW-Authenticate") == "" {
+			t.Fatal("401 response without WWW-Authenticate")
+		}
+	}
+	// the token is checked before the DB lookup, so the DB names do not leak
+	if recorder := apiGet(t, handler, "/api/dbs/missing/scan", nil, nil); recorder.Code != http.StatusUnauthorized {
+		t.Fatalf("unauthorized GET of a missing DB = %d, want 401", recorder.Code)
+	}
+}
@@ -0,0 +1,140 @@
+//go:build windows || !windows
+// +build windows !windows
+
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// flag.Value of the -DBfile of serve, which can be given several times or comma separated
+type dbFileList []string
+
+func (files *dbFileList) String() string {
+	return strings.Join(*files, ",")
+}
+
+func (files *dbFileList) Set(value string) error {
+	for _, file := range strings.Split(value, ",") {
+		if file = strings.TrimSpace(file); file != "" {
+			*files = append(*files, file)
+		}
+	}
+	return nil
+}
+
+// Represents one report DB served by the API, addressed by its file name without .db
+type apiDB struct {
+	Name         string
+	File         string
+	db           *sql.DB
+	rootPath     string
+	rootDepth    int
+	separator    string
+	errorColumns bool // ErrorCode and ErrorStage exist, older DBs do not have them
+}
+
+// runServe implements the "serve" subcommand and returns the process exit code
+func runServe(args []string) int {
+	var dbFiles dbFileList
+	var addr, token string
+	var maxPageSize int
+
+	serveFlags := flag.NewFlagSet("serve", flag.ExitOnError)
+	serveFlags.Var(&dbFiles, "DBfile", "Scan report DB files to serve, comma separated or the flag repeated (mandatory)")
+	serveFlags.StringVar(&addr, "Addr", "127.0.0.1:8080", "Address the HTTP server listens on, e.g. :8080 for all interfaces (optional)")
+	serveFlags.StringVar(&token, "Token", "", "Bearer token required by the API, also read from FOLDERINSIGHT_TOKEN (optional, default is no authentication)")
+	serveFlags.IntVar(&maxPageSize, "MaxPageSize", 1000, "Most entries returned by one request (optional)")
+	addLogFlags(serveFlags)
+	if exitCode, ok := parseCommandFlags(serveFlags, args); !ok {
+		return exitCode
+	}
+
+	if len(dbFiles) == 0 {
+		fmt.Println("Mandatory fields are missing, check with serve -help")
+		return 2
+	}
+	if maxPageSize <= 0 {
+		fmt.Println("-MaxPageSize must be greater than 0")
+		return 2
+	}
+	level, err := parseLogFlags()
+	if err != nil {
+		fmt.Println(err)
+		return 2
+	}
+	setupLogging(os.Stderr, nil, level)
+
+	var dbs []*apiDB
+	for _, dbFile := range dbFiles {
+		served, err := openAPIDB(dbFile)
+		if err != nil {
+			logger.Error("Failed to open the report DB", "db", dbFile, "error", err)
+			return 1
+		}
+		defer served.db.Close()
+		dbs = append(dbs, served)
+		logger.Info("Serving the report DB", "db", served.File, "url", "/api/dbs/"+served.Name, "path", served.rootPath)
+	}
+	handler, err := newAPIServer(dbs, token, maxPageSize)
+	if err != nil {
+		logger.Error("Failed to start the API", "error", err)
+		return 1
+	}
+
+	server := &http.Server{Addr: addr, Handler: handler, ReadHeaderTimeout: 10 * time.Second}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+	if token == "" {
+		logger.Warn("No -Token given, the API does not require authentication")
+	}
+	logger.Info("Listening", "addr", addr)
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		logger.Error("Server failed", "addr", addr, "error", err)
+		return 1
+	}
+	logger.Info("Server stopped")
+	return 0
+}
+
+// opens a report DB read only for the API
+func openAPIDB(dbFile string) (*apiDB, error) {
+	reportDBfile, err := reportDBPath(dbFile)
+	if err != nil {
+		return nil, err
+	}
+	db, err := openReportDB(reportDBfile)
+	if err != nil {
+		return nil, err
+	}
+	served := &apiDB{Name: strings.TrimSuffix(filepath.Base(reportDBfile), ".db"), File: reportDBfile, db: db}
+	if served.rootPath, served.rootDepth, err = getScanRoot(db); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to open %s, error: %v", reportDBfile, err)
+	}
+	served.separator = reportPathSeparator(served.rootPath)
+	columns, err := fileinfoColumns(db)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	served.errorColumns = columns["ErrorCode"] && columns["ErrorStage"]
+	return served, nil
+}